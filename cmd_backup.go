@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/appgram/td/internal/db"
+)
+
+// defaultBackupKeep is how many automatic backups are kept when the
+// backup_keep setting is unset.
+const defaultBackupKeep = 10
+
+// autoBackupEvery spaces out the automatic backups, so opening td many
+// times in a row doesn't rotate the older ones away.
+const autoBackupEvery = time.Hour
+
+// runBackup implements "td backup [path]": an online copy of the database,
+// safe to take while the TUI or td serve is running. Without a path the
+// copy goes into the backups directory, outside the rotation.
+func runBackup(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td backup", flag.ExitOnError)
+	list := fs.Bool("list", false, "list the backups in the backups directory")
+	keep := fs.Int("keep", -1, "number of automatic backups to keep, remembered for next time (0 turns them off)")
+	fs.Parse(args)
+
+	if *keep >= 0 {
+		if err := database.SetSetting("backup_keep", strconv.Itoa(*keep)); err != nil {
+			return err
+		}
+		fmt.Printf("Keeping the last %d automatic backups\n", *keep)
+		if fs.NArg() == 0 && !*list {
+			return nil
+		}
+	}
+
+	if *list {
+		snapshots, err := database.Backups()
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			fmt.Printf("No backups in %s\n", database.BackupDir())
+		}
+		for _, path := range snapshots {
+			size := ""
+			if info, err := os.Stat(path); err == nil {
+				size = fmt.Sprintf("%6.1f KB", float64(info.Size())/1024)
+			}
+			fmt.Printf("%s  %s\n", size, path)
+		}
+		return nil
+	}
+
+	switch fs.NArg() {
+	case 0:
+		path, err := database.SaveSnapshot("manual")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backed up to %s\n", path)
+	case 1:
+		path := fs.Arg(0)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Join(path, time.Now().Format("td-20060102-150405.db"))
+		}
+		if err := database.Backup(path); err != nil {
+			return err
+		}
+		fmt.Printf("Backed up to %s\n", path)
+	default:
+		return errors.New("usage: td backup [--list] [--keep N] [path]")
+	}
+	return nil
+}
+
+// runRestore implements "td restore <file>": after checking the file is a
+// td database this version can read, the current data is saved to the
+// backups directory and replaced with the file's.
+func runRestore(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td restore", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: td restore <file>")
+	}
+
+	path := fs.Arg(0)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if inDir := filepath.Join(database.BackupDir(), path); inDir != path {
+			if _, err := os.Stat(inDir); err == nil {
+				path = inDir
+			}
+		}
+	}
+	version, err := db.CheckBackup(path)
+	if err != nil {
+		return err
+	}
+
+	saved, err := database.SaveSnapshot("before-restore")
+	if err != nil {
+		return fmt.Errorf("cannot save the current database first: %v", err)
+	}
+	if err := database.Restore(path); err != nil {
+		return fmt.Errorf("%v (the previous data is in %s)", err, saved)
+	}
+	fmt.Printf("Restored %s (schema version %d)\n", path, version)
+	fmt.Printf("The previous data was saved to %s\n", saved)
+	return nil
+}
+
+func backupKeep(database *db.DB) int {
+	v, err := database.GetSetting("backup_keep")
+	if err != nil || v == "" {
+		return defaultBackupKeep
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return defaultBackupKeep
+	}
+	return n
+}
+
+// autoBackup takes the startup backup, unless backups are turned off or
+// the last one is recent.
+func autoBackup(database *db.DB) error {
+	keep := backupKeep(database)
+	if keep == 0 {
+		return nil
+	}
+	snapshots, err := database.Snapshots()
+	if err != nil {
+		return err
+	}
+	if n := len(snapshots); n > 0 {
+		if t, ok := db.SnapshotTime(snapshots[n-1]); ok && time.Since(t) < autoBackupEvery {
+			return nil
+		}
+	}
+	_, err = database.Snapshot(keep)
+	return err
+}
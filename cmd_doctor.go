@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/appgram/td/internal/db"
+)
+
+// runDoctor implements "td doctor": check the database for damage and
+// inconsistent data, and offer to repair each kind of problem found.
+func runDoctor(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td doctor", flag.ExitOnError)
+	yes := fs.Bool("yes", false, "repair everything without asking")
+	dryRun := fs.Bool("dry-run", false, "only report problems")
+	fs.Parse(args)
+
+	diagnoses, err := database.Diagnose()
+	if err != nil {
+		return err
+	}
+
+	interactive := isTerminal(os.Stdin)
+	in := bufio.NewReader(os.Stdin)
+	var found, left int
+	var backedUp bool
+	for _, d := range diagnoses {
+		if len(d.Problems) == 0 {
+			fmt.Printf("ok    %s\n", d.Title)
+			continue
+		}
+		found += len(d.Problems)
+		fmt.Printf("FAIL  %s: %d\n", d.Title, len(d.Problems))
+		for _, p := range d.Problems {
+			fmt.Printf("      %s\n", p)
+		}
+
+		fix := *yes
+		if !fix && !*dryRun && interactive {
+			fmt.Printf("      Fix: %s? [y/N] ", d.Fix)
+			answer, _ := in.ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			fix = answer == "y" || answer == "yes"
+		}
+		if !fix || *dryRun {
+			left += len(d.Problems)
+			continue
+		}
+
+		if !backedUp {
+			path, err := database.SaveSnapshot("before-doctor")
+			if err != nil {
+				return fmt.Errorf("cannot back up before repairing: %v", err)
+			}
+			fmt.Printf("      Backed up to %s\n", path)
+			backedUp = true
+		}
+		changes, err := database.Repair(d.Check)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			fmt.Printf("      fixed: %s\n", c)
+		}
+	}
+
+	switch {
+	case found == 0:
+		fmt.Println("No problems found")
+	case left > 0:
+		return fmt.Errorf("%d problem(s) left; run td doctor again to repair them, or with --yes", left)
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
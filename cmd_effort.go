@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+type weekEffort struct {
+	done      int
+	estimated model.Estimate
+	tracked   time.Duration
+}
+
+// runEffort implements "td effort": per week, the estimated effort of the
+// tasks completed that week next to the time actually tracked.
+func runEffort(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td effort", flag.ExitOnError)
+	weeks := fs.Int("weeks", 4, "number of weeks to show, including this one")
+	fs.Parse(args)
+	if *weeks < 1 {
+		*weeks = 1
+	}
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	thisWeek := lastWeekday(midnight, cfg.FirstDayOfWeek())
+	since := thisWeek.AddDate(0, 0, -7*(*weeks-1))
+	weekOf := func(t time.Time) int {
+		for i := *weeks - 1; i >= 0; i-- {
+			if !t.Before(since.AddDate(0, 0, 7*i)) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	summary := make([]weekEffort, *weeks)
+	done, err := database.GetCompletedEstimates(since)
+	if err != nil {
+		return err
+	}
+	for _, c := range done {
+		if w := weekOf(c.CompletedAt); w >= 0 && w < len(summary) {
+			summary[w].done++
+			summary[w].estimated = summary[w].estimated.Add(c.Estimate)
+		}
+	}
+	entries, err := database.GetTimeEntries(since)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if w := weekOf(e.Start); w >= 0 && w < len(summary) {
+			summary[w].tracked += e.Duration()
+		}
+	}
+
+	fmt.Printf("%-12s %5s  %-14s %s\n", "Week of", "Done", "Estimated", "Tracked")
+	for i, w := range summary {
+		estimated := w.estimated.String()
+		if estimated == "" {
+			estimated = "-"
+		}
+		tracked := "-"
+		if w.tracked > 0 {
+			tracked = formatHours(w.tracked)
+		}
+		fmt.Printf("%-12s %5d  %-14s %s\n", since.AddDate(0, 0, 7*i).Format("2006-01-02"), w.done, estimated, tracked)
+	}
+	return nil
+}
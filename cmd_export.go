@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/ical"
+)
+
+// runExport implements "td export --format ics": a calendar of the tasks that
+// have due dates, written to a file or stdout, or served over HTTP so
+// calendar apps can subscribe to it.
+func runExport(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td export", flag.ExitOnError)
+	format := fs.String("format", "ics", "export format (ics)")
+	kind := fs.String("kind", "both", "calendar entries to emit: todo, event or both")
+	workspace := fs.String("workspace", "", "only export this workspace")
+	all := fs.Bool("all", false, "include completed and cancelled tasks")
+	output := fs.String("o", "", "write to this file instead of stdout")
+	serve := fs.String("serve", "", "serve the feed over HTTP on this address, e.g. 127.0.0.1:8765")
+	public := fs.Bool("public", false, "allow --serve on an address other machines can reach; the feed has no authentication")
+	fs.Parse(args)
+
+	if *format != "ics" {
+		return fmt.Errorf("unsupported format %q (ics)", *format)
+	}
+	k, ok := ical.ParseKind(*kind)
+	if !ok {
+		return fmt.Errorf("invalid --kind %q (todo, event, both)", *kind)
+	}
+	wsID, name, err := findWorkspace(database, *workspace)
+	if err != nil {
+		return err
+	}
+
+	write := func(w io.Writer) error {
+		tasks, err := database.GetDatedTasks(wsID, *all)
+		if err != nil {
+			return err
+		}
+		return ical.EncodeTasks(w, tasks, k, name)
+	}
+
+	if *serve != "" {
+		if !*public && !isLoopback(*serve) {
+			return fmt.Errorf("--serve %s is reachable from other machines and the feed has no authentication; use a 127.0.0.1 address or add --public", *serve)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+			if err := write(w); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+		})
+		fmt.Fprintf(os.Stderr, "Serving calendar feed at http://%s/td.ics\n", *serve)
+		return listenAndServe(*serve, mux)
+	}
+
+	if *output == "" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// isLoopback reports whether addr only listens on this machine. An empty
+// host listens everywhere.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// findWorkspace resolves a workspace by name, case-insensitively, or by
+// UUID prefix. An empty name means every workspace.
+func findWorkspace(database *db.DB, ref string) (int64, string, error) {
+	if ref == "" {
+		return 0, "td", nil
+	}
+	id, err := database.ResolveWorkspace(ref)
+	if err != nil {
+		return 0, "", err
+	}
+	workspaces, err := database.GetWorkspaces()
+	if err != nil {
+		return 0, "", err
+	}
+	for _, ws := range workspaces {
+		if ws.ID == id {
+			return ws.ID, ws.Name, nil
+		}
+	}
+	return 0, "", fmt.Errorf("workspace %q not found", ref)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/ical"
+)
+
+// runImport implements "td import --format ics": VTODOs from other apps
+// become tasks. Todos seen before, by their UID, are updated in place, so
+// importing the same file twice doesn't duplicate anything.
+func runImport(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td import", flag.ExitOnError)
+	format := fs.String("format", "ics", "import format (ics)")
+	workspace := fs.String("workspace", "", "import into this workspace, creating it if needed")
+	legacyIDs := fs.Bool("legacy-ids", false, `update task #N for "task-N@td" UIDs from older td exports of this database`)
+	fs.Parse(args)
+
+	if *format != "ics" {
+		return fmt.Errorf("unsupported format %q (ics)", *format)
+	}
+
+	var todos []ical.Todo
+	read := func(r io.Reader) error {
+		t, err := ical.DecodeTodos(r)
+		todos = append(todos, t...)
+		return err
+	}
+	if fs.NArg() == 0 {
+		if err := read(os.Stdin); err != nil {
+			return err
+		}
+	}
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = read(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	tasks := make([]db.ImportTask, len(todos))
+	for i, t := range todos {
+		tasks[i] = db.ImportTask{
+			UID:       t.UID,
+			ParentUID: t.ParentUID,
+			Title:     t.Title,
+			Notes:     t.Notes,
+			Tags:      t.Tags,
+			DueDate:   t.DueDate,
+			Priority:  t.Priority,
+			Status:    t.Status,
+			Workspace: t.Workspace,
+		}
+		// Older "task-N@td" UIDs only match task #N with --legacy-ids,
+		// since the same ID is an unrelated task in any other database.
+		if uuid, ok := ical.ParseTaskUID(t.UID); ok {
+			tasks[i].TaskUUID = uuid
+		} else if id, ok := ical.ParseLegacyTaskUID(t.UID); ok && *legacyIDs {
+			tasks[i].TaskID = id
+		}
+	}
+	result, err := database.ImportTasks(tasks, *workspace)
+	if err != nil {
+		return fmt.Errorf("nothing imported: %v", err)
+	}
+	fmt.Printf("Imported %d new, updated %d, skipped %d\n", result.Added, result.Updated, result.Skipped)
+	return nil
+}
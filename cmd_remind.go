@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/remind"
+)
+
+// runRemind implements "td remind": without flags it lists upcoming
+// reminders; with --daemon it keeps checking and announces due ones.
+func runRemind(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td remind", flag.ExitOnError)
+	daemon := fs.Bool("daemon", false, "keep running and announce reminders as they come due")
+	interval := fs.Duration("interval", 30*time.Second, "how often the daemon checks for due reminders")
+	command := fs.String("command", "", `notification command run through the shell, e.g. 'notify-send td "$TD_MESSAGE"' (default: hooks.remind in config.toml, then the remind_command setting)`)
+	fs.Parse(args)
+
+	if !*daemon {
+		return listReminders(database)
+	}
+
+	hook := *command
+	if hook == "" {
+		hook = cfg.Hooks.Remind
+	}
+	if hook == "" {
+		hook, _ = database.GetSetting("remind_command")
+	}
+	if *interval < time.Second {
+		*interval = time.Second
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	fmt.Fprintf(os.Stderr, "td remind: checking every %s\n", *interval)
+	for {
+		if err := announceDue(database, hook); err != nil {
+			fmt.Fprintf(os.Stderr, "td remind: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func announceDue(database *db.DB, hook string) error {
+	due, err := remind.Due(database, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, p := range due {
+		if hook == "" {
+			fmt.Printf("%s%s  %s\n", remind.TerminalAlert("td", remind.Message(p)), time.Now().Format("15:04"), remind.Message(p))
+			continue
+		}
+		if err := remind.RunHook(hook, p); err != nil {
+			fmt.Fprintf(os.Stderr, "td remind: hook failed for #%d: %v\n", p.TaskID, err)
+		}
+	}
+	return nil
+}
+
+func listReminders(database *db.DB) error {
+	pending, err := database.GetPendingReminders()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		fmt.Println("No upcoming reminders")
+		return nil
+	}
+	for _, p := range pending {
+		fmt.Printf("%s  #%d %s  [%s]\n", p.FireAt.Format("Mon 2006-01-02 15:04"), p.TaskID, remind.Message(p), p.WorkspaceName)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/appgram/td/internal/api"
+	"github.com/appgram/td/internal/db"
+)
+
+// runServe implements "td serve": the JSON API on a local address. The
+// bearer token lives in the api_token setting and is created on first use.
+func runServe(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:7777", "address to listen on")
+	newToken := fs.Bool("new-token", false, "replace the API token, locking out existing clients")
+	fs.Parse(args)
+
+	token, err := database.GetSetting(api.TokenSetting)
+	if err != nil {
+		return err
+	}
+	if token == "" || *newToken {
+		if token, err = generateToken(); err != nil {
+			return err
+		}
+		if err := database.SetSetting(api.TokenSetting, token); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving the td API at http://%s\n", *addr)
+	fmt.Fprintf(os.Stderr, "Authorization: Bearer %s\n", token)
+	return listenAndServe(*addr, api.Handler(database, token))
+}
+
+// listenAndServe serves handler on addr with timeouts, so a slow or idle
+// client can't hold a connection open indefinitely.
+func listenAndServe(addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	return srv.ListenAndServe()
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
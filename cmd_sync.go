@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/syncdir"
+)
+
+// runSync implements "td sync": merge with the other machines' change
+// records in the sync directory and write this machine's. When the
+// directory is a git checkout, it pulls first and commits and pushes after.
+func runSync(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td sync", flag.ExitOnError)
+	dir := fs.String("dir", "", "sync directory, remembered for next time")
+	noGit := fs.Bool("no-git", false, "don't pull, commit or push even if the directory is a git checkout")
+	fs.Parse(args)
+
+	if *dir == "" {
+		d, err := database.GetSetting("sync_dir")
+		if err != nil {
+			return err
+		}
+		if d == "" {
+			return errors.New("no sync directory yet; run td sync --dir <path> once")
+		}
+		*dir = d
+	} else {
+		abs, err := filepath.Abs(*dir)
+		if err != nil {
+			return err
+		}
+		*dir = abs
+		if err := database.SetSetting("sync_dir", abs); err != nil {
+			return err
+		}
+	}
+
+	machine, err := syncMachine(database)
+	if err != nil {
+		return err
+	}
+	var since int64
+	if v, _ := database.GetSetting("sync_last"); v != "" {
+		since, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	useGit := !*noGit && isGitCheckout(*dir)
+	if useGit && gitRemote(*dir) != "" {
+		if err := git(*dir, "fetch", "--quiet"); err != nil {
+			return err
+		}
+		if hasUpstream(*dir) {
+			if err := git(*dir, "pull", "--rebase", "--quiet"); err != nil {
+				return err
+			}
+		}
+	}
+
+	started := time.Now()
+	report, err := syncdir.Sync(database, *dir, machine, since)
+	if err != nil {
+		return err
+	}
+	if err := database.SetSetting("sync_last", strconv.FormatInt(started.UnixMilli(), 10)); err != nil {
+		return err
+	}
+
+	if useGit && report.Wrote {
+		if err := git(*dir, "add", machine+".jsonl"); err != nil {
+			return err
+		}
+		if err := git(*dir, "commit", "--quiet", "-m", "td sync from "+machine); err != nil {
+			return err
+		}
+		if hasUpstream(*dir) {
+			err = git(*dir, "push", "--quiet")
+		} else if remote := gitRemote(*dir); remote != "" {
+			err = git(*dir, "push", "--quiet", "--set-upstream", remote, "HEAD")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	printSyncReport(report)
+	return nil
+}
+
+// syncMachine is the name this machine's records are filed under: the host
+// name plus a random suffix, chosen on the first sync.
+func syncMachine(database *db.DB) (string, error) {
+	machine, err := database.GetSetting("sync_machine")
+	if err != nil || machine != "" {
+		return machine, err
+	}
+	host, _ := os.Hostname()
+	host = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(strings.Split(host, ".")[0]))
+	if host == "" {
+		host = "td"
+	}
+	machine = host + "-" + uuid.NewString()[:6]
+	return machine, database.SetSetting("sync_machine", machine)
+}
+
+func isGitCheckout(dir string) bool {
+	return exec.Command("git", "-C", dir, "rev-parse", "--is-inside-work-tree").Run() == nil
+}
+
+// hasUpstream reports whether the current branch tracks a remote branch
+// that exists, i.e. whether there is anything to pull.
+func hasUpstream(dir string) bool {
+	return exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", "@{upstream}").Run() == nil
+}
+
+// gitRemote is the first configured remote, or "" when there is none.
+func gitRemote(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "remote").Output()
+	if err != nil {
+		return ""
+	}
+	remotes := strings.Fields(string(out))
+	if len(remotes) == 0 {
+		return ""
+	}
+	return remotes[0]
+}
+
+func git(dir string, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return nil
+}
+
+func printSyncReport(r *syncdir.Report) {
+	fmt.Printf("Synced with %d other machine(s): %d new, %d updated, %d deleted\n",
+		len(r.Machines), r.Created, r.Updated, r.Deleted)
+	for _, c := range r.Conflicts {
+		switch c.Field {
+		case "parent":
+			fmt.Printf("conflict: %q was moved into a loop of subtasks; moved it to the top level\n", c.Title)
+		case "deleted":
+			if c.Kept.Value == nil {
+				fmt.Printf("conflict: %q was edited on %s but deleted later on %s; deleted it\n", c.Title, c.Lost.By, c.Kept.By)
+			} else {
+				fmt.Printf("conflict: %q was deleted on %s but edited later on %s; kept it\n", c.Title, c.Lost.By, c.Kept.By)
+			}
+		default:
+			fmt.Printf("conflict: %q %s: kept %s (%s, %s) over %s (%s, %s)\n", c.Title, c.Field,
+				describeValue(c.Kept.Value), c.Kept.By, formatMillis(c.Kept.At),
+				describeValue(c.Lost.Value), c.Lost.By, formatMillis(c.Lost.At))
+		}
+	}
+}
+
+// describeValue renders a field value for a conflict line, shortening long
+// text.
+func describeValue(raw json.RawMessage) string {
+	s := string(raw)
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		if text == "" {
+			return "(empty)"
+		}
+		if r := []rune(text); len(r) > 40 {
+			text = string(r[:39]) + "…"
+		}
+		s = strconv.Quote(text)
+	}
+	return s
+}
+
+func formatMillis(ms int64) string {
+	return time.UnixMilli(ms).Local().Format("2006-01-02 15:04")
+}
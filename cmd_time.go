@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appgram/td/internal/db"
+)
+
+// runTime implements "td time report|status|stop".
+func runTime(database *db.DB, args []string) error {
+	sub := "report"
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+	switch sub {
+	case "report":
+		return runTimeReport(database, args)
+	case "status":
+		running, err := database.GetRunningTimer()
+		if err != nil {
+			return err
+		}
+		if running == nil {
+			fmt.Println("No timer running")
+			return nil
+		}
+		fmt.Printf("%s  %s (since %s)\n", formatHours(time.Since(running.StartedAt)), running.Title, running.StartedAt.Format("15:04"))
+		return nil
+	case "stop":
+		stopped, err := database.StopTimer()
+		if err != nil {
+			return err
+		}
+		if stopped == nil {
+			fmt.Println("No timer running")
+			return nil
+		}
+		fmt.Printf("Logged %s on %s\n", formatHours(time.Since(stopped.StartedAt)), stopped.Title)
+		return nil
+	}
+	return fmt.Errorf("unknown time command %q (report, status, stop)", sub)
+}
+
+type timeTotal struct {
+	name  string
+	total time.Duration
+}
+
+func runTimeReport(database *db.DB, args []string) error {
+	fs := flag.NewFlagSet("td time report", flag.ExitOnError)
+	sinceFlag := fs.String("since", "week", "start of the report: today, yesterday, week, month, a weekday, Nd or YYYY-MM-DD")
+	fs.Parse(args)
+
+	since, err := parseSince(*sinceFlag, time.Now())
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetTimeEntries(since)
+	if err != nil {
+		return err
+	}
+
+	tasks := map[int64]*timeTotal{}
+	tags := map[string]*timeTotal{}
+	workspaces := map[int64]*timeTotal{}
+	var total time.Duration
+	add := func(totals map[string]*timeTotal, key string, d time.Duration) {
+		if totals[key] == nil {
+			totals[key] = &timeTotal{name: key}
+		}
+		totals[key].total += d
+	}
+	for _, e := range entries {
+		d := e.Duration()
+		total += d
+		if tasks[e.TaskID] == nil {
+			tasks[e.TaskID] = &timeTotal{name: fmt.Sprintf("%s (%s)", e.Title, e.WorkspaceName)}
+		}
+		tasks[e.TaskID].total += d
+		if workspaces[e.WorkspaceID] == nil {
+			workspaces[e.WorkspaceID] = &timeTotal{name: e.WorkspaceName}
+		}
+		workspaces[e.WorkspaceID].total += d
+		if len(e.Tags) == 0 {
+			add(tags, "(untagged)", d)
+		}
+		for _, tag := range e.Tags {
+			add(tags, "#"+tag, d)
+		}
+	}
+
+	fmt.Printf("Time tracked since %s\n", since.Format("Mon 2006-01-02 15:04"))
+	if total == 0 {
+		fmt.Println("\nNothing tracked.")
+		return nil
+	}
+	printTotals("Tasks", sortedTotals(tasks))
+	printTotals("Tags", sortedTotals(tags))
+	printTotals("Workspaces", sortedTotals(workspaces))
+	fmt.Printf("\n%8s  total\n", formatHours(total))
+	return nil
+}
+
+func sortedTotals[K comparable](totals map[K]*timeTotal) []*timeTotal {
+	list := make([]*timeTotal, 0, len(totals))
+	for _, t := range totals {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].total != list[j].total {
+			return list[i].total > list[j].total
+		}
+		return list[i].name < list[j].name
+	})
+	return list
+}
+
+func printTotals(heading string, totals []*timeTotal) {
+	fmt.Printf("\n%s\n", heading)
+	for _, t := range totals {
+		fmt.Printf("%8s  %s\n", formatHours(t.total), t.name)
+	}
+}
+
+// formatHours renders a duration as hours and minutes, e.g. "2h05m".
+func formatHours(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
+
+// parseSince turns a report start like "monday", "week" or "7d" into the
+// matching midnight. Weekdays mean the most recent one, today included.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "today", "":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	case "week":
+		return lastWeekday(midnight, cfg.FirstDayOfWeek()), nil
+	case "month":
+		return midnight.AddDate(0, 0, 1-now.Day()), nil
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if s == name || s == name[:3] {
+			return lastWeekday(midnight, day), nil
+		}
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && strings.HasSuffix(s, "d") && n >= 0 {
+		return midnight.AddDate(0, 0, -n), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q", s)
+}
+
+func lastWeekday(midnight time.Time, day time.Weekday) time.Time {
+	back := (int(midnight.Weekday()) - int(day) + 7) % 7
+	return midnight.AddDate(0, 0, -back)
+}
@@ -0,0 +1,468 @@
+// Package api serves workspaces and tasks as JSON over HTTP for editor
+// plugins, browser extensions and bots.
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+// TokenSetting is the settings key holding the bearer token clients must
+// send.
+const TokenSetting = "api_token"
+
+// maxBody caps request bodies; task edits are tiny.
+const maxBody = 1 << 20
+
+type server struct {
+	db    *db.DB
+	token string
+}
+
+// Handler returns the API. Every request must carry
+// "Authorization: Bearer <token>". Workspaces can be given by ID, name, UUID
+// or UUID prefix, tasks by ID, UUID or UUID prefix.
+//
+//	GET    /workspaces                  list workspaces
+//	POST   /workspaces                  create {"name"}
+//	GET    /workspaces/{id}/tasks       list tasks, parents before children
+//	POST   /workspaces/{id}/tasks       create {"text"} using inline syntax, optional "parent_id"
+//	GET    /tasks/{id}                  get a task
+//	PATCH  /tasks/{id}                  update any of title, notes, tags, due_date, priority, status, completed, estimate
+//	POST   /tasks/{id}/toggle           toggle completion
+//	POST   /tasks/{id}/move             reparent {"parent_id"}; null makes it top level
+//	DELETE /tasks/{id}                  delete a task and its subtasks
+func Handler(database *db.DB, token string) http.Handler {
+	s := &server{db: database, token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /workspaces", s.listWorkspaces)
+	mux.HandleFunc("POST /workspaces", s.createWorkspace)
+	mux.HandleFunc("GET /workspaces/{id}/tasks", s.listTasks)
+	mux.HandleFunc("POST /workspaces/{id}/tasks", s.createTask)
+	mux.HandleFunc("GET /tasks/{id}", s.getTask)
+	mux.HandleFunc("PATCH /tasks/{id}", s.updateTask)
+	mux.HandleFunc("POST /tasks/{id}/toggle", s.toggleTask)
+	mux.HandleFunc("POST /tasks/{id}/move", s.moveTask)
+	mux.HandleFunc("DELETE /tasks/{id}", s.deleteTask)
+	return s.authenticate(mux)
+}
+
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="td"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) listWorkspaces(w http.ResponseWriter, r *http.Request) {
+	workspaces, err := s.db.GetWorkspaces()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if workspaces == nil {
+		workspaces = []db.Workspace{}
+	}
+	writeJSON(w, http.StatusOK, workspaces)
+}
+
+func (s *server) createWorkspace(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name is required"))
+		return
+	}
+	id, err := s.db.CreateWorkspace(name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	workspaces, err := s.db.GetWorkspaces()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, ws := range workspaces {
+		if ws.ID == id {
+			writeJSON(w, http.StatusCreated, ws)
+			return
+		}
+	}
+	writeError(w, http.StatusInternalServerError, errors.New("workspace vanished after creation"))
+}
+
+func (s *server) listTasks(w http.ResponseWriter, r *http.Request) {
+	wsID, ok := s.workspaceID(w, r)
+	if !ok {
+		return
+	}
+	roots, err := s.db.GetTasksForWorkspace(wsID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	tasks := []*model.Task{}
+	var walk func([]*model.Task)
+	walk = func(level []*model.Task) {
+		sort.Slice(level, func(i, j int) bool { return level[i].Order < level[j].Order })
+		for _, t := range level {
+			tasks = append(tasks, t)
+			walk(t.Children)
+		}
+	}
+	walk(roots)
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *server) createTask(w http.ResponseWriter, r *http.Request) {
+	wsID, ok := s.workspaceID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Text     string `json:"text"`
+		ParentID *int64 `json:"parent_id"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	parsed := model.ParseTaskInput(req.Text, s.db)
+	if parsed.Title == "" {
+		writeError(w, http.StatusBadRequest, errors.New("task title is required"))
+		return
+	}
+	if parsed.Workspace != "" {
+		id, err := s.db.ResolveWorkspace(parsed.Workspace)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		wsID = id
+	}
+	if req.ParentID != nil {
+		parent, ok := s.task(w, *req.ParentID)
+		if !ok {
+			return
+		}
+		if parent.Workspace != wsID {
+			writeError(w, http.StatusBadRequest, errors.New("parent is in another workspace"))
+			return
+		}
+	}
+
+	// Dependencies are resolved before anything is written, so a bad one
+	// fails the request without leaving the task behind. Nothing depends on
+	// a new task yet, so they can't form a cycle.
+	deps := make([]int64, len(parsed.DependsOn))
+	for i, ref := range parsed.DependsOn {
+		dep, err := s.db.ResolveTask(ref)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("cannot depend on %s: %v", ref, err))
+			return
+		}
+		deps[i] = dep
+	}
+
+	id, err := s.db.AddTaskWithMeta(wsID, parsed.Title, req.ParentID, parsed.Tags, parsed.DueDate, parsed.Priority, parsed.Status, parsed.Estimate)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, rem := range parsed.Reminders {
+		if err := s.db.AddReminder(id, rem); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	for _, dep := range deps {
+		if err := s.db.AddDependency(id, dep); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	s.respondTask(w, http.StatusCreated, id)
+}
+
+func (s *server) getTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.taskID(w, r)
+	if !ok {
+		return
+	}
+	s.respondTask(w, http.StatusOK, id)
+}
+
+// taskPatch holds the fields a PATCH may change; absent fields are left
+// alone.
+type taskPatch struct {
+	Title     *string       `json:"title"`
+	Notes     *string       `json:"notes"`
+	Tags      *[]string     `json:"tags"`
+	DueDate   *string       `json:"due_date"`
+	Priority  *int          `json:"priority"`
+	Status    *model.Status `json:"status"`
+	Completed *bool         `json:"completed"`
+	Estimate  *string       `json:"estimate"`
+}
+
+func (s *server) updateTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.taskID(w, r)
+	if !ok {
+		return
+	}
+	var patch taskPatch
+	if !readJSON(w, r, &patch) {
+		return
+	}
+	task, ok := s.task(w, id)
+	if !ok {
+		return
+	}
+	if err := applyPatch(task, patch); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.db.UpdateTask(task); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.respondTask(w, http.StatusOK, id)
+}
+
+func applyPatch(task *model.Task, p taskPatch) error {
+	if p.Title != nil {
+		title := strings.TrimSpace(*p.Title)
+		if title == "" {
+			return errors.New("title cannot be empty")
+		}
+		task.Title = title
+	}
+	if p.Notes != nil {
+		task.Notes = *p.Notes
+	}
+	if p.Tags != nil {
+		task.Tags = nil
+		for _, tag := range *p.Tags {
+			if tag = strings.TrimPrefix(strings.TrimSpace(tag), "#"); tag != "" {
+				task.Tags = append(task.Tags, tag)
+			}
+		}
+	}
+	if p.DueDate != nil {
+		task.DueDate = ""
+		if *p.DueDate != "" {
+			due := model.ParseDueDate(*p.DueDate)
+			if _, err := time.Parse("2006-01-02", due); err != nil {
+				return fmt.Errorf("invalid due_date %q", *p.DueDate)
+			}
+			task.DueDate = due
+		}
+	}
+	if p.Priority != nil {
+		if *p.Priority < 0 || *p.Priority > 2 {
+			return errors.New("priority must be 0 (normal), 1 (low) or 2 (high)")
+		}
+		task.Priority = *p.Priority
+	}
+	if p.Estimate != nil {
+		task.Estimate = model.Estimate{}
+		if *p.Estimate != "" {
+			e, ok := model.ParseEstimate(*p.Estimate)
+			if !ok {
+				return fmt.Errorf("invalid estimate %q", *p.Estimate)
+			}
+			task.Estimate = e
+		}
+	}
+	if p.Completed != nil {
+		task.Completed = *p.Completed
+		if task.Completed {
+			task.Status = model.StatusDone
+		} else if task.Status == model.StatusDone {
+			task.Status = model.StatusOpen
+		}
+	}
+	if p.Status != nil {
+		status, ok := model.ParseStatus(string(*p.Status))
+		if !ok {
+			return fmt.Errorf("invalid status %q", *p.Status)
+		}
+		task.Status = status
+		task.Completed = status == model.StatusDone
+	}
+	return nil
+}
+
+func (s *server) toggleTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.taskID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := s.task(w, id); !ok {
+		return
+	}
+	if err := s.db.ToggleTask(id); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.respondTask(w, http.StatusOK, id)
+}
+
+func (s *server) moveTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.taskID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		ParentID *int64 `json:"parent_id"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	task, ok := s.task(w, id)
+	if !ok {
+		return
+	}
+	if req.ParentID != nil {
+		parent, ok := s.task(w, *req.ParentID)
+		if !ok {
+			return
+		}
+		if parent.Workspace != task.Workspace {
+			writeError(w, http.StatusBadRequest, errors.New("parent is in another workspace"))
+			return
+		}
+		loop, err := s.db.IsAncestor(id, parent.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if loop {
+			writeError(w, http.StatusBadRequest, errors.New("a task cannot be moved under itself"))
+			return
+		}
+	}
+	if err := s.db.MoveTask(id, req.ParentID); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.respondTask(w, http.StatusOK, id)
+}
+
+func (s *server) deleteTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.taskID(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := s.task(w, id); !ok {
+		return
+	}
+	if err := s.db.DeleteTask(id); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// workspaceID resolves the {id} path value: a workspace ID, name, UUID or
+// UUID prefix.
+func (s *server) workspaceID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	ref := r.PathValue("id")
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		workspaces, err := s.db.GetWorkspaces()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return 0, false
+		}
+		for _, ws := range workspaces {
+			if ws.ID == id {
+				return id, true
+			}
+		}
+	}
+	id, err := s.db.ResolveWorkspace(ref)
+	if err != nil {
+		writeRefError(w, err)
+		return 0, false
+	}
+	return id, true
+}
+
+// taskID resolves the {id} path value: a task ID, UUID or UUID prefix.
+func (s *server) taskID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := s.db.ResolveTask(r.PathValue("id"))
+	if err != nil {
+		writeRefError(w, err)
+		return 0, false
+	}
+	return id, true
+}
+
+func writeRefError(w http.ResponseWriter, err error) {
+	status := http.StatusNotFound
+	if errors.Is(err, db.ErrAmbiguous) {
+		status = http.StatusConflict
+	}
+	writeError(w, status, err)
+}
+
+// task loads a task, answering 404 when it doesn't exist.
+func (s *server) task(w http.ResponseWriter, id int64) (*model.Task, bool) {
+	task, err := s.db.GetTask(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("task %d not found", id))
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return task, true
+}
+
+func (s *server) respondTask(w http.ResponseWriter, status int, id int64) {
+	if task, ok := s.task(w, id); ok {
+		writeJSON(w, status, task)
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
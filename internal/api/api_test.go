@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+const testToken = "secret"
+
+// client sends requests straight to the handler and decodes the replies.
+type client struct {
+	t       *testing.T
+	db      *db.DB
+	handler http.Handler
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "td.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return &client{t: t, db: database, handler: Handler(database, testToken)}
+}
+
+// do sends a request with the test token, decodes a JSON reply into out
+// when it is not nil, and returns the status code.
+func (c *client) do(method, path, body string, out interface{}) int {
+	c.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, req)
+	if out != nil && rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			c.t.Fatalf("%s %s: %v in %q", method, path, err, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+func (c *client) countTasks(wsID int64) int {
+	c.t.Helper()
+	var n int
+	if err := c.db.QueryRow("SELECT COUNT(*) FROM tasks WHERE workspace_id = ?", wsID).Scan(&n); err != nil {
+		c.t.Fatal(err)
+	}
+	return n
+}
+
+func TestAuthentication(t *testing.T) {
+	c := newClient(t)
+	for _, header := range []string{"", "Bearer", "Bearer wrong", "Basic " + testToken, "bearer " + testToken} {
+		req := httptest.NewRequest("GET", "/workspaces", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		c.handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Authorization %q: status %d, want 401 with a challenge", header, rec.Code)
+		}
+	}
+	if code := c.do("GET", "/workspaces", "", nil); code != http.StatusOK {
+		t.Errorf("with the token: status %d, want 200", code)
+	}
+}
+
+func TestCreateTask(t *testing.T) {
+	c := newClient(t)
+	var ws model.Workspace
+	if code := c.do("POST", "/workspaces", `{"name":"Home"}`, &ws); code != http.StatusCreated {
+		t.Fatalf("create workspace: status %d", code)
+	}
+	var first model.Task
+	if code := c.do("POST", fmt.Sprintf("/workspaces/%d/tasks", ws.ID), `{"text":"Buy milk"}`, &first); code != http.StatusCreated {
+		t.Fatalf("create first task: status %d", code)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		check  func(t *testing.T, task model.Task)
+	}{
+		{
+			name:   "inline syntax",
+			body:   `{"text":"Ship it #work !high %wip @2026-03-01 =2h"}`,
+			status: http.StatusCreated,
+			check: func(t *testing.T, task model.Task) {
+				if task.Title != "Ship it" || len(task.Tags) != 1 || task.Tags[0] != "work" || task.Priority != 2 ||
+					task.Status != model.StatusInProgress || task.DueDate != "2026-03-01" || task.Estimate.Minutes != 120 {
+					t.Errorf("got %+v", task)
+				}
+			},
+		},
+		{
+			name:   "dependency",
+			body:   fmt.Sprintf(`{"text":"Make tea ~%d"}`, first.ID),
+			status: http.StatusCreated,
+			check: func(t *testing.T, task model.Task) {
+				if task.Title != "Make tea" || len(task.DependsOn) != 1 || task.DependsOn[0] != first.ID {
+					t.Errorf("got title %q, depends on %v; want a dependency on %d", task.Title, task.DependsOn, first.ID)
+				}
+			},
+		},
+		{
+			name:   "hex word that names no task",
+			body:   `{"text":"Meet at ~cafe"}`,
+			status: http.StatusCreated,
+			check: func(t *testing.T, task model.Task) {
+				if task.Title != "Meet at ~cafe" || len(task.DependsOn) != 0 {
+					t.Errorf("got title %q, depends on %v; want ~cafe kept in the title", task.Title, task.DependsOn)
+				}
+			},
+		},
+		{name: "missing dependency", body: `{"text":"Orphan ~999"}`, status: http.StatusBadRequest},
+		{name: "missing parent", body: `{"text":"Child","parent_id":999}`, status: http.StatusNotFound},
+		{name: "empty title", body: `{"text":"#work"}`, status: http.StatusBadRequest},
+		{name: "unknown field", body: `{"title":"Buy milk"}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := c.countTasks(ws.ID)
+			var task model.Task
+			code := c.do("POST", fmt.Sprintf("/workspaces/%d/tasks", ws.ID), tt.body, &task)
+			if code != tt.status {
+				t.Fatalf("status %d, want %d", code, tt.status)
+			}
+			if tt.check != nil {
+				tt.check(t, task)
+			}
+			if after := c.countTasks(ws.ID); tt.status != http.StatusCreated && after != before {
+				t.Errorf("a failed request left %d new tasks", after-before)
+			}
+		})
+	}
+}
+
+func TestTaskEndpoints(t *testing.T) {
+	c := newClient(t)
+	var ws model.Workspace
+	c.do("POST", "/workspaces", `{"name":"Home"}`, &ws)
+	var task model.Task
+	c.do("POST", fmt.Sprintf("/workspaces/%d/tasks", ws.ID), `{"text":"Write report"}`, &task)
+
+	var got model.Task
+	if code := c.do("GET", fmt.Sprintf("/tasks/%d", task.ID), "", &got); code != http.StatusOK || got.UUID != task.UUID {
+		t.Errorf("get by ID: status %d, task %+v", code, got)
+	}
+	if code := c.do("GET", "/tasks/"+task.UUID[:8], "", &got); code != http.StatusOK || got.ID != task.ID {
+		t.Errorf("get by UUID prefix: status %d, task %+v", code, got)
+	}
+	if code := c.do("GET", "/tasks/999", "", nil); code != http.StatusNotFound {
+		t.Errorf("get a missing task: status %d, want 404", code)
+	}
+
+	if code := c.do("PATCH", fmt.Sprintf("/tasks/%d", task.ID), `{"title":"Write the report","tags":["work"]}`, &got); code != http.StatusOK ||
+		got.Title != "Write the report" || len(got.Tags) != 1 {
+		t.Errorf("patch: status %d, task %+v", code, got)
+	}
+	if code := c.do("PATCH", fmt.Sprintf("/tasks/%d", task.ID), `{"status":"nonsense"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("patch with a bad status: status %d, want 400", code)
+	}
+	if code := c.do("POST", fmt.Sprintf("/tasks/%d/toggle", task.ID), "", &got); code != http.StatusOK || !got.Completed {
+		t.Errorf("toggle: status %d, completed %v", code, got.Completed)
+	}
+
+	var tasks []model.Task
+	if code := c.do("GET", fmt.Sprintf("/workspaces/%d/tasks", ws.ID), "", &tasks); code != http.StatusOK || len(tasks) != 1 {
+		t.Errorf("list: status %d, %d tasks", code, len(tasks))
+	}
+	if code := c.do("DELETE", fmt.Sprintf("/tasks/%d", task.ID), "", nil); code != http.StatusNoContent {
+		t.Errorf("delete: status %d, want 204", code)
+	}
+	if code := c.do("GET", fmt.Sprintf("/tasks/%d", task.ID), "", nil); code != http.StatusNotFound {
+		t.Errorf("get after delete: status %d, want 404", code)
+	}
+}
@@ -0,0 +1,135 @@
+// Package config reads config.toml, the settings kept in a file next to the
+// database rather than changed from inside td.
+//
+// A value set in the file wins over the same setting stored in the database.
+// Commands such as :scheme and :dashboard change the running session only;
+// :config reload puts the file's values back.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FileName is the name of the config file in the config directory.
+const FileName = "config.toml"
+
+// DefaultDateFormat is how due dates are shown when date_format is unset.
+const DefaultDateFormat = "2006-01-02"
+
+// Config is the contents of config.toml. Zero values mean "not set".
+type Config struct {
+	// DefaultWorkspace is the workspace the TUI opens on and td -a adds
+	// to, by name, dashed name or UUID prefix.
+	DefaultWorkspace string `toml:"default_workspace"`
+	// Theme is a color scheme name, as for :scheme.
+	Theme string `toml:"theme"`
+	// DateFormat is a Go time layout for showing due dates, e.g. "Jan 2".
+	DateFormat string `toml:"date_format"`
+	// WeekStart is the weekday weeks start on: monday (the default) or
+	// sunday, or any other weekday.
+	WeekStart string `toml:"week_start"`
+	// SidebarWidth is the width of the workspace list in columns.
+	SidebarWidth int `toml:"sidebar_width"`
+	// Dashboard shows or hides the dashboard line under the header.
+	Dashboard *bool `toml:"dashboard"`
+	// AsciiArt picks the header art by file name from the ascii directory,
+	// or "random".
+	AsciiArt string `toml:"ascii_art"`
+
+	Keys  Keys  `toml:"keys"`
+	Hooks Hooks `toml:"hooks"`
+}
+
+// Keys are key bindings, applied before keys.conf.
+type Keys struct {
+	Preset string            `toml:"preset"`
+	Map    map[string]string `toml:"map"` // keys to action
+	Unmap  []string          `toml:"unmap"`
+}
+
+// Hooks are shell commands td runs.
+type Hooks struct {
+	// Remind announces a due reminder for td remind --daemon.
+	Remind string `toml:"remind"`
+}
+
+// Path returns the config file in dir.
+func Path(dir string) string {
+	return filepath.Join(dir, FileName)
+}
+
+// Load reads the config file at path. A missing file is an empty config.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	md, err := toml.DecodeFile(path, cfg)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, len(undecoded))
+		for i, k := range undecoded {
+			keys[i] = k.String()
+		}
+		sort.Strings(keys)
+		return nil, fmt.Errorf("%s: unknown setting %s", filepath.Base(path), strings.Join(keys, ", "))
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if _, ok := parseWeekday(c.WeekStart); !ok && c.WeekStart != "" {
+		return fmt.Errorf("week_start: unknown weekday %q", c.WeekStart)
+	}
+	if c.SidebarWidth != 0 && (c.SidebarWidth < 10 || c.SidebarWidth > 80) {
+		return fmt.Errorf("sidebar_width: %d is outside 10-80", c.SidebarWidth)
+	}
+	if c.DateFormat != "" && strings.TrimSpace(time.Now().Format(c.DateFormat)) == "" {
+		return fmt.Errorf("date_format: %q shows nothing", c.DateFormat)
+	}
+	return nil
+}
+
+// FirstDayOfWeek is the weekday weeks start on.
+func (c *Config) FirstDayOfWeek() time.Weekday {
+	if day, ok := parseWeekday(c.WeekStart); ok {
+		return day
+	}
+	return time.Monday
+}
+
+// FormatDate shows a YYYY-MM-DD date in the configured date format. Dates
+// that don't parse are returned as they are.
+func (c *Config) FormatDate(date string) string {
+	if c.DateFormat == "" {
+		return date
+	}
+	t, err := time.Parse(DefaultDateFormat, date)
+	if err != nil {
+		return date
+	}
+	return t.Format(c.DateFormat)
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if s == name || (len(s) >= 3 && s == name[:3]) {
+			return day, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,268 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// snapshotStamp is the time in snapshot names.
+const snapshotStamp = "20060102-150405"
+
+// backupLayout names the rotating snapshots in BackupDir so they sort by
+// age.
+const backupLayout = "td-" + snapshotStamp + ".db"
+
+// onlineBackup is the part of the sqlite driver connection that runs
+// SQLite's online backup API.
+type onlineBackup interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+}
+
+// Path returns the file the database was opened from.
+func (db *DB) Path() string {
+	return db.path
+}
+
+// BackupDir is where snapshots of the database are kept: a backups
+// directory next to it.
+func (db *DB) BackupDir() string {
+	return filepath.Join(filepath.Dir(db.path), "backups")
+}
+
+// Backup copies the database to path with SQLite's online backup, so it is
+// consistent even while other processes are writing. The copy is written
+// next to path and renamed into place once complete.
+func (db *DB) Backup(path string) error {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	err := db.withBackup(func(c onlineBackup) (*sqlite.Backup, error) { return c.NewBackup(tmp) })
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Restore replaces the contents of the database with the backup at path,
+// after checking it with CheckBackup. Backups from older versions of td are
+// migrated afterwards.
+func (db *DB) Restore(path string) error {
+	if _, err := CheckBackup(path); err != nil {
+		return err
+	}
+	if err := db.withBackup(func(c onlineBackup) (*sqlite.Backup, error) { return c.NewRestore(path) }); err != nil {
+		return err
+	}
+	return migrate(db.DB)
+}
+
+// withBackup runs the backup that start sets up on one of the pool's
+// connections, copying all pages in one step.
+func (db *DB) withBackup(start func(onlineBackup) (*sqlite.Backup, error)) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(onlineBackup)
+		if !ok {
+			return fmt.Errorf("sqlite driver does not support online backup")
+		}
+		b, err := start(c)
+		if err != nil {
+			return err
+		}
+		for more := true; more; {
+			if more, err = b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+		}
+		return b.Finish()
+	})
+}
+
+// CheckBackup makes sure path is an intact td database that this version
+// of td can open, and returns its schema version.
+func CheckBackup(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	src, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	var check string
+	if err := src.QueryRow("PRAGMA quick_check").Scan(&check); err != nil {
+		return 0, fmt.Errorf("%s is not a readable SQLite database: %v", path, err)
+	}
+	if check != "ok" {
+		return 0, fmt.Errorf("%s is damaged: %s", path, check)
+	}
+
+	var tables int
+	err = src.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('tasks', 'workspaces', 'settings')").Scan(&tables)
+	if err != nil {
+		return 0, err
+	}
+	if tables < 3 {
+		return 0, fmt.Errorf("%s is not a td database", path)
+	}
+
+	var version int
+	if err := src.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	if version > len(migrations) {
+		return version, fmt.Errorf("%s has schema version %d, newer than this td supports (%d); upgrade td first",
+			path, version, len(migrations))
+	}
+	return version, nil
+}
+
+// Snapshot backs the database up into BackupDir under a timestamped name.
+// When keep is positive, the oldest snapshots beyond keep are deleted.
+func (db *DB) Snapshot(keep int) (string, error) {
+	dir := db.BackupDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	at := time.Now()
+	path := filepath.Join(dir, at.Format(backupLayout))
+	for exists(path) {
+		at = at.Add(time.Second)
+		path = filepath.Join(dir, at.Format(backupLayout))
+	}
+	if err := db.Backup(path); err != nil {
+		return "", err
+	}
+
+	snapshots, err := db.Snapshots()
+	if err != nil {
+		return path, err
+	}
+	for keep > 0 && len(snapshots) > keep {
+		if err := os.Remove(snapshots[0]); err != nil {
+			return path, err
+		}
+		snapshots = snapshots[1:]
+	}
+	return path, nil
+}
+
+// snapshotBeforeMigrate copies a database that has data and an older schema
+// into BackupDir before it is migrated. New databases have nothing to save.
+func (db *DB) snapshotBeforeMigrate() error {
+	var version, tables int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version >= len(migrations) {
+		return nil
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'tasks'").Scan(&tables); err != nil {
+		return err
+	}
+	if tables == 0 {
+		return nil
+	}
+	_, err := db.SaveSnapshot(fmt.Sprintf("before-v%d", len(migrations)))
+	return err
+}
+
+// SaveSnapshot backs the database up into BackupDir as
+// td-<label>-<time>.db. Named apart from the rotating snapshots, these are
+// never deleted by the rotation: they are taken on request or before td
+// changes data wholesale, and may be needed long after.
+func (db *DB) SaveSnapshot(label string) (string, error) {
+	dir := db.BackupDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	at := time.Now()
+	name := func(t time.Time) string {
+		return filepath.Join(dir, fmt.Sprintf("td-%s-%s.db", label, t.Format(snapshotStamp)))
+	}
+	path := name(at)
+	for exists(path) {
+		at = at.Add(time.Second)
+		path = name(at)
+	}
+	return path, db.Backup(path)
+}
+
+// Snapshots lists the rotating snapshots in BackupDir, oldest first.
+func (db *DB) Snapshots() ([]string, error) {
+	entries, err := os.ReadDir(db.BackupDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if _, err := time.Parse(backupLayout, e.Name()); err == nil && !e.IsDir() {
+			paths = append(paths, filepath.Join(db.BackupDir(), e.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Backups lists every backup in BackupDir, rotating or saved, oldest first.
+func (db *DB) Backups() ([]string, error) {
+	entries, err := os.ReadDir(db.BackupDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	type backup struct {
+		path string
+		at   time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, "td-") || !strings.HasSuffix(name, ".db") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{filepath.Join(db.BackupDir(), name), info.ModTime()})
+	}
+	sort.SliceStable(backups, func(i, j int) bool { return backups[i].at.Before(backups[j].at) })
+	paths := make([]string, len(backups))
+	for i, b := range backups {
+		paths[i] = b.path
+	}
+	return paths, nil
+}
+
+// SnapshotTime is when the snapshot at path was taken, from its name.
+func SnapshotTime(path string) (time.Time, bool) {
+	t, err := time.ParseInLocation(backupLayout, filepath.Base(path), time.Local)
+	return t, err == nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
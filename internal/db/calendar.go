@@ -0,0 +1,58 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/appgram/td/internal/model"
+)
+
+// DatedTask is a task with a due date, as published in calendar feeds.
+type DatedTask struct {
+	model.Task
+	ParentUUID    string
+	WorkspaceName string
+	CompletedAt   time.Time
+}
+
+// GetDatedTasks returns the tasks that have a due date, across workspaces
+// or in one workspace when workspaceID is non-zero. Completed and cancelled
+// tasks are included only when asked for.
+func (db *DB) GetDatedTasks(workspaceID int64, includeClosed bool) ([]DatedTask, error) {
+	rows, err := db.Query(`
+		SELECT t.id, COALESCE(t.uuid, ''), t.parent_id, COALESCE(p.uuid, ''), t.workspace_id, w.name, t.title, t.completed,
+			COALESCE(t.tags, ''), t.due_date, t.priority, t.created_at,
+			COALESCE(t.notes, ''), t.status, t.completed_at
+		FROM tasks t
+		JOIN workspaces w ON w.id = t.workspace_id
+		LEFT JOIN tasks p ON p.id = t.parent_id
+		WHERE COALESCE(t.due_date, '') != ''
+			AND (? = 0 OR t.workspace_id = ?)
+			AND (? OR (t.completed = 0 AND t.status NOT IN ('done', 'cancelled')))
+		ORDER BY t.due_date, t.id
+	`, workspaceID, workspaceID, includeClosed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []DatedTask
+	for rows.Next() {
+		var t DatedTask
+		var parentID, completedAt sql.NullInt64
+		var tags string
+		if err := rows.Scan(&t.ID, &t.UUID, &parentID, &t.ParentUUID, &t.Workspace, &t.WorkspaceName, &t.Title, &t.Completed,
+			&tags, &t.DueDate, &t.Priority, &t.CreatedAt, &t.Notes, &t.Status, &completedAt); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			t.ParentID = &parentID.Int64
+		}
+		if completedAt.Valid {
+			t.CompletedAt = time.Unix(completedAt.Int64, 0)
+		}
+		t.Tags = splitTags(tags)
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
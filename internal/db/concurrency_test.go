@@ -0,0 +1,210 @@
+package db
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// The test binary re-runs itself as a writer process when these are set.
+const (
+	workerDBEnv      = "TD_CONCURRENCY_DB"
+	workerWritersEnv = "TD_CONCURRENCY_WRITERS"
+	workerTasksEnv   = "TD_CONCURRENCY_TASKS"
+)
+
+// TestConcurrentWriters adds tasks from several processes, each with several
+// goroutines holding their own database handle, and checks that no write
+// failed and no two sibling tasks were given the same order.
+func TestConcurrentWriters(t *testing.T) {
+	if path := os.Getenv(workerDBEnv); path != "" {
+		writers, _ := strconv.Atoi(os.Getenv(workerWritersEnv))
+		tasks, _ := strconv.Atoi(os.Getenv(workerTasksEnv))
+		if err := writeConcurrently(path, writers, tasks); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	procs, writers, tasks := 4, 4, 100
+	if testing.Short() {
+		procs, writers, tasks = 2, 2, 20
+	}
+	path := filepath.Join(t.TempDir(), "td.db")
+	database, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if _, err := database.CreateWorkspace("Stress"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < procs; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestConcurrentWriters$", "-test.count=1")
+		cmd.Env = append(os.Environ(),
+			workerDBEnv+"="+path,
+			workerWritersEnv+"="+strconv.Itoa(writers),
+			workerTasksEnv+"="+strconv.Itoa(tasks),
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("writer process failed: %v\n%s", err, out)
+			}
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	want := procs * writers * tasks
+	var got int
+	if err := database.QueryRow("SELECT COUNT(*) FROM tasks").Scan(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("%d tasks written, want %d", got, want)
+	}
+
+	var dupes int
+	err = database.QueryRow(`
+		SELECT COUNT(*) FROM (
+			SELECT 1 FROM tasks
+			GROUP BY workspace_id, COALESCE(parent_id, 0), task_order
+			HAVING COUNT(*) > 1
+		)`).Scan(&dupes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dupes > 0 {
+		t.Errorf("%d sibling orders are shared by more than one task", dupes)
+	}
+}
+
+// writeConcurrently adds tasks from several goroutines, each with its own
+// database handle.
+func writeConcurrently(path string, writers, tasks int) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			if err := writeTasks(path, w, tasks); err != nil {
+				errs <- err
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// writeTasks adds tasks through one handle. Every fifth task goes under an
+// earlier one and every seventh of those is moved back to the top level, so
+// both order allocations are exercised.
+func writeTasks(path string, writer, tasks int) error {
+	database, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	workspaces, err := database.GetWorkspaces()
+	if err != nil {
+		return err
+	}
+	if len(workspaces) == 0 {
+		return fmt.Errorf("no workspace to write to")
+	}
+	wsID := workspaces[0].ID
+
+	rng := rand.New(rand.NewSource(int64(os.Getpid()*100 + writer)))
+	var added []int64
+	for i := 0; i < tasks; i++ {
+		var parent *int64
+		if i%5 == 4 && len(added) > 0 {
+			parent = &added[rng.Intn(len(added))]
+		}
+		title := fmt.Sprintf("pid %d writer %d task %d", os.Getpid(), writer, i)
+		id, err := database.AddTask(wsID, title, parent)
+		if err != nil {
+			return fmt.Errorf("add: %v", err)
+		}
+		added = append(added, id)
+		if i%7 == 6 && parent != nil {
+			if err := database.MoveTask(id, nil); err != nil {
+				return fmt.Errorf("move: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// TestConcurrentOppositeDependencies adds A→B and B→A at the same time from
+// two handles, many times over, and checks that no pair ends up in a cycle.
+func TestConcurrentOppositeDependencies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "td.db")
+	handles := make([]*DB, 2)
+	for i := range handles {
+		database, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer database.Close()
+		handles[i] = database
+	}
+	wsID, err := handles[0].CreateWorkspace("Deps")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pairs := 50
+	if testing.Short() {
+		pairs = 10
+	}
+	for i := 0; i < pairs; i++ {
+		a, err := handles[0].AddTask(wsID, fmt.Sprintf("a%d", i), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := handles[0].AddTask(wsID, fmt.Sprintf("b%d", i), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for j, edge := range [][2]int64{{a, b}, {b, a}} {
+			wg.Add(1)
+			go func(j int, edge [2]int64) {
+				defer wg.Done()
+				errs[j] = handles[j].AddDependency(edge[0], edge[1])
+			}(j, edge)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil && err != ErrDependencyCycle {
+				t.Fatalf("pair %d: %v", i, err)
+			}
+		}
+	}
+
+	var cycles int
+	err = handles[0].QueryRow(`SELECT COUNT(*) FROM task_deps d
+		JOIN task_deps r ON r.task_id = d.depends_on_id AND r.depends_on_id = d.task_id`).Scan(&cycles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cycles > 0 {
+		t.Errorf("%d dependencies are part of a two-task cycle", cycles)
+	}
+}
@@ -1,27 +1,38 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
+	"sync"
 
+	"github.com/google/uuid"
 	_ "modernc.org/sqlite"
 
 	"github.com/appgram/td/internal/model"
 )
 
 type Workspace struct {
-	ID             int64
-	Name           string
-	Order          int
-	TaskCount      int
-	CompletedCount int
+	ID             int64  `json:"id"`
+	UUID           string `json:"uuid"`
+	Name           string `json:"name"`
+	Order          int    `json:"order"`
+	TaskCount      int    `json:"task_count"`
+	CompletedCount int    `json:"completed_count"`
 }
 
 type DB struct {
 	*sql.DB
+	path string
+
+	// versionConn stays open for DataVersion, whose value is only
+	// meaningful when read on the same connection each time.
+	versionMu   sync.Mutex
+	versionConn *sql.Conn
 }
 
+// NewDB opens the database at ~/.config/td/td.db, creating it if needed.
 func NewDB() (*DB, error) {
 	home := os.Getenv("HOME")
 	if home == "" {
@@ -33,21 +44,76 @@ func NewDB() (*DB, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create config dir: %v", err)
 	}
+	return Open(dbPath)
+}
 
-	db, err := sql.Open("sqlite", dbPath)
+// Open opens or creates a td database at path and brings its schema up to
+// date.
+//
+// Several td processes may share one database: a TUI per terminal, td -a
+// from scripts, td serve and the reminder daemon. WAL mode lets readers
+// carry on while one of them writes, the busy timeout makes writers wait
+// for each other instead of failing with "database is locked", and
+// transactions take the write lock as they begin, so a read-then-write
+// inside one can't be interleaved with another process's write.
+func Open(path string) (*DB, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
+	d := &DB{DB: db, path: path}
+	if err := d.snapshotBeforeMigrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to back up before migrating: %v", err)
+	}
+
 	if err := initSchema(db); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to init schema: %v", err)
 	}
 
-	return &DB{DB: db}, nil
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %v", err)
+	}
+
+	return d, nil
+}
+
+// DataVersion returns SQLite's data_version for the database. It changes
+// whenever another connection or process commits a change, so polling it
+// tells whether the data on screen is stale.
+func (db *DB) DataVersion() (int64, error) {
+	db.versionMu.Lock()
+	defer db.versionMu.Unlock()
+	if db.versionConn == nil {
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			return 0, err
+		}
+		db.versionConn = conn
+	}
+	var v int64
+	err := db.versionConn.QueryRowContext(context.Background(), "PRAGMA data_version").Scan(&v)
+	return v, err
+}
+
+// Close releases the data_version connection and closes the database.
+func (db *DB) Close() error {
+	db.versionMu.Lock()
+	if db.versionConn != nil {
+		db.versionConn.Close()
+		db.versionConn = nil
+	}
+	db.versionMu.Unlock()
+	return db.DB.Close()
 }
 
 func initSchema(db *sql.DB) error {
@@ -112,7 +178,7 @@ func (db *DB) SetSetting(key, value string) error {
 
 func (db *DB) GetWorkspaces() ([]Workspace, error) {
 	rows, err := db.Query(`
-		SELECT w.id, w.name, w.word_order,
+		SELECT w.id, COALESCE(w.uuid, ''), w.name, w.word_order,
 			(SELECT COUNT(*) FROM tasks WHERE workspace_id = w.id) as task_count,
 			(SELECT COUNT(*) FROM tasks WHERE workspace_id = w.id AND completed = 1) as completed_count
 		FROM workspaces w ORDER BY w.word_order
@@ -125,20 +191,32 @@ func (db *DB) GetWorkspaces() ([]Workspace, error) {
 	var ws []Workspace
 	for rows.Next() {
 		var w Workspace
-		rows.Scan(&w.ID, &w.Name, &w.Order, &w.TaskCount, &w.CompletedCount)
+		rows.Scan(&w.ID, &w.UUID, &w.Name, &w.Order, &w.TaskCount, &w.CompletedCount)
 		ws = append(ws, w)
 	}
 	return ws, nil
 }
 
 func (db *DB) CreateWorkspace(name string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	var order int
-	db.QueryRow("SELECT COALESCE(MAX(word_order), -1) + 1 FROM workspaces").Scan(&order)
-	result, err := db.Exec("INSERT INTO workspaces (name, word_order) VALUES (?, ?)", name, order)
+	if err := tx.QueryRow("SELECT COALESCE(MAX(word_order), -1) + 1 FROM workspaces").Scan(&order); err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec("INSERT INTO workspaces (uuid, name, word_order) VALUES (?, ?, ?)", uuid.NewString(), name, order)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	return id, tx.Commit()
 }
 
 func (db *DB) DeleteWorkspace(id int64) error {
@@ -153,8 +231,9 @@ func (db *DB) RenameWorkspace(id int64, name string) error {
 
 func (db *DB) GetTasksForWorkspace(workspaceID int64) ([]*model.Task, error) {
 	rows, err := db.Query(`
-		SELECT id, parent_id, title, completed,
-			   COALESCE(tags, ''), COALESCE(due_date, ''), priority, task_order, created_at
+		SELECT id, COALESCE(uuid, ''), parent_id, title, completed,
+			   COALESCE(tags, ''), COALESCE(due_date, ''), priority, task_order, created_at,
+			   COALESCE(notes, ''), status, estimate_minutes, estimate_points
 		FROM tasks WHERE workspace_id = ? ORDER BY parent_id, task_order
 	`, workspaceID)
 	if err != nil {
@@ -169,7 +248,7 @@ func (db *DB) GetTasksForWorkspace(workspaceID int64) ([]*model.Task, error) {
 		var t model.Task
 		var parentID sql.NullInt64
 		var tags, dueDate sql.NullString
-		rows.Scan(&t.ID, &parentID, &t.Title, &t.Completed, &tags, &dueDate, &t.Priority, &t.Order, &t.CreatedAt)
+		rows.Scan(&t.ID, &t.UUID, &parentID, &t.Title, &t.Completed, &tags, &dueDate, &t.Priority, &t.Order, &t.CreatedAt, &t.Notes, &t.Status, &t.Estimate.Minutes, &t.Estimate.Points)
 		t.Workspace = workspaceID
 		if parentID.Valid {
 			t.ParentID = &parentID.Int64
@@ -183,6 +262,13 @@ func (db *DB) GetTasksForWorkspace(workspaceID int64) ([]*model.Task, error) {
 		tasks[t.ID] = &t
 	}
 
+	if err := db.loadDependencies(workspaceID, tasks); err != nil {
+		return nil, err
+	}
+	if err := db.loadReminders(workspaceID, tasks); err != nil {
+		return nil, err
+	}
+
 	for _, t := range tasks {
 		if t.ParentID == nil {
 			roots = append(roots, t)
@@ -196,12 +282,43 @@ func (db *DB) GetTasksForWorkspace(workspaceID int64) ([]*model.Task, error) {
 	return roots, nil
 }
 
+// GetTask returns a single task with its dependencies and reminders but
+// without its children.
+func (db *DB) GetTask(id int64) (*model.Task, error) {
+	var t model.Task
+	var parentID sql.NullInt64
+	var tags string
+	err := db.QueryRow(`
+		SELECT id, COALESCE(uuid, ''), parent_id, workspace_id, title, completed,
+			   COALESCE(tags, ''), COALESCE(due_date, ''), priority, task_order, created_at,
+			   COALESCE(notes, ''), status, estimate_minutes, estimate_points
+		FROM tasks WHERE id = ?
+	`, id).Scan(&t.ID, &t.UUID, &parentID, &t.Workspace, &t.Title, &t.Completed, &tags, &t.DueDate, &t.Priority, &t.Order,
+		&t.CreatedAt, &t.Notes, &t.Status, &t.Estimate.Minutes, &t.Estimate.Points)
+	if err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		t.ParentID = &parentID.Int64
+	}
+	t.Tags = splitTags(tags)
+
+	tasks := map[int64]*model.Task{t.ID: &t}
+	if err := db.loadDependencies(t.Workspace, tasks); err != nil {
+		return nil, err
+	}
+	if err := db.loadReminders(t.Workspace, tasks); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 func splitTags(s string) []string {
 	if s == "" {
 		return nil
 	}
 	var tags []string
-	for _, t := range splitString(s, ",") {
+	for _, t := range splitString(s, tagSep) {
 		if t != "" {
 			tags = append(tags, t)
 		}
@@ -215,7 +332,7 @@ func joinTags(tags []string) string {
 	}
 	result := tags[0]
 	for i := 1; i < len(tags); i++ {
-		result += "," + tags[i]
+		result += tagSep + tags[i]
 	}
 	return result
 }
@@ -237,20 +354,49 @@ func splitString(s, sep string) []string {
 }
 
 func (db *DB) AddTask(workspaceID int64, title string, parentID *int64) (int64, error) {
-	return db.AddTaskWithMeta(workspaceID, title, parentID, nil, "", 0)
+	return db.AddTaskWithMeta(workspaceID, title, parentID, nil, "", 0, model.StatusOpen, model.Estimate{})
 }
 
-func (db *DB) AddTaskWithMeta(workspaceID int64, title string, parentID *int64, tags []string, dueDate string, priority int) (int64, error) {
-	var order int
-	db.QueryRow("SELECT COALESCE(MAX(task_order), -1) + 1 FROM tasks WHERE workspace_id = ? AND (parent_id = ? OR (parent_id IS NULL AND ? IS NULL))",
-		workspaceID, coalesceNull(parentID), coalesceNull(parentID)).Scan(&order)
+func (db *DB) AddTaskWithMeta(workspaceID int64, title string, parentID *int64, tags []string, dueDate string, priority int, status model.Status, estimate model.Estimate) (int64, error) {
+	if status == "" {
+		status = model.StatusOpen
+	}
 
-	result, err := db.Exec("INSERT INTO tasks (workspace_id, parent_id, title, task_order, tags, due_date, priority) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		workspaceID, coalesceNull(parentID), title, order, joinTags(tags), nullIfEmpty(dueDate), priority)
+	tx, err := db.Begin()
 	if err != nil {
 		return 0, err
 	}
-	return result.LastInsertId()
+	defer tx.Rollback()
+
+	order, err := nextTaskOrder(tx, workspaceID, parentID)
+	if err != nil {
+		return 0, err
+	}
+	result, err := tx.Exec(`INSERT INTO tasks (uuid, workspace_id, parent_id, title, task_order, due_date, priority, status, completed,
+		estimate_minutes, estimate_points) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		uuid.NewString(), workspaceID, coalesceNull(parentID), title, order, nullIfEmpty(dueDate), priority, status, boolToInt(status == model.StatusDone),
+		estimate.Minutes, estimate.Points)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := setTaskTags(tx, id, tags); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// nextTaskOrder is the order that puts a new task last among its siblings.
+// Callers run it in the same transaction as the write that uses it, so two
+// processes adding tasks at once can't be handed the same order.
+func nextTaskOrder(tx *sql.Tx, workspaceID int64, parentID *int64) (int, error) {
+	var order int
+	err := tx.QueryRow("SELECT COALESCE(MAX(task_order), -1) + 1 FROM tasks WHERE workspace_id = ? AND (parent_id = ? OR (parent_id IS NULL AND ? IS NULL))",
+		workspaceID, coalesceNull(parentID), coalesceNull(parentID)).Scan(&order)
+	return order, err
 }
 
 func nullIfEmpty(s string) interface{} {
@@ -261,46 +407,102 @@ func nullIfEmpty(s string) interface{} {
 }
 
 func (db *DB) UpdateTask(task *model.Task) error {
-	_, err := db.Exec(`UPDATE tasks SET title = ?, completed = ?, tags = ?, due_date = ?, priority = ?
-		WHERE id = ?`, task.Title, boolToInt(task.Completed), joinTags(task.Tags),
-		task.DueDate, task.Priority, task.ID)
-	return err
+	status := task.Status
+	if status == "" {
+		status = model.StatusOpen
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`UPDATE tasks SET title = ?, completed = ?, due_date = ?, priority = ?, notes = ?, status = ?,
+		estimate_minutes = ?, estimate_points = ?
+		WHERE id = ?`, task.Title, boolToInt(task.Completed),
+		task.DueDate, task.Priority, nullIfEmpty(task.Notes), status,
+		task.Estimate.Minutes, task.Estimate.Points, task.ID)
+	if err != nil {
+		return err
+	}
+	if err := setTaskTags(tx, task.ID, task.Tags); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
+// DeleteTask deletes a task and all of its subtasks.
 func (db *DB) DeleteTask(id int64) error {
-	_, err := db.Exec("DELETE FROM tasks WHERE id = ?", id)
+	_, err := db.Exec(`
+		WITH RECURSIVE subtree(id) AS (
+			SELECT ?
+			UNION
+			SELECT t.id FROM tasks t JOIN subtree s ON t.parent_id = s.id
+		)
+		DELETE FROM tasks WHERE id IN (SELECT id FROM subtree)
+	`, id)
 	return err
 }
 
+// ToggleTask flips completion. Completing a task marks it done; reopening a
+// done task makes it open again.
 func (db *DB) ToggleTask(id int64) error {
-	_, err := db.Exec("UPDATE tasks SET completed = NOT completed WHERE id = ?", id)
+	_, err := db.Exec(`UPDATE tasks SET completed = NOT completed,
+		status = CASE WHEN completed = 0 THEN 'done' WHEN status = 'done' THEN 'open' ELSE status END
+		WHERE id = ?`, id)
 	return err
 }
 
 func (db *DB) SetTaskCompleted(id int64, completed bool) error {
-	_, err := db.Exec("UPDATE tasks SET completed = ? WHERE id = ?", boolToInt(completed), id)
+	_, err := db.Exec(`UPDATE tasks SET completed = ?,
+		status = CASE WHEN ? THEN 'done' WHEN status = 'done' THEN 'open' ELSE status END
+		WHERE id = ?`, boolToInt(completed), boolToInt(completed), id)
+	return err
+}
+
+// SetTaskStatus changes a task's workflow status; done also completes it.
+func (db *DB) SetTaskStatus(id int64, status model.Status) error {
+	_, err := db.Exec("UPDATE tasks SET status = ?, completed = ? WHERE id = ?",
+		status, boolToInt(status == model.StatusDone), id)
 	return err
 }
 
 func (db *DB) MoveTask(id int64, newParentID *int64) error {
-	var workspaceID int64
-	if err := db.QueryRow("SELECT workspace_id FROM tasks WHERE id = ?", id).Scan(&workspaceID); err != nil {
+	tx, err := db.Begin()
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	var order int
-	if err := db.QueryRow(
-		"SELECT COALESCE(MAX(task_order), -1) + 1 FROM tasks WHERE workspace_id = ? AND (parent_id = ? OR (parent_id IS NULL AND ? IS NULL))",
-		workspaceID, coalesceNull(newParentID), coalesceNull(newParentID),
-	).Scan(&order); err != nil {
+	var workspaceID int64
+	if err := tx.QueryRow("SELECT workspace_id FROM tasks WHERE id = ?", id).Scan(&workspaceID); err != nil {
 		return err
 	}
+	order, err := nextTaskOrder(tx, workspaceID, newParentID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE tasks SET parent_id = ?, task_order = ? WHERE id = ?", coalesceNull(newParentID), order, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	_, err := db.Exec("UPDATE tasks SET parent_id = ?, task_order = ? WHERE id = ?", coalesceNull(newParentID), order, id)
-	return err
+// IsAncestor reports whether ancestorID is id itself or one of its parents,
+// i.e. whether nesting ancestorID under id would create a loop.
+func (db *DB) IsAncestor(ancestorID, id int64) (bool, error) {
+	var n int
+	err := db.QueryRow(`
+		WITH RECURSIVE up(id) AS (
+			SELECT ?
+			UNION
+			SELECT t.parent_id FROM tasks t JOIN up ON t.id = up.id WHERE t.parent_id IS NOT NULL
+		)
+		SELECT COUNT(*) FROM up WHERE id = ?
+	`, id, ancestorID).Scan(&n)
+	return n > 0, err
 }
 
-func (db *DB) GetTaskStats(workspaceID int64) (total, completed, blocked int, err error) {
+func (db *DB) GetTaskStats(workspaceID int64) (total, completed, blocked, inProgress int, err error) {
 	err = db.QueryRow("SELECT COUNT(*) FROM tasks WHERE workspace_id = ?", workspaceID).Scan(&total)
 	if err != nil {
 		return
@@ -309,7 +511,11 @@ func (db *DB) GetTaskStats(workspaceID int64) (total, completed, blocked int, er
 	if err != nil {
 		return
 	}
-	err = db.QueryRow("SELECT COUNT(*) FROM tasks WHERE workspace_id = ? AND priority = -1", workspaceID).Scan(&blocked)
+	err = db.QueryRow("SELECT COUNT(*) FROM tasks WHERE workspace_id = ? AND status = 'blocked'", workspaceID).Scan(&blocked)
+	if err != nil {
+		return
+	}
+	err = db.QueryRow("SELECT COUNT(*) FROM tasks WHERE workspace_id = ? AND status = 'in-progress'", workspaceID).Scan(&inProgress)
 	return
 }
 
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/appgram/td/internal/model"
+)
+
+// ErrDependencyCycle is returned when a new dependency would make a task
+// (indirectly) depend on itself.
+var ErrDependencyCycle = errors.New("dependency would create a cycle")
+
+// openTask is a SQL condition on alias p that holds while p is unfinished.
+const openTask = `p.completed = 0 AND p.status NOT IN ('done', 'cancelled')`
+
+// DependencyRef is a task on the other end of a dependency edge.
+type DependencyRef struct {
+	ID        int64
+	Title     string
+	Status    model.Status
+	Completed bool
+}
+
+// AddDependency records that taskID cannot start until dependsOnID is done.
+// The checks and the insert share one transaction, which takes the write
+// lock up front, so two processes adding opposite edges can't both pass the
+// cycle check.
+func (db *DB) AddDependency(taskID, dependsOnID int64) error {
+	if taskID == dependsOnID {
+		return ErrDependencyCycle
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE id = ?", dependsOnID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return fmt.Errorf("task #%d not found", dependsOnID)
+	}
+
+	// Adding the edge closes a cycle if taskID is already reachable from
+	// dependsOnID by following existing prerequisites.
+	var cycle int
+	err = tx.QueryRow(`
+		WITH RECURSIVE reach(id) AS (
+			SELECT ?
+			UNION
+			SELECT d.depends_on_id FROM task_deps d JOIN reach r ON d.task_id = r.id
+		)
+		SELECT COUNT(*) FROM reach WHERE id = ?
+	`, dependsOnID, taskID).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle > 0 {
+		return ErrDependencyCycle
+	}
+
+	if _, err := tx.Exec("INSERT OR IGNORE INTO task_deps (task_id, depends_on_id) VALUES (?, ?)", taskID, dependsOnID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) RemoveDependency(taskID, dependsOnID int64) error {
+	_, err := db.Exec("DELETE FROM task_deps WHERE task_id = ? AND depends_on_id = ?", taskID, dependsOnID)
+	return err
+}
+
+// GetDependencies returns the prerequisites of a task and the tasks waiting on it.
+func (db *DB) GetDependencies(taskID int64) (blockers, dependents []DependencyRef, err error) {
+	blockers, err = db.queryDependencyRefs(`
+		SELECT t.id, t.title, t.status, t.completed FROM task_deps d
+		JOIN tasks t ON t.id = d.depends_on_id
+		WHERE d.task_id = ? ORDER BY t.id
+	`, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	dependents, err = db.queryDependencyRefs(`
+		SELECT t.id, t.title, t.status, t.completed FROM task_deps d
+		JOIN tasks t ON t.id = d.task_id
+		WHERE d.depends_on_id = ? ORDER BY t.id
+	`, taskID)
+	return blockers, dependents, err
+}
+
+func (db *DB) queryDependencyRefs(query string, taskID int64) ([]DependencyRef, error) {
+	rows, err := db.Query(query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []DependencyRef
+	for rows.Next() {
+		var r DependencyRef
+		if err := rows.Scan(&r.ID, &r.Title, &r.Status, &r.Completed); err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+	}
+	return refs, rows.Err()
+}
+
+// loadDependencies fills DependsOn and BlockedBy for the tasks of a workspace.
+func (db *DB) loadDependencies(workspaceID int64, tasks map[int64]*model.Task) error {
+	rows, err := db.Query(`
+		SELECT d.task_id, d.depends_on_id, `+openTask+`
+		FROM task_deps d
+		JOIN tasks t ON t.id = d.task_id
+		JOIN tasks p ON p.id = d.depends_on_id
+		WHERE t.workspace_id = ?
+		ORDER BY d.depends_on_id
+	`, workspaceID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID, dependsOnID int64
+		var open bool
+		if err := rows.Scan(&taskID, &dependsOnID, &open); err != nil {
+			return err
+		}
+		t, ok := tasks[taskID]
+		if !ok {
+			continue
+		}
+		t.DependsOn = append(t.DependsOn, dependsOnID)
+		if open {
+			t.BlockedBy = append(t.BlockedBy, dependsOnID)
+		}
+	}
+	return rows.Err()
+}
+
+// NextActions lists unfinished tasks in every workspace that can be worked on
+// right now: not blocked or waiting, no open subtasks and no open
+// prerequisites. Due tasks and high priorities come first.
+func (db *DB) NextActions(limit int) ([]SearchHit, error) {
+	return db.queryHits(`
+		SELECT t.id, t.parent_id, t.workspace_id, w.name, t.title, COALESCE(t.tags, ''), t.completed
+		FROM tasks t
+		JOIN workspaces w ON w.id = t.workspace_id
+		WHERE t.completed = 0 AND t.status IN ('open', 'in-progress')
+			AND NOT EXISTS (SELECT 1 FROM tasks p WHERE p.parent_id = t.id AND `+openTask+`)
+			AND NOT EXISTS (
+				SELECT 1 FROM task_deps d JOIN tasks p ON p.id = d.depends_on_id
+				WHERE d.task_id = t.id AND `+openTask+`
+			)
+		ORDER BY COALESCE(t.due_date, '') = '', t.due_date,
+			CASE t.priority WHEN 2 THEN 0 WHEN 1 THEN 2 ELSE 1 END,
+			w.word_order, t.task_order
+		LIMIT ?
+	`, limit)
+}
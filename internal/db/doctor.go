@@ -0,0 +1,703 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Diagnosis is what one td doctor check found.
+type Diagnosis struct {
+	Check    string   // short name, passed to Repair
+	Title    string   // what was checked
+	Fix      string   // what Repair does about it
+	Problems []string // one line per problem; empty when all is well
+}
+
+// doctorCheck finds one class of problem and repairs it, returning a line
+// per change made.
+type doctorCheck struct {
+	name   string
+	title  string
+	fix    string
+	find   func(q queryer) ([]string, error)
+	repair func(tx *sql.Tx) ([]string, error)
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// doctorChecks run in this order, so that parents are sorted out before
+// sibling orders are renumbered.
+var doctorChecks = []doctorCheck{
+	{"integrity", "SQLite integrity check", "rebuild the indexes",
+		findIntegrity, repairIntegrity},
+	{"orphans", "Tasks with a missing parent or workspace", "move them to the top level, or to a Recovered workspace",
+		findOrphans, repairOrphans},
+	{"cycles", "Subtasks nested in a loop", "move one task of each loop to the top level",
+		findCycles, repairCycles},
+	{"order", "Duplicate or gapped task and workspace order", "renumber siblings from 0, keeping their order",
+		findOrder, repairOrder},
+	{"due", "Malformed due dates", "normalise them to YYYY-MM-DD, or move them into the notes",
+		findDue, repairDue},
+	{"tags", "Empty or padded tags and stale tag links", "trim tag names, merging any that then match, and drop empty tags and dangling links",
+		findTags, repairTags},
+}
+
+// Diagnose runs every check without changing anything.
+func (db *DB) Diagnose() ([]Diagnosis, error) {
+	var out []Diagnosis
+	for _, c := range doctorChecks {
+		problems, err := c.find(db.DB)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", c.name, err)
+		}
+		out = append(out, Diagnosis{Check: c.name, Title: c.title, Fix: c.fix, Problems: problems})
+	}
+	return out, nil
+}
+
+// Repair fixes the problems found by the named check in one transaction
+// and describes each change.
+func (db *DB) Repair(check string) ([]string, error) {
+	for _, c := range doctorChecks {
+		if c.name != check {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback()
+		changes, err := c.repair(tx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", c.name, err)
+		}
+		return changes, tx.Commit()
+	}
+	return nil, fmt.Errorf("unknown check %q", check)
+}
+
+// doctorTask is the part of a task the checks look at.
+type doctorTask struct {
+	id        int64
+	workspace int64
+	parent    sql.NullInt64
+	order     int
+	title     string
+	due       string
+}
+
+func (t doctorTask) String() string {
+	title := t.title
+	if r := []rune(title); len(r) > 40 {
+		title = string(r[:39]) + "…"
+	}
+	return fmt.Sprintf("#%d %q", t.id, title)
+}
+
+func loadDoctorTasks(q queryer) (map[int64]*doctorTask, error) {
+	rows, err := q.Query(`SELECT id, workspace_id, parent_id, task_order, title, COALESCE(due_date, '')
+		FROM tasks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tasks := map[int64]*doctorTask{}
+	for rows.Next() {
+		t := &doctorTask{}
+		if err := rows.Scan(&t.id, &t.workspace, &t.parent, &t.order, &t.title, &t.due); err != nil {
+			return nil, err
+		}
+		tasks[t.id] = t
+	}
+	return tasks, rows.Err()
+}
+
+func loadWorkspaceNames(q queryer) (map[int64]string, error) {
+	rows, err := q.Query("SELECT id, name FROM workspaces")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
+
+// sortedTasks returns the tasks by ID, so reports come out in a stable order.
+func sortedTasks(tasks map[int64]*doctorTask) []*doctorTask {
+	list := make([]*doctorTask, 0, len(tasks))
+	for _, t := range tasks {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].id < list[j].id })
+	return list
+}
+
+func findIntegrity(q queryer) ([]string, error) {
+	rows, err := q.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
+
+func repairIntegrity(tx *sql.Tx) ([]string, error) {
+	if _, err := tx.Exec("REINDEX"); err != nil {
+		return nil, err
+	}
+	left, err := findIntegrity(tx)
+	if err != nil {
+		return nil, err
+	}
+	if len(left) > 0 {
+		return nil, fmt.Errorf("still damaged after rebuilding the indexes; restore a backup (td backup --list)")
+	}
+	return []string{"rebuilt all indexes"}, nil
+}
+
+// orphan is a task whose workspace is gone, or whose parent is gone or
+// lives in another workspace.
+type orphan struct {
+	task   *doctorTask
+	reason string
+}
+
+func findOrphanTasks(q queryer) ([]orphan, error) {
+	tasks, err := loadDoctorTasks(q)
+	if err != nil {
+		return nil, err
+	}
+	names, err := loadWorkspaceNames(q)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []orphan
+	for _, t := range sortedTasks(tasks) {
+		if _, ok := names[t.workspace]; !ok {
+			orphans = append(orphans, orphan{t, fmt.Sprintf("workspace %d does not exist", t.workspace)})
+			continue
+		}
+		if !t.parent.Valid {
+			continue
+		}
+		parent, ok := tasks[t.parent.Int64]
+		switch {
+		case !ok:
+			orphans = append(orphans, orphan{t, fmt.Sprintf("parent #%d does not exist", t.parent.Int64)})
+		case parent.workspace != t.workspace:
+			orphans = append(orphans, orphan{t, fmt.Sprintf("parent %s is in another workspace", parent)})
+		}
+	}
+	return orphans, nil
+}
+
+func findOrphans(q queryer) ([]string, error) {
+	orphans, err := findOrphanTasks(q)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, o := range orphans {
+		problems = append(problems, fmt.Sprintf("%s: %s", o.task, o.reason))
+	}
+	return problems, nil
+}
+
+// repairOrphans moves tasks with a missing parent to the top level, and
+// tasks of a deleted workspace to a Recovered workspace, keeping their
+// subtasks under them.
+func repairOrphans(tx *sql.Tx) ([]string, error) {
+	orphans, err := findOrphanTasks(tx)
+	if err != nil {
+		return nil, err
+	}
+	names, err := loadWorkspaceNames(tx)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := loadDoctorTasks(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered int64
+	var changes []string
+	for _, o := range orphans {
+		ws := o.task.workspace
+		where := "the top level"
+		if _, ok := names[ws]; !ok {
+			if recovered == 0 {
+				if recovered, err = recoveredWorkspace(tx); err != nil {
+					return nil, err
+				}
+			}
+			where = "the Recovered workspace"
+			if p, ok := tasks[o.task.parent.Int64]; ok && o.task.parent.Valid && p.workspace == ws {
+				if _, err := tx.Exec("UPDATE tasks SET workspace_id = ? WHERE id = ?", recovered, o.task.id); err != nil {
+					return nil, err
+				}
+				changes = append(changes, fmt.Sprintf("moved %s to %s", o.task, where))
+				continue
+			}
+			ws = recovered
+		}
+		order, err := nextTaskOrder(tx, ws, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE tasks SET workspace_id = ?, parent_id = NULL, task_order = ? WHERE id = ?",
+			ws, order, o.task.id); err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("moved %s to %s", o.task, where))
+	}
+	return changes, nil
+}
+
+// recoveredWorkspace returns the workspace orphans from deleted workspaces
+// are moved to, creating it if needed.
+func recoveredWorkspace(tx *sql.Tx) (int64, error) {
+	return syncWorkspace(tx, SyncWorkspace{Name: "Recovered"})
+}
+
+// findLoops returns the tasks of each parent loop, lowest ID first.
+func findLoops(q queryer) ([][]*doctorTask, error) {
+	tasks, err := loadDoctorTasks(q)
+	if err != nil {
+		return nil, err
+	}
+	// 0: unvisited, 1: on the current path, 2: done
+	state := map[int64]int{}
+	var loops [][]*doctorTask
+	for _, start := range sortedTasks(tasks) {
+		var path []*doctorTask
+		t := start
+		for t != nil && state[t.id] == 0 {
+			state[t.id] = 1
+			path = append(path, t)
+			if !t.parent.Valid {
+				t = nil
+			} else {
+				t = tasks[t.parent.Int64]
+			}
+		}
+		if t != nil && state[t.id] == 1 {
+			var loop []*doctorTask
+			for i := len(path) - 1; i >= 0; i-- {
+				loop = append(loop, path[i])
+				if path[i] == t {
+					break
+				}
+			}
+			sort.Slice(loop, func(i, j int) bool { return loop[i].id < loop[j].id })
+			loops = append(loops, loop)
+		}
+		for _, p := range path {
+			state[p.id] = 2
+		}
+	}
+	return loops, nil
+}
+
+func findCycles(q queryer) ([]string, error) {
+	loops, err := findLoops(q)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, loop := range loops {
+		names := make([]string, len(loop))
+		for i, t := range loop {
+			names[i] = t.String()
+		}
+		problems = append(problems, strings.Join(names, " → ")+" are each other's ancestors")
+	}
+	return problems, nil
+}
+
+func repairCycles(tx *sql.Tx) ([]string, error) {
+	loops, err := findLoops(tx)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, loop := range loops {
+		t := loop[0]
+		order, err := nextTaskOrder(tx, t.workspace, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE tasks SET parent_id = NULL, task_order = ? WHERE id = ?", order, t.id); err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("moved %s to the top level", t))
+	}
+	return changes, nil
+}
+
+// orderGroup is a set of siblings whose order should run 0, 1, 2, ...
+type orderGroup struct {
+	label string
+	table string
+	ids   []int64 // in their current order
+	bad   string  // what is wrong, or "" when the order is fine
+}
+
+func findOrderGroups(q queryer) ([]orderGroup, error) {
+	tasks, err := loadDoctorTasks(q)
+	if err != nil {
+		return nil, err
+	}
+	names, err := loadWorkspaceNames(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []orderGroup
+	rows, err := q.Query("SELECT id, word_order FROM workspaces ORDER BY word_order, id")
+	if err != nil {
+		return nil, err
+	}
+	var wsIDs []int64
+	var wsOrders []int
+	for rows.Next() {
+		var id int64
+		var order int
+		if err := rows.Scan(&id, &order); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		wsIDs = append(wsIDs, id)
+		wsOrders = append(wsOrders, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	groups = append(groups, orderGroup{label: "workspaces", table: "workspaces", ids: wsIDs, bad: orderProblem(wsOrders)})
+
+	type key struct{ ws, parent int64 }
+	siblings := map[key][]*doctorTask{}
+	var keys []key
+	for _, t := range sortedTasks(tasks) {
+		k := key{t.workspace, t.parent.Int64}
+		if siblings[k] == nil {
+			keys = append(keys, k)
+		}
+		siblings[k] = append(siblings[k], t)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ws != keys[j].ws {
+			return keys[i].ws < keys[j].ws
+		}
+		return keys[i].parent < keys[j].parent
+	})
+	for _, k := range keys {
+		if _, ok := names[k.ws]; !ok {
+			continue // reported as orphans
+		}
+		if _, ok := tasks[k.parent]; k.parent != 0 && !ok {
+			continue
+		}
+		list := siblings[k]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].order < list[j].order })
+		ids := make([]int64, len(list))
+		orders := make([]int, len(list))
+		for i, t := range list {
+			ids[i], orders[i] = t.id, t.order
+		}
+		label := fmt.Sprintf("top-level tasks of %q", names[k.ws])
+		if k.parent != 0 {
+			label = "subtasks of " + tasks[k.parent].String()
+		}
+		groups = append(groups, orderGroup{label: label, table: "tasks", ids: ids, bad: orderProblem(orders)})
+	}
+	return groups, nil
+}
+
+// orderProblem describes what is wrong with sorted sibling orders: values
+// shared by several siblings, and values skipped or not starting at 0.
+func orderProblem(orders []int) string {
+	var dupes, gaps int
+	next := 0
+	for i, o := range orders {
+		if i > 0 && o == orders[i-1] {
+			dupes++
+			continue
+		}
+		if o != next {
+			gaps++
+		}
+		next = o + 1
+	}
+	var parts []string
+	if dupes > 0 {
+		parts = append(parts, fmt.Sprintf("%d duplicate", dupes))
+	}
+	if gaps > 0 {
+		parts = append(parts, fmt.Sprintf("%d gap(s)", gaps))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func findOrder(q queryer) ([]string, error) {
+	groups, err := findOrderGroups(q)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, g := range groups {
+		if g.bad != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", g.label, g.bad))
+		}
+	}
+	return problems, nil
+}
+
+func repairOrder(tx *sql.Tx) ([]string, error) {
+	groups, err := findOrderGroups(tx)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, g := range groups {
+		if g.bad == "" {
+			continue
+		}
+		column := "task_order"
+		if g.table == "workspaces" {
+			column = "word_order"
+		}
+		for i, id := range g.ids {
+			if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", g.table, column), i, id); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, fmt.Sprintf("renumbered %d %s", len(g.ids), g.label))
+	}
+	return changes, nil
+}
+
+// dueLayouts are date formats a malformed due date can be recovered from.
+var dueLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-1-2",
+	"2006/01/02",
+	"2006/1/2",
+	"20060102",
+}
+
+func malformedDue(due string) bool {
+	if due == "" {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", due)
+	return err != nil
+}
+
+// normaliseDue recovers a date from a malformed due date.
+func normaliseDue(due string) (string, bool) {
+	due = strings.TrimSpace(due)
+	for _, layout := range dueLayouts {
+		if t, err := time.Parse(layout, due); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return "", false
+}
+
+func findDue(q queryer) ([]string, error) {
+	tasks, err := loadDoctorTasks(q)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, t := range sortedTasks(tasks) {
+		if malformedDue(t.due) {
+			problems = append(problems, fmt.Sprintf("%s: due date %q", t, t.due))
+		}
+	}
+	return problems, nil
+}
+
+func repairDue(tx *sql.Tx) ([]string, error) {
+	tasks, err := loadDoctorTasks(tx)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, t := range sortedTasks(tasks) {
+		if !malformedDue(t.due) {
+			continue
+		}
+		if due, ok := normaliseDue(t.due); ok {
+			if _, err := tx.Exec("UPDATE tasks SET due_date = ? WHERE id = ?", due, t.id); err != nil {
+				return nil, err
+			}
+			changes = append(changes, fmt.Sprintf("%s: due date %q → %s", t, t.due, due))
+			continue
+		}
+		_, err := tx.Exec(`UPDATE tasks SET due_date = NULL,
+			notes = CASE WHEN COALESCE(notes, '') = '' THEN ? ELSE notes || char(10) || ? END WHERE id = ?`,
+			"due: "+t.due, "due: "+t.due, t.id)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, fmt.Sprintf("%s: cleared due date %q and kept it in the notes", t, t.due))
+	}
+	return changes, nil
+}
+
+// staleTagsSQL finds tasks whose tasks.tags copy disagrees with task_tags.
+var staleTagsSQL = fmt.Sprintf("COALESCE(tags, '') != COALESCE(%s, '')", fmt.Sprintf(taskTagsSQL, "tasks.id"))
+
+func findTags(q queryer) ([]string, error) {
+	var problems []string
+	err := eachRow(q, `SELECT id, name FROM tags WHERE name = '' OR name != trim(name) OR name LIKE '#%' ORDER BY id`,
+		func(rows *sql.Rows) error {
+			var id int64
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				return err
+			}
+			problems = append(problems, fmt.Sprintf("tag %d is named %q", id, name))
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = eachRow(q, `SELECT COUNT(*) FROM task_tags
+		WHERE task_id NOT IN (SELECT id FROM tasks) OR tag_id NOT IN (SELECT id FROM tags)`,
+		func(rows *sql.Rows) error {
+			var n int
+			if err := rows.Scan(&n); err != nil {
+				return err
+			}
+			if n > 0 {
+				problems = append(problems, fmt.Sprintf("%d tag link(s) to missing tasks or tags", n))
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	err = eachRow(q, "SELECT id, title FROM tasks WHERE "+staleTagsSQL+" ORDER BY id",
+		func(rows *sql.Rows) error {
+			t := &doctorTask{}
+			if err := rows.Scan(&t.id, &t.title); err != nil {
+				return err
+			}
+			problems = append(problems, fmt.Sprintf("%s: tags shown differ from its tag links", t))
+			return nil
+		})
+	return problems, err
+}
+
+func eachRow(q queryer, query string, scan func(*sql.Rows) error) error {
+	rows, err := q.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func repairTags(tx *sql.Tx) ([]string, error) {
+	type badTag struct {
+		id   int64
+		name string
+	}
+	var bad []badTag
+	err := eachRow(tx, `SELECT id, name FROM tags WHERE name = '' OR name != trim(name) OR name LIKE '#%' ORDER BY id`,
+		func(rows *sql.Rows) error {
+			var t badTag
+			if err := rows.Scan(&t.id, &t.name); err != nil {
+				return err
+			}
+			bad = append(bad, t)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	for _, t := range bad {
+		clean := cleanTagNames([]string{t.name})
+		var other int64
+		if len(clean) > 0 {
+			err := tx.QueryRow("SELECT id FROM tags WHERE name = ? AND id != ?", clean[0], t.id).Scan(&other)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, err
+			}
+		}
+		switch {
+		case len(clean) == 0:
+			err = execAll(tx,
+				fmt.Sprintf("DELETE FROM task_tags WHERE tag_id = %d", t.id),
+				fmt.Sprintf("DELETE FROM tags WHERE id = %d", t.id))
+			changes = append(changes, fmt.Sprintf("removed empty tag %q", t.name))
+		case other != 0:
+			err = execAll(tx,
+				fmt.Sprintf("INSERT OR IGNORE INTO task_tags (task_id, tag_id, position) SELECT task_id, %d, position FROM task_tags WHERE tag_id = %d", other, t.id),
+				fmt.Sprintf("DELETE FROM task_tags WHERE tag_id = %d", t.id),
+				fmt.Sprintf("DELETE FROM tags WHERE id = %d", t.id))
+			changes = append(changes, fmt.Sprintf("merged tag %q into #%s", t.name, clean[0]))
+		default:
+			_, err = tx.Exec("UPDATE tags SET name = ? WHERE id = ?", clean[0], t.id)
+			changes = append(changes, fmt.Sprintf("renamed tag %q to #%s", t.name, clean[0]))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := tx.Exec(`DELETE FROM task_tags
+		WHERE task_id NOT IN (SELECT id FROM tasks) OR tag_id NOT IN (SELECT id FROM tags)`)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		changes = append(changes, fmt.Sprintf("removed %d dangling tag link(s)", n))
+	}
+
+	res, err = tx.Exec(fmt.Sprintf("UPDATE tasks SET tags = %s WHERE %s", fmt.Sprintf(taskTagsSQL, "tasks.id"), staleTagsSQL))
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		changes = append(changes, fmt.Sprintf("refreshed the tags shown for %d task(s)", n))
+	}
+	return changes, nil
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/appgram/td/internal/model"
+)
+
+func TestDoctorRepairsDamage(t *testing.T) {
+	database := newTestDB(t)
+	wsID, err := database.CreateWorkspace("Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]int64{}
+	for _, title := range []string{"Lost parent", "Lost workspace", "Loop A", "Loop B", "Slash date", "Someday", "Tagged"} {
+		id, err := database.AddTask(wsID, title, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[title] = id
+	}
+	tagged := getTask(t, database, ids["Tagged"])
+	tagged.Tags = []string{"home"}
+	if err := database.UpdateTask(tagged); err != nil {
+		t.Fatal(err)
+	}
+	damage := []string{
+		`UPDATE tasks SET parent_id = 999 WHERE title = 'Lost parent'`,
+		`UPDATE tasks SET workspace_id = 99 WHERE title = 'Lost workspace'`,
+		`UPDATE tasks SET parent_id = (SELECT id FROM tasks WHERE title = 'Loop B') WHERE title = 'Loop A'`,
+		`UPDATE tasks SET parent_id = (SELECT id FROM tasks WHERE title = 'Loop A') WHERE title = 'Loop B'`,
+		`UPDATE tasks SET task_order = 0 WHERE title IN ('Slash date', 'Someday')`,
+		`UPDATE tasks SET due_date = '2026/3/1' WHERE title = 'Slash date'`,
+		`UPDATE tasks SET due_date = 'someday', notes = 'call first' WHERE title = 'Someday'`,
+		`UPDATE tags SET name = ' home' WHERE name = 'home'`,
+		`INSERT INTO task_tags (task_id, tag_id) VALUES (999, 1)`,
+	}
+	for _, s := range damage {
+		if _, err := database.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+
+	found := diagnose(t, database)
+	for _, check := range []string{"orphans", "cycles", "order", "due", "tags"} {
+		if len(found[check]) == 0 {
+			t.Errorf("%s: found nothing", check)
+		}
+	}
+	if len(found["integrity"]) != 0 {
+		t.Errorf("integrity: %q, want no problems", found["integrity"])
+	}
+
+	for _, c := range doctorChecks {
+		if _, err := database.Repair(c.name); err != nil {
+			t.Fatalf("repair %s: %v", c.name, err)
+		}
+	}
+	for check, problems := range diagnose(t, database) {
+		if len(problems) != 0 {
+			t.Errorf("%s after repair: %q", check, problems)
+		}
+	}
+
+	for _, title := range []string{"Lost parent", "Loop A"} {
+		if task := getTask(t, database, ids[title]); task.ParentID != nil {
+			t.Errorf("%s: parent %d, want the top level", title, *task.ParentID)
+		}
+	}
+	if task := getTask(t, database, ids["Lost workspace"]); task.Workspace == wsID || task.Workspace == 99 {
+		t.Errorf("Lost workspace: in workspace %d, want the Recovered workspace", task.Workspace)
+	}
+	if task := getTask(t, database, ids["Slash date"]); task.DueDate != "2026-03-01" {
+		t.Errorf("Slash date: due %q, want 2026-03-01", task.DueDate)
+	}
+	if task := getTask(t, database, ids["Someday"]); task.DueDate != "" || task.Notes != "call first\ndue: someday" {
+		t.Errorf("Someday: due %q, notes %q; want the date moved into the notes", task.DueDate, task.Notes)
+	}
+	if task := getTask(t, database, ids["Tagged"]); strings.Join(task.Tags, ",") != "home" {
+		t.Errorf("Tagged: tags %q, want [home]", task.Tags)
+	}
+}
+
+// diagnose runs every check and returns the problems by check name.
+func diagnose(t *testing.T, database *DB) map[string][]string {
+	t.Helper()
+	diagnoses, err := database.Diagnose()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[string][]string{}
+	for _, d := range diagnoses {
+		found[d.Check] = d.Problems
+	}
+	return found
+}
+
+func getTask(t *testing.T, database *DB, id int64) *model.Task {
+	t.Helper()
+	task, err := database.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"time"
+
+	"github.com/appgram/td/internal/model"
+)
+
+// CompletedEstimate is the estimate of a task completed at a known time.
+type CompletedEstimate struct {
+	TaskID      int64
+	CompletedAt time.Time
+	Estimate    model.Estimate
+}
+
+// GetCompletedEstimates returns the tasks completed since the given moment
+// with their estimates. A parent's own estimate is left out when its
+// subtasks carry estimates, so effort isn't counted twice.
+func (db *DB) GetCompletedEstimates(since time.Time) ([]CompletedEstimate, error) {
+	rows, err := db.Query(`
+		SELECT t.id, t.completed_at, t.estimate_minutes, t.estimate_points
+		FROM tasks t
+		WHERE t.completed = 1 AND t.completed_at >= ?
+			AND NOT EXISTS (
+				SELECT 1 FROM tasks c
+				WHERE c.parent_id = t.id AND (c.estimate_minutes > 0 OR c.estimate_points > 0)
+			)
+		ORDER BY t.completed_at
+	`, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var done []CompletedEstimate
+	for rows.Next() {
+		var c CompletedEstimate
+		var completedAt int64
+		if err := rows.Scan(&c.TaskID, &completedAt, &c.Estimate.Minutes, &c.Estimate.Points); err != nil {
+			return nil, err
+		}
+		c.CompletedAt = time.Unix(completedAt, 0)
+		done = append(done, c)
+	}
+	return done, rows.Err()
+}
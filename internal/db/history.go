@@ -0,0 +1,71 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+// The kinds of input history is kept for.
+const (
+	HistoryCommand = "command"
+	HistorySearch  = "search"
+	HistoryTask    = "task"
+)
+
+// historyLimit is how many lines of each kind are kept.
+const historyLimit = 500
+
+// AddHistory records a line typed into the input of the given kind. A line
+// that is already there moves to the end instead of being repeated, and
+// the oldest lines beyond historyLimit are dropped.
+func (db *DB) AddHistory(kind, line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM history WHERE kind = ? AND line = ?", kind, line); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO history (kind, line, used_at) VALUES (?, ?, ?)", kind, line, time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM history WHERE kind = ? AND id NOT IN (
+		SELECT id FROM history WHERE kind = ? ORDER BY id DESC LIMIT ?)`, kind, kind, historyLimit)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// History returns the lines of the given kind, oldest first.
+func (db *DB) History(kind string) ([]string, error) {
+	rows, err := db.Query("SELECT line FROM history WHERE kind = ? ORDER BY id", kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// ClearHistory forgets the lines of the given kind, or of every kind when
+// kind is empty, and returns how many were removed.
+func (db *DB) ClearHistory(kind string) (int64, error) {
+	res, err := db.Exec("DELETE FROM history WHERE ? = '' OR kind = ?", kind, kind)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/appgram/td/internal/model"
+)
+
+// ImportTask is a task from another app, identified by the UID it had there.
+type ImportTask struct {
+	UID       string
+	ParentUID string
+	Title     string
+	Notes     string
+	Tags      []string
+	DueDate   string
+	Priority  int
+	Status    model.Status
+	Workspace string // workspace name to prefer for new tasks, if it exists
+	// TaskUUID and TaskID name the td task the UID was exported from, so
+	// it is updated rather than duplicated. Either may be empty.
+	TaskUUID string
+	TaskID   int64
+}
+
+// ImportResult counts what ImportTasks did.
+type ImportResult struct {
+	Added, Updated, Skipped int
+}
+
+// ImportTasks adds tasks, or updates the ones imported or exported under the
+// same UID before, and then nests each under its parent when both are in
+// the same workspace. New tasks go into workspace (found or created by
+// name) when it is given, else into the workspace they name, else the
+// first. It all happens in one transaction, so a failure imports nothing.
+func (db *DB) ImportTasks(tasks []ImportTask, workspace string) (ImportResult, error) {
+	var result ImportResult
+	target, err := db.ResolveWorkspace(workspace)
+	if workspace == "" || errors.Is(err, ErrNotFound) {
+		target, err = 0, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	workspaces, fallback, err := importWorkspaces(tx)
+	if err != nil {
+		return result, err
+	}
+	if workspace != "" && target == 0 {
+		if target, err = insertWorkspace(tx, workspace); err != nil {
+			return result, err
+		}
+	} else if workspace == "" && fallback == 0 {
+		if fallback, err = insertWorkspace(tx, "Default"); err != nil {
+			return result, err
+		}
+	}
+	workspaceFor := func(t ImportTask) int64 {
+		if target != 0 {
+			return target
+		}
+		if id, ok := workspaces[strings.ToLower(t.Workspace)]; ok {
+			return id
+		}
+		return fallback
+	}
+
+	byUID := map[string]int64{}
+	for _, t := range tasks {
+		if t.Title == "" {
+			result.Skipped++
+			continue
+		}
+		status := t.Status
+		if status == "" {
+			status = model.StatusOpen
+		}
+		id, err := importedTask(tx, t)
+		if err != nil {
+			return result, err
+		}
+		if id != 0 {
+			if _, err := tx.Exec(`UPDATE tasks SET title = ?, notes = ?, due_date = ?, priority = ?, status = ?, completed = ?
+				WHERE id = ?`, t.Title, nullIfEmpty(t.Notes), nullIfEmpty(t.DueDate), t.Priority, status,
+				boolToInt(status == model.StatusDone), id); err != nil {
+				return result, err
+			}
+			result.Updated++
+		} else {
+			wsID := workspaceFor(t)
+			order, err := nextTaskOrder(tx, wsID, nil)
+			if err != nil {
+				return result, err
+			}
+			res, err := tx.Exec(`INSERT INTO tasks (uuid, workspace_id, title, task_order, due_date, priority, status, completed,
+				notes, external_uid) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				uuid.NewString(), wsID, t.Title, order, nullIfEmpty(t.DueDate), t.Priority, status,
+				boolToInt(status == model.StatusDone), nullIfEmpty(t.Notes), nullIfEmpty(t.UID))
+			if err != nil {
+				return result, err
+			}
+			if id, err = res.LastInsertId(); err != nil {
+				return result, err
+			}
+			result.Added++
+		}
+		if err := setTaskTags(tx, id, t.Tags); err != nil {
+			return result, err
+		}
+		if t.UID != "" {
+			byUID[t.UID] = id
+		}
+	}
+
+	for _, t := range tasks {
+		id, ok := byUID[t.UID]
+		parentID, hasParent := byUID[t.ParentUID]
+		if !ok || t.ParentUID == "" || !hasParent || parentID == id {
+			continue
+		}
+		if err := importParent(tx, id, parentID); err != nil {
+			return result, err
+		}
+	}
+	return result, tx.Commit()
+}
+
+// importWorkspaces maps lowercased workspace names to IDs and returns the
+// first workspace, or 0 when there is none.
+func importWorkspaces(tx *sql.Tx) (map[string]int64, int64, error) {
+	rows, err := tx.Query("SELECT id, name FROM workspaces ORDER BY word_order")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	workspaces := map[string]int64{}
+	var first int64
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, 0, err
+		}
+		if first == 0 {
+			first = id
+		}
+		if _, ok := workspaces[strings.ToLower(name)]; !ok {
+			workspaces[strings.ToLower(name)] = id
+		}
+	}
+	return workspaces, first, rows.Err()
+}
+
+func insertWorkspace(tx *sql.Tx, name string) (int64, error) {
+	res, err := tx.Exec("INSERT INTO workspaces (uuid, name, word_order) SELECT ?, ?, COALESCE(MAX(word_order), -1) + 1 FROM workspaces",
+		uuid.NewString(), name)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// importedTask finds the task t was imported as before, or the td task it
+// was exported from, or returns 0 when it is new.
+func importedTask(tx *sql.Tx, t ImportTask) (int64, error) {
+	lookups := []struct {
+		query string
+		arg   interface{}
+		use   bool
+	}{
+		{"SELECT id FROM tasks WHERE external_uid = ?", t.UID, t.UID != ""},
+		{"SELECT id FROM tasks WHERE uuid = ?", t.TaskUUID, t.TaskUUID != ""},
+		{"SELECT id FROM tasks WHERE id = ?", t.TaskID, t.TaskID != 0},
+	}
+	for _, l := range lookups {
+		if !l.use {
+			continue
+		}
+		var id int64
+		err := tx.QueryRow(l.query, l.arg).Scan(&id)
+		if err == nil {
+			return id, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// importParent nests a task under its parent when both are in the same
+// workspace, it isn't there already and the move wouldn't create a loop.
+func importParent(tx *sql.Tx, id, parentID int64) error {
+	var wsID, parentWS int64
+	var current sql.NullInt64
+	if err := tx.QueryRow("SELECT workspace_id, parent_id FROM tasks WHERE id = ?", id).Scan(&wsID, &current); err != nil {
+		return err
+	}
+	if err := tx.QueryRow("SELECT workspace_id FROM tasks WHERE id = ?", parentID).Scan(&parentWS); err != nil {
+		return err
+	}
+	if parentWS != wsID || (current.Valid && current.Int64 == parentID) {
+		return nil
+	}
+	var loops int
+	err := tx.QueryRow(`
+		WITH RECURSIVE up(id) AS (
+			SELECT ?
+			UNION
+			SELECT t.parent_id FROM tasks t JOIN up ON t.id = up.id WHERE t.parent_id IS NOT NULL
+		)
+		SELECT COUNT(*) FROM up WHERE id = ?
+	`, parentID, id).Scan(&loops)
+	if err != nil || loops > 0 {
+		return err
+	}
+	order, err := nextTaskOrder(tx, wsID, &parentID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE tasks SET parent_id = ?, task_order = ? WHERE id = ?", parentID, order, id)
+	return err
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/appgram/td/internal/model"
+)
+
+func TestImportTasks(t *testing.T) {
+	database := newTestDB(t)
+	home, err := database.CreateWorkspace("Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine, err := database.AddTask(home, "Exported from here", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := database.GetTask(mine)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := []ImportTask{
+		{UID: "parent@other", Title: "Plan trip", Tags: []string{"travel"}},
+		{UID: "child@other", ParentUID: "parent@other", Title: "Book flights", DueDate: "2026-05-01", Status: model.StatusDone},
+		{UID: exported.UUID + "@td", TaskUUID: exported.UUID, Title: "Renamed elsewhere"},
+		{UID: "task-1@td", Title: "Legacy UID without --legacy-ids"},
+		{UID: "empty@other"},
+	}
+	result, err := database.ImportTasks(tasks, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportResult{Added: 3, Updated: 1, Skipped: 1}); result != want {
+		t.Errorf("first import: %+v, want %+v", result, want)
+	}
+
+	// Importing the same todos again updates them in place.
+	tasks[1].Title = "Book cheaper flights"
+	result, err = database.ImportTasks(tasks, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportResult{Updated: 4, Skipped: 1}); result != want {
+		t.Errorf("second import: %+v, want %+v", result, want)
+	}
+
+	all, err := database.GetTasksForWorkspace(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTitle := map[string]*model.Task{}
+	var walk func([]*model.Task)
+	walk = func(tasks []*model.Task) {
+		for _, task := range tasks {
+			byTitle[task.Title] = task
+			walk(task.Children)
+		}
+	}
+	walk(all)
+	if len(byTitle) != 4 {
+		t.Errorf("workspace has %d tasks, want 4: %v", len(byTitle), byTitle)
+	}
+	if task := byTitle["Renamed elsewhere"]; task == nil || task.ID != mine {
+		t.Errorf("the exported task was not updated in place")
+	}
+	child, parent := byTitle["Book cheaper flights"], byTitle["Plan trip"]
+	if child == nil || parent == nil || child.ParentID == nil || *child.ParentID != parent.ID {
+		t.Fatalf("the subtask is not nested under its parent")
+	}
+	if !child.Completed || child.DueDate != "2026-05-01" {
+		t.Errorf("subtask: completed %v, due %q", child.Completed, child.DueDate)
+	}
+	if len(parent.Tags) != 1 || parent.Tags[0] != "travel" {
+		t.Errorf("parent tags = %v, want [travel]", parent.Tags)
+	}
+
+	// With --legacy-ids a task-N@td UID updates task #N.
+	legacy := []ImportTask{{UID: "task-99@td", TaskID: mine, Title: "Legacy update"}}
+	if result, err = database.ImportTasks(legacy, "Elsewhere"); err != nil {
+		t.Fatal(err)
+	}
+	if result.Updated != 1 {
+		t.Errorf("legacy import: %+v, want one update", result)
+	}
+	if task, err := database.GetTask(mine); err != nil || task.Title != "Legacy update" {
+		t.Errorf("legacy import did not update task #%d: %v", mine, err)
+	}
+}
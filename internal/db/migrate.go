@@ -0,0 +1,380 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// migrations upgrade the baseline schema created by initSchema. Each entry
+// runs exactly once, in order; the number of applied migrations is stored in
+// PRAGMA user_version.
+var migrations = []func(tx *sql.Tx) error{
+	migrateSearchIndex,
+	migrateTaskStatus,
+	migrateBlockedPriority,
+	migrateTaskDependencies,
+	migrateTimeEntries,
+	migrateEstimates,
+	migratePomodoros,
+	migrateReminders,
+	migrateExternalUID,
+	migrateSync,
+	migrateWorkspaceUUID,
+	migrateTags,
+	migrateHistory,
+}
+
+// migrate applies pending migrations one transaction at a time. The version
+// is re-read inside each transaction so that when several td processes
+// start at once, only the first applies a migration and the others skip it.
+func migrate(db *sql.DB) error {
+	for {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		var version int
+		if err := tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to read schema version: %v", err)
+		}
+		if version >= len(migrations) {
+			return tx.Rollback()
+		}
+		if err := migrations[version](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %v", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %v", version+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %v", version+1, err)
+		}
+	}
+}
+
+func execAll(tx *sql.Tx, queries ...string) error {
+	for _, q := range queries {
+		if _, err := tx.Exec(q); err != nil {
+			return fmt.Errorf("failed to execute: %v", err)
+		}
+	}
+	return nil
+}
+
+// migrateSearchIndex adds task notes and an FTS5 index over titles, tags and
+// notes. The index uses tasks as its external content table and is kept in
+// sync by triggers.
+func migrateSearchIndex(tx *sql.Tx) error {
+	return execAll(tx,
+		`ALTER TABLE tasks ADD COLUMN notes TEXT`,
+		`CREATE VIRTUAL TABLE tasks_fts USING fts5(
+			title, tags, notes,
+			content='tasks', content_rowid='id'
+		)`,
+		`CREATE TRIGGER tasks_fts_insert AFTER INSERT ON tasks BEGIN
+			INSERT INTO tasks_fts(rowid, title, tags, notes)
+			VALUES (new.id, new.title, COALESCE(new.tags, ''), COALESCE(new.notes, ''));
+		END`,
+		`CREATE TRIGGER tasks_fts_delete AFTER DELETE ON tasks BEGIN
+			INSERT INTO tasks_fts(tasks_fts, rowid, title, tags, notes)
+			VALUES ('delete', old.id, old.title, COALESCE(old.tags, ''), COALESCE(old.notes, ''));
+		END`,
+		`CREATE TRIGGER tasks_fts_update AFTER UPDATE OF title, tags, notes ON tasks BEGIN
+			INSERT INTO tasks_fts(tasks_fts, rowid, title, tags, notes)
+			VALUES ('delete', old.id, old.title, COALESCE(old.tags, ''), COALESCE(old.notes, ''));
+			INSERT INTO tasks_fts(rowid, title, tags, notes)
+			VALUES (new.id, new.title, COALESCE(new.tags, ''), COALESCE(new.notes, ''));
+		END`,
+		`INSERT INTO tasks_fts(tasks_fts) VALUES ('rebuild')`,
+	)
+}
+
+// migrateTaskStatus adds an explicit workflow status. Completed tasks start
+// out as done; everything else is open.
+func migrateTaskStatus(tx *sql.Tx) error {
+	return execAll(tx,
+		`ALTER TABLE tasks ADD COLUMN status TEXT NOT NULL DEFAULT 'open'`,
+		`UPDATE tasks SET status = 'done' WHERE completed = 1`,
+	)
+}
+
+// migrateBlockedPriority moves the old "priority = -1 means blocked" encoding
+// into the status column and gives those tasks back a normal priority.
+func migrateBlockedPriority(tx *sql.Tx) error {
+	return execAll(tx,
+		`UPDATE tasks SET status = 'blocked' WHERE priority < 0 AND completed = 0`,
+		`UPDATE tasks SET priority = 0 WHERE priority < 0`,
+	)
+}
+
+// migrateTaskDependencies adds the prerequisite graph between tasks. Foreign
+// keys aren't enforced, so a trigger drops edges when either task goes away.
+func migrateTaskDependencies(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE task_deps (
+			task_id INTEGER NOT NULL,
+			depends_on_id INTEGER NOT NULL,
+			PRIMARY KEY (task_id, depends_on_id),
+			FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+			FOREIGN KEY (depends_on_id) REFERENCES tasks(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX idx_task_deps_depends_on ON task_deps(depends_on_id)`,
+		`CREATE TRIGGER task_deps_cleanup AFTER DELETE ON tasks BEGIN
+			DELETE FROM task_deps WHERE task_id = old.id OR depends_on_id = old.id;
+		END`,
+	)
+}
+
+// migrateTimeEntries adds tracked time. Times are Unix seconds; the entry of
+// the running timer has no end yet.
+func migrateTimeEntries(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE time_entries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			started_at INTEGER NOT NULL,
+			ended_at INTEGER,
+			FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX idx_time_entries_task ON time_entries(task_id)`,
+		`CREATE INDEX idx_time_entries_started ON time_entries(started_at)`,
+		`CREATE TRIGGER time_entries_cleanup AFTER DELETE ON tasks BEGIN
+			DELETE FROM time_entries WHERE task_id = old.id;
+		END`,
+	)
+}
+
+// migrateEstimates adds effort estimates and records when tasks get
+// completed, so estimated effort can be compared per week. Triggers keep
+// completed_at in step with every path that flips completed.
+func migrateEstimates(tx *sql.Tx) error {
+	return execAll(tx,
+		`ALTER TABLE tasks ADD COLUMN estimate_minutes INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN estimate_points REAL NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN completed_at INTEGER`,
+		`CREATE TRIGGER tasks_completed_insert AFTER INSERT ON tasks WHEN new.completed = 1 BEGIN
+			UPDATE tasks SET completed_at = CAST(strftime('%s', 'now') AS INTEGER) WHERE id = new.id;
+		END`,
+		`CREATE TRIGGER tasks_completed_update AFTER UPDATE OF completed ON tasks WHEN new.completed != old.completed BEGIN
+			UPDATE tasks SET completed_at = CASE WHEN new.completed = 1 THEN CAST(strftime('%s', 'now') AS INTEGER) END
+			WHERE id = new.id;
+		END`,
+	)
+}
+
+// migratePomodoros adds the log of completed focus intervals.
+func migratePomodoros(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE pomodoros (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			started_at INTEGER NOT NULL,
+			ended_at INTEGER NOT NULL,
+			FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX idx_pomodoros_task ON pomodoros(task_id)`,
+		`CREATE TRIGGER pomodoros_cleanup AFTER DELETE ON tasks BEGIN
+			DELETE FROM pomodoros WHERE task_id = old.id;
+		END`,
+	)
+}
+
+// migrateReminders adds per-task reminders. A reminder either has an absolute
+// remind_at or fires before_minutes ahead of the due date; moving the due date
+// re-arms relative reminders.
+func migrateReminders(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE reminders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			remind_at INTEGER,
+			before_minutes INTEGER,
+			fired_at INTEGER,
+			FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX idx_reminders_task ON reminders(task_id)`,
+		`CREATE TRIGGER reminders_cleanup AFTER DELETE ON tasks BEGIN
+			DELETE FROM reminders WHERE task_id = old.id;
+		END`,
+		`CREATE TRIGGER reminders_rearm AFTER UPDATE OF due_date ON tasks
+		WHEN COALESCE(new.due_date, '') != COALESCE(old.due_date, '') BEGIN
+			UPDATE reminders SET fired_at = NULL WHERE task_id = new.id AND before_minutes IS NOT NULL;
+		END`,
+	)
+}
+
+// migrateExternalUID remembers the UID of tasks imported from other apps so
+// importing the same file again updates them instead of adding duplicates.
+func migrateExternalUID(tx *sql.Tx) error {
+	return execAll(tx,
+		`ALTER TABLE tasks ADD COLUMN external_uid TEXT`,
+		`CREATE UNIQUE INDEX idx_tasks_external_uid ON tasks(external_uid) WHERE external_uid IS NOT NULL`,
+	)
+}
+
+// nowMillis is the current time in Unix milliseconds, for use in SQL.
+const nowMillis = `CAST((julianday('now') - 2440587.5) * 86400000 AS INTEGER)`
+
+// migrateSync gives every task a UUID and records, per synced field, when it
+// last changed and on which machine (NULL for this one), plus a tombstone
+// for each deleted task. td sync merges on these.
+func migrateSync(tx *sql.Tx) error {
+	if err := execAll(tx,
+		`ALTER TABLE tasks ADD COLUMN uuid TEXT`,
+		`CREATE TABLE task_clock (
+			task_id INTEGER NOT NULL,
+			field TEXT NOT NULL,
+			updated_at INTEGER NOT NULL,
+			machine TEXT,
+			PRIMARY KEY (task_id, field)
+		)`,
+		`CREATE TABLE task_tombstones (
+			uuid TEXT PRIMARY KEY,
+			deleted_at INTEGER NOT NULL,
+			machine TEXT
+		)`,
+		`CREATE TRIGGER task_clock_update AFTER UPDATE ON tasks BEGIN
+			INSERT OR REPLACE INTO task_clock (task_id, field, updated_at, machine)
+			SELECT new.id, field, `+nowMillis+`, NULL FROM (
+				SELECT 'title' AS field WHERE old.title IS NOT new.title
+				UNION ALL SELECT 'notes' WHERE COALESCE(old.notes, '') != COALESCE(new.notes, '')
+				UNION ALL SELECT 'tags' WHERE COALESCE(old.tags, '') != COALESCE(new.tags, '')
+				UNION ALL SELECT 'due_date' WHERE COALESCE(old.due_date, '') != COALESCE(new.due_date, '')
+				UNION ALL SELECT 'priority' WHERE old.priority IS NOT new.priority
+				UNION ALL SELECT 'status' WHERE old.status IS NOT new.status OR old.completed IS NOT new.completed
+				UNION ALL SELECT 'estimate' WHERE old.estimate_minutes IS NOT new.estimate_minutes
+					OR old.estimate_points IS NOT new.estimate_points
+				UNION ALL SELECT 'parent' WHERE old.parent_id IS NOT new.parent_id
+				UNION ALL SELECT 'workspace' WHERE old.workspace_id IS NOT new.workspace_id
+			);
+		END`,
+		`CREATE TRIGGER task_tombstone AFTER DELETE ON tasks WHEN old.uuid IS NOT NULL BEGIN
+			INSERT OR REPLACE INTO task_tombstones (uuid, deleted_at, machine) VALUES (old.uuid, `+nowMillis+`, NULL);
+			DELETE FROM task_clock WHERE task_id = old.id;
+		END`,
+	); err != nil {
+		return err
+	}
+
+	if err := fillUUIDs(tx, "tasks"); err != nil {
+		return err
+	}
+	return execAll(tx, `CREATE UNIQUE INDEX idx_tasks_uuid ON tasks(uuid)`)
+}
+
+// migrateWorkspaceUUID gives workspaces a UUID too, so sync and API clients
+// can refer to them across renames and machines.
+func migrateWorkspaceUUID(tx *sql.Tx) error {
+	if err := execAll(tx, `ALTER TABLE workspaces ADD COLUMN uuid TEXT`); err != nil {
+		return err
+	}
+	if err := fillUUIDs(tx, "workspaces"); err != nil {
+		return err
+	}
+	return execAll(tx, `CREATE UNIQUE INDEX idx_workspaces_uuid ON workspaces(uuid)`)
+}
+
+// fillUUIDs assigns a fresh UUID to every row of table that lacks one.
+func fillUUIDs(tx *sql.Tx, table string) error {
+	rows, err := tx.Query("SELECT id FROM " + table + " WHERE uuid IS NULL")
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	for _, id := range ids {
+		if _, err := tx.Exec("UPDATE "+table+" SET uuid = ? WHERE id = ?", uuid.NewString(), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateTags moves tags out of the comma-joined tasks.tags column into a
+// tags table and a task_tags join table. tasks.tags stays as a copy for the
+// search index and the sync clocks, now separated by tagSep; the tag clocks
+// are put back afterwards so the rewrite doesn't count as an edit.
+func migrateTags(tx *sql.Tx) error {
+	err := execAll(tx,
+		`CREATE TABLE tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			color TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE task_tags (
+			task_id INTEGER NOT NULL,
+			tag_id INTEGER NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (task_id, tag_id)
+		)`,
+		`CREATE INDEX idx_task_tags_tag ON task_tags(tag_id)`,
+		`CREATE TRIGGER task_tags_cleanup AFTER DELETE ON tasks BEGIN
+			DELETE FROM task_tags WHERE task_id = old.id;
+		END`,
+		`CREATE TEMP TABLE saved_tag_clock AS SELECT * FROM task_clock WHERE field = 'tags'`,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id, tags FROM tasks WHERE COALESCE(tags, '') != ''")
+	if err != nil {
+		return err
+	}
+	legacy := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var tags string
+		if err := rows.Scan(&id, &tags); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy[id] = tags
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, tags := range legacy {
+		if _, err := tx.Exec("UPDATE tasks SET tags = NULL WHERE id = ?", id); err != nil {
+			return err
+		}
+		if err := setTaskTags(tx, id, strings.Split(tags, ",")); err != nil {
+			return err
+		}
+	}
+
+	return execAll(tx,
+		`DELETE FROM task_clock WHERE field = 'tags'`,
+		`INSERT INTO task_clock SELECT * FROM saved_tag_clock`,
+		`DROP TABLE saved_tag_clock`,
+	)
+}
+
+// migrateHistory keeps the lines typed into the TUI's command line, search
+// and task input so they can be recalled in later sessions.
+func migrateHistory(tx *sql.Tx) error {
+	return execAll(tx,
+		`CREATE TABLE history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind TEXT NOT NULL,
+			line TEXT NOT NULL,
+			used_at INTEGER NOT NULL
+		)`,
+		`CREATE UNIQUE INDEX idx_history_line ON history(kind, line)`,
+	)
+}
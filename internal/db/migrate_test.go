@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/appgram/td/internal/model"
+)
+
+// legacyDB creates a database with only the baseline schema, as the first
+// release of td left it, runs the statements in it and returns its path.
+func legacyDB(t *testing.T, statements ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "td.db")
+	raw, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if err := initSchema(raw); err != nil {
+		t.Fatal(err)
+	}
+	statements = append([]string{`INSERT INTO workspaces (id, name) VALUES (1, 'Home')`}, statements...)
+	for _, s := range statements {
+		if _, err := raw.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	return path
+}
+
+// openLegacy migrates a database made by legacyDB.
+func openLegacy(t *testing.T, path string) *DB {
+	t.Helper()
+	database, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestMigrateLegacyTags(t *testing.T) {
+	path := legacyDB(t,
+		`INSERT INTO tasks (id, workspace_id, title, tags) VALUES
+			(1, 1, 'Plain', 'work,urgent'),
+			(2, 1, 'Messy', ' home , #errand,,home'),
+			(3, 1, 'Single', 'work'),
+			(4, 1, 'Empty', ''),
+			(5, 1, 'Null', NULL)`,
+	)
+	database := openLegacy(t, path)
+
+	tests := []struct {
+		id   int64
+		want []string
+	}{
+		{1, []string{"work", "urgent"}},
+		{2, []string{"home", "errand"}},
+		{3, []string{"work"}},
+		{4, nil},
+		{5, nil},
+	}
+	for _, tt := range tests {
+		task, err := database.GetTask(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(task.Tags) != len(tt.want) || len(tt.want) > 0 && !reflect.DeepEqual(task.Tags, tt.want) {
+			t.Errorf("task %d (%s): tags %q, want %q", tt.id, task.Title, task.Tags, tt.want)
+		}
+		var stored sql.NullString
+		if err := database.QueryRow("SELECT tags FROM tasks WHERE id = ?", tt.id).Scan(&stored); err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.Join(tt.want, tagSep); stored.String != want {
+			t.Errorf("task %d: tasks.tags = %q, want %q", tt.id, stored.String, want)
+		}
+	}
+
+	tags, err := database.GetTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int{}
+	for _, tag := range tags {
+		got[tag.Name] = tag.Total
+	}
+	if want := map[string]int{"errand": 1, "home": 1, "urgent": 1, "work": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tags = %v, want %v", got, want)
+	}
+
+	backups, err := database.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || !strings.Contains(filepath.Base(backups[0]), "before-v") {
+		t.Errorf("backups = %q, want the snapshot taken before migrating", backups)
+	}
+}
+
+func TestMigrateTaskStatus(t *testing.T) {
+	path := legacyDB(t,
+		`INSERT INTO tasks (id, workspace_id, title, completed, priority) VALUES
+			(1, 1, 'Open', 0, 0),
+			(2, 1, 'High', 0, 2),
+			(3, 1, 'Done', 1, 1),
+			(4, 1, 'Blocked', 0, -1),
+			(5, 1, 'Blocked then done', 1, -1)`,
+	)
+	database := openLegacy(t, path)
+
+	tests := []struct {
+		id       int64
+		status   model.Status
+		priority int
+	}{
+		{1, model.StatusOpen, 0},
+		{2, model.StatusOpen, 2},
+		{3, model.StatusDone, 1},
+		{4, model.StatusBlocked, 0},
+		{5, model.StatusDone, 0},
+	}
+	for _, tt := range tests {
+		task, err := database.GetTask(tt.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.Status != tt.status || task.Priority != tt.priority {
+			t.Errorf("task %d (%s): status %q, priority %d; want %q, %d",
+				tt.id, task.Title, task.Status, task.Priority, tt.status, tt.priority)
+		}
+	}
+}
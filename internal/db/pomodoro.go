@@ -0,0 +1,17 @@
+package db
+
+import "time"
+
+// AddPomodoro records a completed focus interval on a task.
+func (db *DB) AddPomodoro(taskID int64, start, end time.Time) error {
+	_, err := db.Exec("INSERT INTO pomodoros (task_id, started_at, ended_at) VALUES (?, ?, ?)",
+		taskID, start.Unix(), end.Unix())
+	return err
+}
+
+// CountPomodoros returns how many focus intervals were completed on a task.
+func (db *DB) CountPomodoros(taskID int64) (int, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM pomodoros WHERE task_id = ?", taskID).Scan(&n)
+	return n, err
+}
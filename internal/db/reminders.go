@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/appgram/td/internal/model"
+)
+
+// PendingReminder is an unfired reminder on an open task, with the task
+// details needed to announce it.
+type PendingReminder struct {
+	model.Reminder
+	TaskID        int64
+	Title         string
+	DueDate       string
+	WorkspaceName string
+	FireAt        time.Time
+}
+
+func (db *DB) AddReminder(taskID int64, r model.Reminder) error {
+	var at, before interface{}
+	if r.Relative() {
+		before = int64(r.Before / time.Minute)
+	} else {
+		at = r.At.Unix()
+	}
+	_, err := db.Exec("INSERT INTO reminders (task_id, remind_at, before_minutes) VALUES (?, ?, ?)", taskID, at, before)
+	return err
+}
+
+func (db *DB) ClearReminders(taskID int64) error {
+	_, err := db.Exec("DELETE FROM reminders WHERE task_id = ?", taskID)
+	return err
+}
+
+// MarkReminderFired claims a reminder for announcing. It reports false when
+// another notifier already fired it.
+func (db *DB) MarkReminderFired(id int64, at time.Time) (bool, error) {
+	res, err := db.Exec("UPDATE reminders SET fired_at = ? WHERE id = ? AND fired_at IS NULL", at.Unix(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func scanReminder(r *model.Reminder, at, before, fired sql.NullInt64) {
+	if at.Valid {
+		r.At = time.Unix(at.Int64, 0)
+	}
+	if before.Valid {
+		r.Before = time.Duration(before.Int64) * time.Minute
+	}
+	if fired.Valid {
+		r.FiredAt = time.Unix(fired.Int64, 0)
+	}
+}
+
+// loadReminders fills Reminders for the tasks of a workspace.
+func (db *DB) loadReminders(workspaceID int64, tasks map[int64]*model.Task) error {
+	rows, err := db.Query(`
+		SELECT r.id, r.task_id, r.remind_at, r.before_minutes, r.fired_at
+		FROM reminders r JOIN tasks t ON t.id = r.task_id
+		WHERE t.workspace_id = ?
+		ORDER BY r.id
+	`, workspaceID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r model.Reminder
+		var taskID int64
+		var at, before, fired sql.NullInt64
+		if err := rows.Scan(&r.ID, &taskID, &at, &before, &fired); err != nil {
+			return err
+		}
+		scanReminder(&r, at, before, fired)
+		if t, ok := tasks[taskID]; ok {
+			t.Reminders = append(t.Reminders, r)
+		}
+	}
+	return rows.Err()
+}
+
+// GetPendingReminders returns the unfired reminders of open tasks that can
+// fire, soonest first. Relative reminders on tasks without a due date are left
+// out.
+func (db *DB) GetPendingReminders() ([]PendingReminder, error) {
+	rows, err := db.Query(`
+		SELECT r.id, r.remind_at, r.before_minutes, r.fired_at,
+			t.id, t.title, COALESCE(t.due_date, ''), w.name
+		FROM reminders r
+		JOIN tasks t ON t.id = r.task_id
+		JOIN workspaces w ON w.id = t.workspace_id
+		WHERE r.fired_at IS NULL AND t.completed = 0 AND t.status NOT IN ('done', 'cancelled')
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []PendingReminder
+	for rows.Next() {
+		var p PendingReminder
+		var at, before, fired sql.NullInt64
+		if err := rows.Scan(&p.ID, &at, &before, &fired, &p.TaskID, &p.Title, &p.DueDate, &p.WorkspaceName); err != nil {
+			return nil, err
+		}
+		scanReminder(&p.Reminder, at, before, fired)
+		fireAt, ok := p.FireTime(p.DueDate)
+		if !ok {
+			continue
+		}
+		p.FireAt = fireAt
+		pending = append(pending, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].FireAt.Before(pending[j].FireAt) })
+	return pending, nil
+}
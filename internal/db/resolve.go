@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrAmbiguous is returned when a UUID prefix matches more than one task or
+// workspace.
+var ErrAmbiguous = errors.New("ambiguous reference")
+
+// ErrNotFound matches the error returned when a reference names no task or
+// workspace.
+var ErrNotFound = errors.New("not found")
+
+// notFound is a not-found error with its own message.
+type notFound string
+
+func (e notFound) Error() string        { return string(e) }
+func (e notFound) Is(target error) bool { return target == ErrNotFound }
+
+// minPrefix is the shortest UUID prefix accepted, so that a couple of
+// letters typed by accident don't select something.
+const minPrefix = 4
+
+// minNumericPrefix is the shortest all-digit UUID prefix accepted. Shorter
+// numbers are only ever task IDs, so a mistyped or deleted ID doesn't pick
+// an unrelated task whose UUID happens to start with it.
+const minNumericPrefix = 8
+
+// ResolveTask finds a task by reference: its numeric ID ("42", "#42" or
+// "~42"), or its UUID or a unique prefix of it. A bare number of at least
+// minNumericPrefix digits that is no task's ID is tried as a UUID prefix,
+// since prefixes can be all digits.
+func (db *DB) ResolveTask(ref string) (int64, error) {
+	ref = strings.TrimSpace(ref)
+	bare := strings.TrimLeft(ref, "#~")
+	if id, err := strconv.ParseInt(bare, 10, 64); err == nil && id > 0 {
+		var exists int
+		if err := db.QueryRow("SELECT COUNT(*) FROM tasks WHERE id = ?", id).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if exists > 0 {
+			return id, nil
+		}
+		if bare != ref || len(bare) < minNumericPrefix {
+			return 0, notFound(fmt.Sprintf("task #%d not found", id))
+		}
+		uuidID, err := db.resolveUUID("tasks", "task", bare)
+		if errors.Is(err, ErrNotFound) {
+			return 0, notFound(fmt.Sprintf("task #%d not found", id))
+		}
+		return uuidID, err
+	}
+	return db.resolveUUID("tasks", "task", bare)
+}
+
+// ResolveWorkspace finds a workspace by name (case-insensitive, with dashes
+// standing in for spaces), or by its UUID or a unique prefix of it.
+func (db *DB) ResolveWorkspace(ref string) (int64, error) {
+	ref = strings.TrimSpace(ref)
+	var id int64
+	err := db.QueryRow(`SELECT id FROM workspaces
+		WHERE name = ? COLLATE NOCASE OR replace(name, ' ', '-') = ? COLLATE NOCASE
+		ORDER BY name = ? COLLATE NOCASE DESC, word_order LIMIT 1`, ref, ref, ref).Scan(&id)
+	if err != sql.ErrNoRows {
+		return id, err
+	}
+	return db.resolveUUID("workspaces", "workspace", ref)
+}
+
+// IsWorkspaceRef reports whether ref names a workspace for ResolveWorkspace.
+// An ambiguous UUID prefix counts, so the caller gets to report it.
+func (db *DB) IsWorkspaceRef(ref string) bool {
+	_, err := db.ResolveWorkspace(ref)
+	return err == nil || errors.Is(err, ErrAmbiguous)
+}
+
+// IsTaskRef reports whether ref names a task for ResolveTask. An ambiguous
+// UUID prefix counts, so the caller gets to report it.
+func (db *DB) IsTaskRef(ref string) bool {
+	_, err := db.ResolveTask(ref)
+	return err == nil || errors.Is(err, ErrAmbiguous)
+}
+
+func (db *DB) resolveUUID(table, noun, ref string) (int64, error) {
+	prefix := strings.ToLower(ref)
+	if len(prefix) < minPrefix || strings.Trim(prefix, "0123456789abcdef-") != "" {
+		return 0, notFound(fmt.Sprintf("%s %q not found", noun, ref))
+	}
+	rows, err := db.Query("SELECT id FROM "+table+" WHERE uuid >= ? AND uuid < ? LIMIT 2", prefix, prefix+"\xff")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	switch len(ids) {
+	case 0:
+		return 0, notFound(fmt.Sprintf("%s %q not found", noun, ref))
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, fmt.Errorf("%s %q: %w", noun, ref, ErrAmbiguous)
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestDB opens a fresh database in a temporary directory.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	database, err := Open(filepath.Join(t.TempDir(), "td.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestResolveTask(t *testing.T) {
+	database := newTestDB(t)
+	wsID, err := database.CreateWorkspace("Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuids := map[string]string{
+		"digits":  "12345678-0000-4000-8000-000000000000",
+		"cafe":    "cafe0000-0000-4000-8000-000000000000",
+		"shared1": "beef1111-0000-4000-8000-000000000000",
+		"shared2": "beef2222-0000-4000-8000-000000000000",
+	}
+	ids := map[string]int64{}
+	for _, name := range []string{"digits", "cafe", "shared1", "shared2"} {
+		id, err := database.AddTask(wsID, name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.Exec("UPDATE tasks SET uuid = ? WHERE id = ?", uuids[name], id); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = id
+	}
+
+	tests := []struct {
+		ref     string
+		want    string // name of the task found
+		wantErr string
+	}{
+		{ref: "1", want: "digits"},
+		{ref: "#2", want: "cafe"},
+		{ref: "~3", want: "shared1"},
+		{ref: " 4 ", want: "shared2"},
+		{ref: "cafe", want: "cafe"},
+		{ref: "~cafe", want: "cafe"},
+		{ref: "CAFE0000", want: "cafe"},
+		{ref: uuids["shared2"], want: "shared2"},
+		{ref: "beef1", want: "shared1"},
+		// A bare number long enough not to be an ID is a UUID prefix.
+		{ref: "12345678", want: "digits"},
+		// A number with a sigil, or too short to be a prefix, is only an ID.
+		{ref: "1234", wantErr: "task #1234 not found"},
+		{ref: "1234567", wantErr: "task #1234567 not found"},
+		{ref: "#12345678", wantErr: "task #12345678 not found"},
+		{ref: "~12345678", wantErr: "task #12345678 not found"},
+		{ref: "99999999", wantErr: "task #99999999 not found"},
+		{ref: "beef", wantErr: ErrAmbiguous.Error()},
+		{ref: "caf", wantErr: `task "caf" not found`},
+		{ref: "coffee", wantErr: `task "coffee" not found`},
+		{ref: "", wantErr: `task "" not found`},
+	}
+	for _, tt := range tests {
+		got, err := database.ResolveTask(tt.ref)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveTask(%q) = %d, %v; want error %q", tt.ref, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != ids[tt.want] {
+			t.Errorf("ResolveTask(%q) = %d, %v; want %d (%s)", tt.ref, got, err, ids[tt.want], tt.want)
+		}
+	}
+	if _, err := database.ResolveTask("beef"); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("ResolveTask(%q) error %v is not ErrAmbiguous", "beef", err)
+	}
+	for ref, want := range map[string]bool{"cafe": true, "beef": true, "~1": true, "coffee": false, "~1234": false} {
+		if got := database.IsTaskRef(ref); got != want {
+			t.Errorf("IsTaskRef(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// SearchHit is a task matched by SearchTasks together with the context needed
+// to show and locate it: its workspace and the chain of ancestors above it.
+type SearchHit struct {
+	TaskID        int64
+	WorkspaceID   int64
+	WorkspaceName string
+	Title         string
+	Tags          []string
+	Completed     bool
+	AncestorIDs   []int64  // root first
+	Path          []string // ancestor titles, root first
+}
+
+// SearchTasks runs a full-text query against every workspace. Each word of
+// the query is matched as a prefix; hits are ordered by relevance.
+func (db *DB) SearchTasks(query string, limit int) ([]SearchHit, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	return db.queryHits(`
+		SELECT t.id, t.parent_id, t.workspace_id, w.name, t.title, COALESCE(t.tags, ''), t.completed
+		FROM tasks_fts f
+		JOIN tasks t ON t.id = f.rowid
+		JOIN workspaces w ON w.id = t.workspace_id
+		WHERE tasks_fts MATCH ?
+		ORDER BY f.rank
+		LIMIT ?
+	`, match, limit)
+}
+
+// queryHits runs a query selecting id, parent_id, workspace_id, workspace
+// name, title, tags and completed, and resolves each hit's ancestors.
+func (db *DB) queryHits(query string, args ...interface{}) ([]SearchHit, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []SearchHit
+	var parents []sql.NullInt64
+	for rows.Next() {
+		var h SearchHit
+		var parentID sql.NullInt64
+		var tags string
+		if err := rows.Scan(&h.TaskID, &parentID, &h.WorkspaceID, &h.WorkspaceName, &h.Title, &tags, &h.Completed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		h.Tags = splitTags(tags)
+		hits = append(hits, h)
+		parents = append(parents, parentID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		if err := db.fillAncestors(&hits[i], parents[i]); err != nil {
+			return nil, err
+		}
+	}
+	return hits, nil
+}
+
+func (db *DB) fillAncestors(hit *SearchHit, parentID sql.NullInt64) error {
+	// Bounded walk so a corrupted parent cycle can't loop forever.
+	for depth := 0; parentID.Valid && depth < 64; depth++ {
+		var title string
+		id := parentID.Int64
+		err := db.QueryRow("SELECT title, parent_id FROM tasks WHERE id = ?", id).Scan(&title, &parentID)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		hit.AncestorIDs = append([]int64{id}, hit.AncestorIDs...)
+		hit.Path = append([]string{title}, hit.Path...)
+	}
+	return nil
+}
+
+// ftsQuery turns free text into an FTS5 expression that matches every word as
+// a quoted prefix, so user input can never produce a syntax error.
+func ftsQuery(input string) string {
+	var terms []string
+	for _, word := range strings.Fields(input) {
+		word = strings.TrimLeft(word, "#")
+		if word == "" {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/appgram/td/internal/model"
+)
+
+// SyncFields are the task fields td sync merges, each with its own clock.
+var SyncFields = []string{"title", "notes", "tags", "due_date", "priority", "status", "estimate", "parent", "workspace"}
+
+// Clock is when a synced value last changed, in Unix milliseconds, and the
+// machine that changed it. An empty Machine means this one.
+type Clock struct {
+	At      int64
+	Machine string
+}
+
+// SyncWorkspace is how a sync record names a task's workspace.
+type SyncWorkspace struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// SyncTask is a task as td sync sees it: identified by UUID, with its parent
+// as a UUID.
+type SyncTask struct {
+	UUID       string
+	Title      string
+	Notes      string
+	Tags       []string
+	DueDate    string
+	Priority   int
+	Status     model.Status
+	Estimate   model.Estimate
+	ParentUUID string
+	Workspace  SyncWorkspace
+	Clocks     map[string]Clock // by SyncFields name
+}
+
+// GetSyncTasks returns every task with its field clocks. Fields that never
+// changed are dated to the task's creation.
+func (db *DB) GetSyncTasks() ([]SyncTask, error) {
+	clocks := map[int64]map[string]Clock{}
+	rows, err := db.Query("SELECT task_id, field, updated_at, COALESCE(machine, '') FROM task_clock")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var field string
+		var c Clock
+		if err := rows.Scan(&id, &field, &c.At, &c.Machine); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if clocks[id] == nil {
+			clocks[id] = map[string]Clock{}
+		}
+		clocks[id][field] = c
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT t.id, t.uuid, t.title, COALESCE(t.notes, ''), COALESCE(t.tags, ''), COALESCE(t.due_date, ''),
+			   t.priority, t.status, t.estimate_minutes, t.estimate_points, COALESCE(p.uuid, ''), COALESCE(w.uuid, ''), w.name, t.created_at
+		FROM tasks t
+		JOIN workspaces w ON w.id = t.workspace_id
+		LEFT JOIN tasks p ON p.id = t.parent_id
+		ORDER BY t.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []SyncTask
+	for rows.Next() {
+		var t SyncTask
+		var id int64
+		var tags, created string
+		if err := rows.Scan(&id, &t.UUID, &t.Title, &t.Notes, &tags, &t.DueDate, &t.Priority, &t.Status,
+			&t.Estimate.Minutes, &t.Estimate.Points, &t.ParentUUID, &t.Workspace.UUID, &t.Workspace.Name, &created); err != nil {
+			return nil, err
+		}
+		t.Tags = splitTags(tags)
+		t.Clocks = map[string]Clock{}
+		createdAt := parseCreatedAt(created).UnixMilli()
+		for _, f := range SyncFields {
+			c, ok := clocks[id][f]
+			if !ok {
+				c = Clock{At: createdAt}
+			}
+			t.Clocks[f] = c
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+func parseCreatedAt(s string) time.Time {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// GetTombstones returns when each deleted task was deleted, by UUID.
+func (db *DB) GetTombstones() (map[string]Clock, error) {
+	rows, err := db.Query("SELECT uuid, deleted_at, COALESCE(machine, '') FROM task_tombstones")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tombstones := map[string]Clock{}
+	for rows.Next() {
+		var id string
+		var c Clock
+		if err := rows.Scan(&id, &c.At, &c.Machine); err != nil {
+			return nil, err
+		}
+		tombstones[id] = c
+	}
+	return tombstones, rows.Err()
+}
+
+// SaveSyncTask creates or updates the task with t's UUID and takes over t's
+// clocks, so the merge result isn't mistaken for a local edit. A parent that
+// doesn't exist, or lives in another workspace, leaves the task at the top
+// level.
+func (db *DB) SaveSyncTask(t SyncTask) (created bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	wsID, err := syncWorkspace(tx, t.Workspace)
+	if err != nil {
+		return false, err
+	}
+
+	var parentID *int64
+	if t.ParentUUID != "" {
+		var id, parentWS int64
+		err := tx.QueryRow("SELECT id, workspace_id FROM tasks WHERE uuid = ?", t.ParentUUID).Scan(&id, &parentWS)
+		if err != nil && err != sql.ErrNoRows {
+			return false, err
+		}
+		if err == nil && parentWS == wsID {
+			parentID = &id
+		}
+	}
+
+	var id, oldWS int64
+	var oldParent sql.NullInt64
+	err = tx.QueryRow("SELECT id, workspace_id, parent_id FROM tasks WHERE uuid = ?", t.UUID).Scan(&id, &oldWS, &oldParent)
+	switch {
+	case err == sql.ErrNoRows:
+		created = true
+		order, err := nextTaskOrder(tx, wsID, parentID)
+		if err != nil {
+			return false, err
+		}
+		res, err := tx.Exec(`INSERT INTO tasks (uuid, workspace_id, parent_id, title, task_order, due_date, priority, status, completed,
+			notes, estimate_minutes, estimate_points) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			t.UUID, wsID, coalesceNull(parentID), t.Title, order, nullIfEmpty(t.DueDate), t.Priority, t.Status,
+			boolToInt(t.Status == model.StatusDone), nullIfEmpty(t.Notes), t.Estimate.Minutes, t.Estimate.Points)
+		if err != nil {
+			return false, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return false, err
+		}
+	case err != nil:
+		return false, err
+	default:
+		samePlace := oldWS == wsID && oldParent.Valid == (parentID != nil) && (parentID == nil || oldParent.Int64 == *parentID)
+		if !samePlace {
+			order, err := nextTaskOrder(tx, wsID, parentID)
+			if err != nil {
+				return false, err
+			}
+			if _, err := tx.Exec("UPDATE tasks SET workspace_id = ?, parent_id = ?, task_order = ? WHERE id = ?",
+				wsID, coalesceNull(parentID), order, id); err != nil {
+				return false, err
+			}
+		}
+		if _, err := tx.Exec(`UPDATE tasks SET title = ?, due_date = ?, priority = ?, status = ?, completed = ?,
+			notes = ?, estimate_minutes = ?, estimate_points = ? WHERE id = ?`,
+			t.Title, nullIfEmpty(t.DueDate), t.Priority, t.Status, boolToInt(t.Status == model.StatusDone),
+			nullIfEmpty(t.Notes), t.Estimate.Minutes, t.Estimate.Points, id); err != nil {
+			return false, err
+		}
+	}
+
+	if err := setTaskTags(tx, id, t.Tags); err != nil {
+		return false, err
+	}
+
+	for field, c := range t.Clocks {
+		if _, err := tx.Exec("INSERT OR REPLACE INTO task_clock (task_id, field, updated_at, machine) VALUES (?, ?, ?, ?)",
+			id, field, c.At, nullIfEmpty(c.Machine)); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM task_tombstones WHERE uuid = ?", t.UUID); err != nil {
+		return false, err
+	}
+	return created, tx.Commit()
+}
+
+// syncWorkspace finds the workspace a sync record refers to: by UUID, else
+// by name, else a new one with the record's UUID. Two machines that each
+// created a workspace of the same name before syncing end up sharing the
+// lower of the two UUIDs.
+func syncWorkspace(tx *sql.Tx, ws SyncWorkspace) (int64, error) {
+	var id int64
+	err := tx.QueryRow("SELECT id FROM workspaces WHERE uuid = ?", ws.UUID).Scan(&id)
+	if err != sql.ErrNoRows {
+		return id, err
+	}
+
+	var local string
+	err = tx.QueryRow("SELECT id, COALESCE(uuid, '') FROM workspaces WHERE name = ? ORDER BY word_order LIMIT 1", ws.Name).Scan(&id, &local)
+	switch {
+	case err == nil:
+		if ws.UUID != "" && ws.UUID < local {
+			_, err = tx.Exec("UPDATE workspaces SET uuid = ? WHERE id = ?", ws.UUID, id)
+		}
+		return id, err
+	case err != sql.ErrNoRows:
+		return 0, err
+	}
+
+	if ws.UUID == "" {
+		ws.UUID = uuid.NewString()
+	}
+	res, err := tx.Exec("INSERT INTO workspaces (uuid, name, word_order) SELECT ?, ?, COALESCE(MAX(word_order), -1) + 1 FROM workspaces",
+		ws.UUID, ws.Name)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// DeleteSyncedTask deletes the task with the given UUID and dates its
+// tombstone to c. Its subtasks move to the top level; whether they survive
+// is up to their own sync records.
+func (db *DB) DeleteSyncedTask(uuid string, c Clock) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow("SELECT id FROM tasks WHERE uuid = ?", uuid).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		if _, err := tx.Exec("UPDATE tasks SET parent_id = NULL WHERE parent_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM tasks WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("INSERT OR REPLACE INTO task_tombstones (uuid, deleted_at, machine) VALUES (?, ?, ?)",
+		uuid, c.At, nullIfEmpty(c.Machine)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,244 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// tagSep separates the tags in tasks.tags, the copy of a task's tags kept
+// for the search index and sync clocks. Unlike a comma it can't end up
+// inside a tag name.
+const tagSep = "\x1f"
+
+// ErrNoTag is returned for a tag name that isn't in use.
+var ErrNoTag = errors.New("no such tag")
+
+// Tag is a tag with how many tasks carry it.
+type Tag struct {
+	ID    int64
+	Name  string
+	Color string // lipgloss color: "#ff8800", "208", or "" for the default
+	Open  int    // tasks not yet completed
+	Total int
+}
+
+// cleanTagNames trims tags and drops empty and repeated ones.
+func cleanTagNames(tags []string) []string {
+	var clean []string
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		clean = append(clean, tag)
+	}
+	return clean
+}
+
+// setTaskTags replaces a task's tags. Nothing is written when they are
+// unchanged, so the task's sync clock only moves on a real edit.
+func setTaskTags(tx *sql.Tx, taskID int64, tags []string) error {
+	tags = cleanTagNames(tags)
+	var current string
+	err := tx.QueryRow("SELECT COALESCE(tags, '') FROM tasks WHERE id = ?", taskID).Scan(&current)
+	if err != nil {
+		return err
+	}
+	if current == joinTags(tags) {
+		return nil
+	}
+
+	if _, err := tx.Exec("DELETE FROM task_tags WHERE task_id = ?", taskID); err != nil {
+		return err
+	}
+	for i, tag := range tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO task_tags (task_id, tag_id, position) SELECT ?, id, ? FROM tags WHERE name = ?",
+			taskID, i, tag); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE tasks SET tags = ? WHERE id = ?", nullIfEmpty(joinTags(tags)), taskID); err != nil {
+		return err
+	}
+	return pruneTags(tx)
+}
+
+// pruneTags forgets tags no task uses any more, unless they were given a
+// color worth keeping.
+func pruneTags(tx *sql.Tx) error {
+	_, err := tx.Exec("DELETE FROM tags WHERE color = '' AND id NOT IN (SELECT tag_id FROM task_tags)")
+	return err
+}
+
+// taskTagsSQL is the tags of the task with the given ID, as stored in
+// tasks.tags.
+const taskTagsSQL = `(SELECT group_concat(g.name, char(31) ORDER BY tt.position)
+	FROM task_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.task_id = %s)`
+
+// refreshTaskTags rewrites tasks.tags for the tasks carrying tagID.
+func refreshTaskTags(tx *sql.Tx, tagID int64) error {
+	_, err := tx.Exec(fmt.Sprintf("UPDATE tasks SET tags = %s WHERE id IN (SELECT task_id FROM task_tags WHERE tag_id = ?)",
+		fmt.Sprintf(taskTagsSQL, "tasks.id")), tagID)
+	return err
+}
+
+func tagID(tx *sql.Tx, name string) (int64, error) {
+	var id int64
+	err := tx.QueryRow("SELECT id FROM tags WHERE name = ?", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("#%s: %w", name, ErrNoTag)
+	}
+	return id, err
+}
+
+// GetTags returns every tag with its task counts, by name.
+func (db *DB) GetTags() ([]Tag, error) {
+	rows, err := db.Query(`
+		SELECT g.id, g.name, g.color,
+			COALESCE(SUM(CASE WHEN t.completed = 0 THEN 1 ELSE 0 END), 0), COUNT(t.id)
+		FROM tags g
+		LEFT JOIN task_tags tt ON tt.tag_id = g.id
+		LEFT JOIN tasks t ON t.id = tt.task_id
+		GROUP BY g.id
+		ORDER BY g.name COLLATE NOCASE
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tags []Tag
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.Color, &t.Open, &t.Total); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// TagColors returns the colors of the tags that have one, by name.
+func (db *DB) TagColors() (map[string]string, error) {
+	rows, err := db.Query("SELECT name, color FROM tags WHERE color != ''")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	colors := map[string]string{}
+	for rows.Next() {
+		var name, color string
+		if err := rows.Scan(&name, &color); err != nil {
+			return nil, err
+		}
+		colors[name] = color
+	}
+	return colors, rows.Err()
+}
+
+// SetTagColor sets the color a tag is shown in; an empty color resets it.
+func (db *DB) SetTagColor(name, color string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tagID(tx, name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE tags SET color = ? WHERE name = ?", color, name); err != nil {
+		return err
+	}
+	if err := pruneTags(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RenameTag renames a tag on every task that has it and returns how many
+// tasks that was. Renaming onto a tag that already exists is refused; that
+// is what MergeTags is for.
+func (db *DB) RenameTag(oldName, newName string) (int, error) {
+	newName = strings.TrimSpace(strings.TrimPrefix(newName, "#"))
+	if newName == "" {
+		return 0, errors.New("the new name is empty")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := tagID(tx, oldName)
+	if err != nil {
+		return 0, err
+	}
+	if other, err := tagID(tx, newName); err == nil && other != id {
+		return 0, fmt.Errorf("#%s already exists; merge the tags instead", newName)
+	}
+	if _, err := tx.Exec("UPDATE tags SET name = ? WHERE id = ?", newName, id); err != nil {
+		return 0, err
+	}
+	if err := refreshTaskTags(tx, id); err != nil {
+		return 0, err
+	}
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM task_tags WHERE tag_id = ?", id).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// MergeTags moves every task tagged from over to into, which is created if
+// needed, and deletes from. It returns how many tasks were retagged.
+func (db *DB) MergeTags(from, into string) (int, error) {
+	into = strings.TrimSpace(strings.TrimPrefix(into, "#"))
+	if into == "" {
+		return 0, errors.New("the tag to merge into is empty")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	fromID, err := tagID(tx, from)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", into); err != nil {
+		return 0, err
+	}
+	intoID, err := tagID(tx, into)
+	if err != nil {
+		return 0, err
+	}
+	if intoID == fromID {
+		return 0, errors.New("can't merge a tag into itself")
+	}
+
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM task_tags WHERE tag_id = ?", fromID).Scan(&n); err != nil {
+		return 0, err
+	}
+	err = execAll(tx,
+		fmt.Sprintf(`INSERT OR IGNORE INTO task_tags (task_id, tag_id, position)
+			SELECT task_id, %d, position FROM task_tags WHERE tag_id = %d`, intoID, fromID),
+		fmt.Sprintf(`DELETE FROM task_tags WHERE tag_id = %d`, fromID),
+		fmt.Sprintf(`UPDATE tags SET color = (SELECT color FROM tags WHERE id = %d) WHERE id = %d AND color = ''`, fromID, intoID),
+		fmt.Sprintf(`DELETE FROM tags WHERE id = %d`, fromID),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := refreshTaskTags(tx, intoID); err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
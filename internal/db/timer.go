@@ -0,0 +1,133 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RunningTimer is the time entry that has been started but not stopped.
+type RunningTimer struct {
+	EntryID   int64
+	TaskID    int64
+	Title     string
+	StartedAt time.Time
+}
+
+// TimeEntry is a tracked span of work on a task, with enough context to be
+// summed per task, tag or workspace.
+type TimeEntry struct {
+	TaskID        int64
+	Title         string
+	Tags          []string
+	WorkspaceID   int64
+	WorkspaceName string
+	Start         time.Time
+	End           time.Time // zero while the timer is running
+}
+
+// Duration returns the length of the entry; a running entry counts up to now.
+func (e TimeEntry) Duration() time.Duration {
+	end := e.End
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(e.Start)
+}
+
+// StartTimer stops any running timer and starts a new one on the task.
+func (db *DB) StartTimer(taskID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	if _, err := tx.Exec("UPDATE time_entries SET ended_at = ? WHERE ended_at IS NULL", now); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO time_entries (task_id, started_at) VALUES (?, ?)", taskID, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// StopTimer ends the running timer and returns it, or nil if none was running.
+func (db *DB) StopTimer() (*RunningTimer, error) {
+	running, err := db.GetRunningTimer()
+	if err != nil || running == nil {
+		return nil, err
+	}
+	if _, err := db.Exec("UPDATE time_entries SET ended_at = ? WHERE id = ?", time.Now().Unix(), running.EntryID); err != nil {
+		return nil, err
+	}
+	return running, nil
+}
+
+// GetRunningTimer returns the running timer, or nil if none is running.
+func (db *DB) GetRunningTimer() (*RunningTimer, error) {
+	var r RunningTimer
+	var started int64
+	err := db.QueryRow(`
+		SELECT e.id, e.task_id, t.title, e.started_at
+		FROM time_entries e JOIN tasks t ON t.id = e.task_id
+		WHERE e.ended_at IS NULL
+		ORDER BY e.started_at DESC LIMIT 1
+	`).Scan(&r.EntryID, &r.TaskID, &r.Title, &started)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.StartedAt = time.Unix(started, 0)
+	return &r, nil
+}
+
+// GetTaskTime returns the total time tracked on a task, including a running timer.
+func (db *DB) GetTaskTime(taskID int64) (time.Duration, error) {
+	var seconds int64
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(COALESCE(ended_at, ?) - started_at), 0)
+		FROM time_entries WHERE task_id = ?
+	`, time.Now().Unix(), taskID).Scan(&seconds)
+	return time.Duration(seconds) * time.Second, err
+}
+
+// GetTimeEntries returns the time tracked since the given moment. Entries
+// that started earlier are clipped so only the part after since is counted.
+func (db *DB) GetTimeEntries(since time.Time) ([]TimeEntry, error) {
+	rows, err := db.Query(`
+		SELECT t.id, t.title, COALESCE(t.tags, ''), w.id, w.name, e.started_at, e.ended_at
+		FROM time_entries e
+		JOIN tasks t ON t.id = e.task_id
+		JOIN workspaces w ON w.id = t.workspace_id
+		WHERE e.ended_at IS NULL OR e.ended_at > ?
+		ORDER BY e.started_at
+	`, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []TimeEntry
+	for rows.Next() {
+		var e TimeEntry
+		var tags string
+		var started int64
+		var ended sql.NullInt64
+		if err := rows.Scan(&e.TaskID, &e.Title, &tags, &e.WorkspaceID, &e.WorkspaceName, &started, &ended); err != nil {
+			return nil, err
+		}
+		e.Tags = splitTags(tags)
+		e.Start = time.Unix(started, 0)
+		if e.Start.Before(since) {
+			e.Start = since
+		}
+		if ended.Valid {
+			e.End = time.Unix(ended.Int64, 0)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,94 @@
+// Package ical reads and writes the subset of iCalendar (RFC 5545) that td
+// needs to publish due dates and import tasks from other apps.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Writer emits iCalendar content lines with CRLF endings, folding lines
+// longer than 75 octets.
+type Writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+func (w *Writer) Begin(component string) {
+	w.line("BEGIN:" + component)
+}
+
+func (w *Writer) End(component string) {
+	w.line("END:" + component)
+}
+
+// Prop writes a property with an already formatted value, e.g. a date.
+// Params are written as given, e.g. "VALUE=DATE".
+func (w *Writer) Prop(name, value string, params ...string) {
+	if len(params) > 0 {
+		name += ";" + strings.Join(params, ";")
+	}
+	w.line(name + ":" + value)
+}
+
+// Text writes a TEXT property, escaping the value.
+func (w *Writer) Text(name, value string, params ...string) {
+	w.Prop(name, EscapeText(value), params...)
+}
+
+// Flush writes any buffered data and returns the first error encountered.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+func (w *Writer) line(s string) {
+	if w.err != nil {
+		return
+	}
+	// The space that starts a continuation line counts towards its 75.
+	for limit := 75; len(s) > limit; limit = 74 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, w.err = w.w.WriteString(s[:cut] + "\r\n "); w.err != nil {
+			return
+		}
+		s = s[cut:]
+	}
+	_, w.err = w.w.WriteString(s + "\r\n")
+}
+
+// EscapeText escapes a TEXT value.
+func EscapeText(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// UnescapeText reverses EscapeText.
+func UnescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
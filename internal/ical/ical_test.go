@@ -0,0 +1,126 @@
+package ical
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+func TestWriterFoldsLongLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"short", "Buy milk"},
+		{"exactly one line", strings.Repeat("a", 75-len("DESCRIPTION:"))},
+		{"ascii", strings.Repeat("The quick brown fox jumps over the lazy dog. ", 8)},
+		{"multibyte", strings.Repeat("Überprüfung der Äpfel, 日本語のメモ, emoji 🎉; ", 10)},
+		{"escapes", strings.Repeat("line one\nline two, with; separators\\ ", 6)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.Begin("VTODO")
+			w.Text("DESCRIPTION", tt.value)
+			w.End("VTODO")
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			out := buf.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output does not end in CRLF: %q", out)
+			}
+			for i, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets, more than 75: %q", i+1, len(line), line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d splits a character: %q", i+1, line)
+				}
+			}
+
+			root, err := Parse(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(root.Components) != 1 {
+				t.Fatalf("parsed %d components, want 1", len(root.Components))
+			}
+			desc, ok := root.Components[0].Get("DESCRIPTION")
+			if !ok {
+				t.Fatal("DESCRIPTION missing after parsing")
+			}
+			if got := desc.Text(); got != tt.value {
+				t.Errorf("round trip changed the value:\n got %q\nwant %q", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestEncodeDecodeTodos(t *testing.T) {
+	const (
+		parentUUID = "0b6f1c2a-0000-4000-8000-000000000001"
+		childUUID  = "0b6f1c2a-0000-4000-8000-000000000002"
+		doneUUID   = "0b6f1c2a-0000-4000-8000-000000000003"
+	)
+	task := func(uuid, title string, change func(*db.DatedTask)) db.DatedTask {
+		t := db.DatedTask{Task: model.Task{UUID: uuid, Title: title, DueDate: "2026-03-01", Status: model.StatusOpen},
+			WorkspaceName: "Home"}
+		change(&t)
+		return t
+	}
+	tasks := []db.DatedTask{
+		task(parentUUID, "Plan the trip; pack", func(t *db.DatedTask) {
+			t.Priority = 2
+			t.Status = model.StatusInProgress
+			t.Tags = []string{"travel", "a,b"}
+			t.Notes = strings.Repeat("Überprüfung der Äpfel, 日本語のメモ 🎉\n", 6)
+			t.WorkspaceName = "Side projects"
+		}),
+		task(childUUID, "Book flights", func(t *db.DatedTask) {
+			t.Priority = 1
+			t.ParentUUID = parentUUID
+			t.Status = model.StatusCancelled
+		}),
+		task(doneUUID, "Renew passport", func(t *db.DatedTask) {
+			t.Completed = true
+			t.Status = model.StatusDone
+			t.CompletedAt = time.Date(2026, 2, 20, 9, 30, 0, 0, time.UTC)
+		}),
+		task("0b6f1c2a-0000-4000-8000-000000000004", "Someday", func(t *db.DatedTask) { t.DueDate = "" }),
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeTasks(&buf, tasks, KindBoth, "td"); err != nil {
+		t.Fatal(err)
+	}
+	todos, err := DecodeTodos(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Todo{
+		{UID: TaskUID(parentUUID), Title: "Plan the trip; pack", Notes: strings.TrimSpace(tasks[0].Notes),
+			DueDate: "2026-03-01", Priority: 2, Tags: []string{"travel", "a,b"}, Status: model.StatusInProgress,
+			Workspace: "Side projects"},
+		{UID: TaskUID(childUUID), Title: "Book flights", DueDate: "2026-03-01", Priority: 1,
+			Status: model.StatusCancelled, ParentUID: TaskUID(parentUUID), Workspace: "Home"},
+		{UID: TaskUID(doneUUID), Title: "Renew passport", DueDate: "2026-03-01", Status: model.StatusDone,
+			Workspace: "Home"},
+	}
+	if !reflect.DeepEqual(todos, want) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", todos, want)
+	}
+	for _, todo := range todos {
+		if uuid, ok := ParseTaskUID(todo.UID); !ok || !strings.HasPrefix(uuid, "0b6f1c2a-") {
+			t.Errorf("ParseTaskUID(%q) = %q, %v; want the task's UUID", todo.UID, uuid, ok)
+		}
+	}
+}
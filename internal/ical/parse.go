@@ -0,0 +1,151 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Component is a parsed BEGIN/END block such as VCALENDAR or VTODO.
+type Component struct {
+	Name       string
+	Props      []Property
+	Components []*Component
+}
+
+// Property is one content line. Values are kept raw; use Text for TEXT
+// values that need unescaping.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+func (p Property) Text() string {
+	return UnescapeText(p.Value)
+}
+
+// Get returns the first property with the given name.
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Props {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// All returns every property with the given name.
+func (c *Component) All(name string) []Property {
+	var props []Property
+	for _, p := range c.Props {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Walk calls fn for the component and all components nested in it.
+func (c *Component) Walk(fn func(*Component)) {
+	fn(c)
+	for _, child := range c.Components {
+		child.Walk(fn)
+	}
+}
+
+// Parse reads an iCalendar stream. Top-level components are returned as the
+// children of an unnamed root, so files with several VCALENDARs work too.
+func Parse(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Component{}
+	stack := []*Component{root}
+	for n, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		top := stack[len(stack)-1]
+		switch prop.Name {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(prop.Value)}
+			top.Components = append(top.Components, c)
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 1 || top.Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", n+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			top.Props = append(top.Props, prop)
+		}
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// unfold joins continuation lines, which start with a space or tab.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits "NAME;PARAM=value;PARAM="quoted":value".
+func parseLine(line string) (Property, error) {
+	prop := Property{Params: map[string]string{}}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return prop, fmt.Errorf("malformed content line %q", line)
+	}
+	prop.Name = strings.ToUpper(line[:i])
+	rest := line[i:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return prop, fmt.Errorf("malformed parameter in %q", line)
+		}
+		key := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return prop, fmt.Errorf("unterminated quote in %q", line)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return prop, fmt.Errorf("missing value in %q", line)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		prop.Params[key] = value
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return prop, fmt.Errorf("missing value in %q", line)
+	}
+	prop.Value = rest[1:]
+	return prop, nil
+}
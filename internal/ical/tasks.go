@@ -0,0 +1,305 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+// Kind selects which components a feed contains.
+type Kind string
+
+const (
+	KindTodo  Kind = "todo"  // VTODO, for task apps
+	KindEvent Kind = "event" // all-day VEVENT on the due date, for calendars
+	KindBoth  Kind = "both"
+)
+
+func ParseKind(s string) (Kind, bool) {
+	switch Kind(strings.ToLower(s)) {
+	case KindTodo, "vtodo":
+		return KindTodo, true
+	case KindEvent, "vevent":
+		return KindEvent, true
+	case KindBoth, "":
+		return KindBoth, true
+	}
+	return "", false
+}
+
+// TaskUID is the stable UID of a task's VTODO. It is derived from the task's
+// UUID, so re-exporting updates the entry instead of duplicating it, from
+// any machine the task was synced to.
+func TaskUID(uuid string) string {
+	return uuid + "@td"
+}
+
+// EventUID is the stable UID of a task's due-date VEVENT.
+func EventUID(uuid string) string {
+	return uuid + "-due@td"
+}
+
+const dateFormat = "20060102"
+const utcFormat = "20060102T150405Z"
+
+// EncodeTasks writes a calendar with an entry per task. Tasks without a
+// valid due date are skipped. td has no recurring tasks yet, so no RRULE is
+// written.
+func EncodeTasks(out io.Writer, tasks []db.DatedTask, kind Kind, name string) error {
+	w := NewWriter(out)
+	stamp := time.Now().UTC().Format(utcFormat)
+
+	w.Begin("VCALENDAR")
+	w.Prop("VERSION", "2.0")
+	w.Prop("PRODID", "-//appgram//td//EN")
+	w.Prop("CALSCALE", "GREGORIAN")
+	if name != "" {
+		w.Text("X-WR-CALNAME", name)
+	}
+	for _, t := range tasks {
+		due, err := time.Parse("2006-01-02", t.DueDate)
+		if err != nil {
+			continue
+		}
+		if kind == KindTodo || kind == KindBoth {
+			writeTodo(w, t, due, stamp)
+		}
+		if kind == KindEvent || kind == KindBoth {
+			writeEvent(w, t, due, stamp)
+		}
+	}
+	w.End("VCALENDAR")
+	return w.Flush()
+}
+
+func writeTodo(w *Writer, t db.DatedTask, due time.Time, stamp string) {
+	w.Begin("VTODO")
+	w.Prop("UID", TaskUID(t.UUID))
+	w.Prop("DTSTAMP", stamp)
+	writeCommon(w, t, t.Title)
+	w.Prop("DUE", due.Format(dateFormat), "VALUE=DATE")
+	w.Prop("STATUS", todoStatus(t))
+	if !t.CompletedAt.IsZero() {
+		w.Prop("COMPLETED", t.CompletedAt.UTC().Format(utcFormat))
+	}
+	if t.ParentUUID != "" {
+		w.Prop("RELATED-TO", TaskUID(t.ParentUUID))
+	}
+	w.End("VTODO")
+}
+
+func writeEvent(w *Writer, t db.DatedTask, due time.Time, stamp string) {
+	w.Begin("VEVENT")
+	w.Prop("UID", EventUID(t.UUID))
+	w.Prop("DTSTAMP", stamp)
+	summary := t.Title
+	if t.Completed {
+		summary = "✓ " + summary
+	}
+	writeCommon(w, t, summary)
+	w.Prop("DTSTART", due.Format(dateFormat), "VALUE=DATE")
+	w.Prop("DTEND", due.AddDate(0, 0, 1).Format(dateFormat), "VALUE=DATE")
+	w.Prop("TRANSP", "TRANSPARENT")
+	if t.Status == model.StatusCancelled {
+		w.Prop("STATUS", "CANCELLED")
+	}
+	w.End("VEVENT")
+}
+
+func writeCommon(w *Writer, t db.DatedTask, summary string) {
+	w.Text("SUMMARY", summary)
+	if created, err := time.Parse(time.RFC3339, t.CreatedAt); err == nil {
+		w.Prop("CREATED", created.UTC().Format(utcFormat))
+	}
+	if t.Notes != "" {
+		w.Text("DESCRIPTION", t.Notes)
+	}
+	if len(t.Tags) > 0 {
+		escaped := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			escaped[i] = EscapeText(tag)
+		}
+		w.Prop("CATEGORIES", strings.Join(escaped, ","))
+	}
+	w.Text("X-TD-WORKSPACE", t.WorkspaceName)
+	if p := icalPriority(t.Priority); p != 0 {
+		w.Prop("PRIORITY", fmt.Sprint(p))
+	}
+}
+
+// icalPriority maps td priorities onto the 1 (highest) to 9 (lowest) scale.
+func icalPriority(priority int) int {
+	switch {
+	case priority >= 2:
+		return 1
+	case priority == 1:
+		return 9
+	default:
+		return 5
+	}
+}
+
+func todoStatus(t db.DatedTask) string {
+	switch {
+	case t.Completed || t.Status == model.StatusDone:
+		return "COMPLETED"
+	case t.Status == model.StatusCancelled:
+		return "CANCELLED"
+	case t.Status == model.StatusInProgress:
+		return "IN-PROCESS"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+// ParseTaskUID recognises the "<uuid>@td" UIDs td writes for VTODOs and
+// returns the task's UUID.
+func ParseTaskUID(uid string) (string, bool) {
+	if ref, ok := strings.CutSuffix(uid, "@td"); ok && len(ref) == 36 && strings.Count(ref, "-") == 4 {
+		return ref, true
+	}
+	return "", false
+}
+
+// ParseLegacyTaskUID recognises the "task-N@td" UIDs older versions of td
+// wrote and returns the task ID. IDs are only meaningful in the database
+// that exported them.
+func ParseLegacyTaskUID(uid string) (int64, bool) {
+	var id int64
+	var rest string
+	if n, _ := fmt.Sscanf(uid, "task-%d%s", &id, &rest); n == 2 && rest == "@td" {
+		return id, true
+	}
+	return 0, false
+}
+
+// Todo is a VTODO mapped onto td's task fields.
+type Todo struct {
+	UID       string
+	Title     string
+	Notes     string
+	DueDate   string // YYYY-MM-DD, empty when unset
+	Priority  int
+	Tags      []string
+	Status    model.Status
+	ParentUID string
+	Workspace string // X-TD-WORKSPACE, only set in td's own exports
+}
+
+// DecodeTodos reads every VTODO in an iCalendar stream. Other components
+// are ignored.
+func DecodeTodos(r io.Reader) ([]Todo, error) {
+	root, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	var todos []Todo
+	root.Walk(func(c *Component) {
+		if c.Name == "VTODO" {
+			todos = append(todos, decodeTodo(c))
+		}
+	})
+	return todos, nil
+}
+
+func decodeTodo(c *Component) Todo {
+	var t Todo
+	if p, ok := c.Get("UID"); ok {
+		t.UID = p.Value
+	}
+	if p, ok := c.Get("SUMMARY"); ok {
+		t.Title = strings.TrimSpace(p.Text())
+	}
+	if p, ok := c.Get("DESCRIPTION"); ok {
+		t.Notes = strings.TrimSpace(p.Text())
+	}
+	if p, ok := c.Get("DUE"); ok {
+		t.DueDate = parseDate(p)
+	}
+	if p, ok := c.Get("PRIORITY"); ok {
+		var n int
+		fmt.Sscanf(p.Value, "%d", &n)
+		t.Priority = tdPriority(n)
+	}
+	for _, p := range c.All("CATEGORIES") {
+		for _, tag := range splitList(p.Value) {
+			tag = strings.Join(strings.Fields(UnescapeText(tag)), "-")
+			if tag != "" {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+	}
+	t.Status = model.StatusOpen
+	if p, ok := c.Get("STATUS"); ok {
+		switch strings.ToUpper(p.Value) {
+		case "IN-PROCESS":
+			t.Status = model.StatusInProgress
+		case "COMPLETED":
+			t.Status = model.StatusDone
+		case "CANCELLED":
+			t.Status = model.StatusCancelled
+		}
+	}
+	if _, ok := c.Get("COMPLETED"); ok && t.Status == model.StatusOpen {
+		t.Status = model.StatusDone
+	}
+	for _, p := range c.All("RELATED-TO") {
+		if rel := strings.ToUpper(p.Params["RELTYPE"]); rel == "" || rel == "PARENT" {
+			t.ParentUID = p.Value
+			break
+		}
+	}
+	if p, ok := c.Get("X-TD-WORKSPACE"); ok {
+		t.Workspace = p.Text()
+	}
+	return t
+}
+
+// parseDate reads a DATE or DATE-TIME value as a local calendar day. UTC
+// times are converted first; floating and TZID times keep their date.
+func parseDate(p Property) string {
+	v := p.Value
+	if strings.HasSuffix(v, "Z") {
+		if t, err := time.Parse(utcFormat, v); err == nil {
+			return t.Local().Format("2006-01-02")
+		}
+	}
+	if len(v) >= 8 {
+		if t, err := time.Parse(dateFormat, v[:8]); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// tdPriority maps the iCalendar 1 (highest) to 9 (lowest) scale onto td's.
+func tdPriority(n int) int {
+	switch {
+	case n >= 1 && n <= 4:
+		return 2
+	case n >= 6 && n <= 9:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// splitList splits a multi-valued property on commas that aren't escaped.
+func splitList(v string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, v[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, v[start:])
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Estimate is the expected effort for a task, as working time, story points
+// or both. The two are never converted into each other.
+type Estimate struct {
+	Minutes int     `json:"minutes,omitempty"`
+	Points  float64 `json:"points,omitempty"`
+}
+
+// ParseEstimate accepts "90m", "2h", "1.5h", "1h30m" or "3p".
+func ParseEstimate(s string) (Estimate, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return Estimate{}, false
+	}
+	if p, ok := strings.CutSuffix(s, "p"); ok {
+		points, err := strconv.ParseFloat(p, 64)
+		if err != nil || points < 0 {
+			return Estimate{}, false
+		}
+		return Estimate{Points: points}, true
+	}
+
+	var minutes float64
+	for s != "" {
+		i := strings.IndexAny(s, "hm")
+		if i <= 0 {
+			return Estimate{}, false
+		}
+		n, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil || n < 0 {
+			return Estimate{}, false
+		}
+		if s[i] == 'h' {
+			n *= 60
+		}
+		minutes += n
+		s = s[i+1:]
+	}
+	return Estimate{Minutes: int(minutes + 0.5)}, true
+}
+
+func (e Estimate) IsZero() bool {
+	return e.Minutes == 0 && e.Points == 0
+}
+
+func (e Estimate) Add(o Estimate) Estimate {
+	return Estimate{Minutes: e.Minutes + o.Minutes, Points: e.Points + o.Points}
+}
+
+// String renders the estimate the way it is typed, e.g. "2h30m", "3p" or
+// "2h · 3p" when both are set.
+func (e Estimate) String() string {
+	var parts []string
+	if e.Minutes > 0 {
+		parts = append(parts, FormatMinutes(e.Minutes))
+	}
+	if e.Points > 0 {
+		parts = append(parts, strconv.FormatFloat(e.Points, 'f', -1, 64)+"p")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// FormatMinutes renders a number of minutes as "45m", "2h" or "2h30m".
+func FormatMinutes(minutes int) string {
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	default:
+		return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+	}
+}
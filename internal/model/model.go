@@ -1,17 +1,72 @@
 package model
 
+// Status is the workflow state of a task, independent of its priority.
+type Status string
+
+const (
+	StatusOpen       Status = "open"
+	StatusInProgress Status = "in-progress"
+	StatusWaiting    Status = "waiting"
+	StatusBlocked    Status = "blocked"
+	StatusDone       Status = "done"
+	StatusCancelled  Status = "cancelled"
+)
+
+// Statuses lists every status in workflow order.
+var Statuses = []Status{StatusOpen, StatusInProgress, StatusWaiting, StatusBlocked, StatusDone, StatusCancelled}
+
+// ParseStatus accepts a status name or one of its short forms.
+func ParseStatus(s string) (Status, bool) {
+	switch s {
+	case "open", "todo", "o":
+		return StatusOpen, true
+	case "in-progress", "inprogress", "progress", "wip", "doing", "i":
+		return StatusInProgress, true
+	case "waiting", "wait", "w":
+		return StatusWaiting, true
+	case "blocked", "block", "b":
+		return StatusBlocked, true
+	case "done", "d":
+		return StatusDone, true
+	case "cancelled", "canceled", "cancel", "c":
+		return StatusCancelled, true
+	}
+	return "", false
+}
+
+// Closed reports whether no more work is expected on a task in this status.
+func (s Status) Closed() bool {
+	return s == StatusDone || s == StatusCancelled
+}
+
 type Task struct {
-	ID        int64    `json:"id"`
-	ParentID  *int64   `json:"parent_id"`
-	Workspace int64    `json:"workspace"`
-	Title     string   `json:"title"`
-	Completed bool     `json:"completed"`
-	Tags      []string `json:"tags"`
-	DueDate   string   `json:"due_date"`
-	Priority  int      `json:"priority"`
-	Order     int      `json:"order"`
-	CreatedAt string   `json:"created_at"`
-	Children  []*Task  `json:"-"`
+	ID        int64      `json:"id"`
+	UUID      string     `json:"uuid"`
+	ParentID  *int64     `json:"parent_id"`
+	Workspace int64      `json:"workspace"`
+	Title     string     `json:"title"`
+	Completed bool       `json:"completed"`
+	Tags      []string   `json:"tags"`
+	DueDate   string     `json:"due_date"`
+	Priority  int        `json:"priority"`
+	Order     int        `json:"order"`
+	CreatedAt string     `json:"created_at"`
+	Notes     string     `json:"notes"`
+	Status    Status     `json:"status"`
+	DependsOn []int64    `json:"depends_on"`
+	BlockedBy []int64    `json:"blocked_by"` // prerequisites that are still open
+	Estimate  Estimate   `json:"estimate"`
+	Reminders []Reminder `json:"reminders"`
+	Children  []*Task    `json:"-"`
+}
+
+// EffectiveStatus is the status to show for a task: an unfinished task with
+// open prerequisites is blocked regardless of its own status.
+func (t *Task) EffectiveStatus() Status {
+	if len(t.BlockedBy) > 0 && !t.Completed && !t.Status.Closed() {
+		return StatusBlocked
+	}
+	return t.Status
 }
 
 type Workspace struct {
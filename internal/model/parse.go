@@ -0,0 +1,230 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParsedTask holds the result of parsing inline task syntax
+type ParsedTask struct {
+	Title     string
+	Tags      []string
+	DueDate   string
+	Priority  int
+	Status    Status
+	DependsOn []string // task references: IDs or UUID prefixes
+	Estimate  Estimate
+	Reminders []Reminder
+	Workspace string // workspace name (spaces may be written as dashes) or UUID prefix
+}
+
+// Refs reports which references name an existing workspace or task.
+type Refs interface {
+	IsWorkspaceRef(ref string) bool
+	IsTaskRef(ref string) bool
+}
+
+// ParseTaskInput parses inline task syntax:
+// "task #tag @date +workspace !priority %status ~id =2h remind:30m-before"
+// A "+word" only names a workspace, and a "~word" of hex digits only names
+// a task, when refs reports one by that reference, so titles like "reply
+// +1" or "meet at ~cafe" keep their word. With nil refs they always stay in
+// the title. "~42" always depends on task #42.
+func ParseTaskInput(input string, refs Refs) ParsedTask {
+	var result ParsedTask
+	var titleParts []string
+	var reminders []string
+
+	words := strings.Fields(input)
+	for _, word := range words {
+		switch {
+		case len(word) > len("remind:") && strings.EqualFold(word[:len("remind:")], "remind:"):
+			reminders = append(reminders, word[len("remind:"):])
+		case strings.HasPrefix(word, "#"):
+			tag := strings.TrimPrefix(word, "#")
+			if tag != "" {
+				result.Tags = append(result.Tags, tag)
+			}
+		case strings.HasPrefix(word, "!"):
+			p := strings.ToLower(strings.TrimPrefix(word, "!"))
+			switch p {
+			case "high", "h":
+				result.Priority = 2
+			case "low", "l":
+				result.Priority = 1
+			case "blocked", "b":
+				// Kept for compatibility; blocked is a status now.
+				result.Status = StatusBlocked
+			case "normal", "n":
+				result.Priority = 0
+			}
+		case strings.HasPrefix(word, "%") && len(word) > 1:
+			if status, ok := ParseStatus(strings.ToLower(word[1:])); ok {
+				result.Status = status
+			} else {
+				titleParts = append(titleParts, word)
+			}
+		case strings.HasPrefix(word, "~") && len(word) > 1:
+			if ref, ok := ParseTaskRef(word); ok && (isTaskID(ref) || refs != nil && refs.IsTaskRef(ref)) {
+				result.DependsOn = append(result.DependsOn, ref)
+			} else {
+				titleParts = append(titleParts, word)
+			}
+		case strings.HasPrefix(word, "=") && len(word) > 1:
+			if est, ok := ParseEstimate(word[1:]); ok {
+				result.Estimate = result.Estimate.Add(est)
+			} else {
+				titleParts = append(titleParts, word)
+			}
+		case strings.HasPrefix(word, "@"):
+			date := strings.TrimPrefix(word, "@")
+			result.DueDate = ParseDueDate(date)
+		case strings.HasPrefix(word, "+") && len(word) > 1 && refs != nil && refs.IsWorkspaceRef(word[1:]):
+			result.Workspace = word[1:]
+		default:
+			titleParts = append(titleParts, word)
+		}
+	}
+
+	// Reminders are resolved last so a bare time can land on the due date.
+	for _, spec := range reminders {
+		if r, ok := ParseReminder(spec, result.DueDate, time.Now()); ok {
+			result.Reminders = append(result.Reminders, r)
+		} else {
+			titleParts = append(titleParts, "remind:"+spec)
+		}
+	}
+
+	result.Title = strings.Join(titleParts, " ")
+	return result
+}
+
+// ParseDueDate resolves a date shortcut such as "tomorrow" or "fri", or a
+// YYYY-MM-DD or MM-DD date, to YYYY-MM-DD.
+func ParseDueDate(input string) string {
+	input = strings.ToLower(input)
+	now := time.Now()
+
+	switch input {
+	case "today":
+		return now.Format("2006-01-02")
+	case "tomorrow", "tmr":
+		return now.AddDate(0, 0, 1).Format("2006-01-02")
+	case "week", "nextweek":
+		return now.AddDate(0, 0, 7).Format("2006-01-02")
+	case "mon", "monday":
+		return nextWeekday(now, time.Monday)
+	case "tue", "tuesday":
+		return nextWeekday(now, time.Tuesday)
+	case "wed", "wednesday":
+		return nextWeekday(now, time.Wednesday)
+	case "thu", "thursday":
+		return nextWeekday(now, time.Thursday)
+	case "fri", "friday":
+		return nextWeekday(now, time.Friday)
+	case "sat", "saturday":
+		return nextWeekday(now, time.Saturday)
+	case "sun", "sunday":
+		return nextWeekday(now, time.Sunday)
+	default:
+		// Try parsing as date (YYYY-MM-DD or MM-DD)
+		if t, err := time.Parse("2006-01-02", input); err == nil {
+			return t.Format("2006-01-02")
+		}
+		if t, err := time.Parse("01-02", input); err == nil {
+			return time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location()).Format("2006-01-02")
+		}
+		return input
+	}
+}
+
+func nextWeekday(from time.Time, weekday time.Weekday) string {
+	daysUntil := int(weekday) - int(from.Weekday())
+	if daysUntil <= 0 {
+		daysUntil += 7
+	}
+	return from.AddDate(0, 0, daysUntil).Format("2006-01-02")
+}
+
+// ParseReminder reads the part after "remind:": "30m-before", "2h-before" or
+// "1d-before" relative to the due date, or a moment such as "14:30",
+// "friday", "2026-03-01" or "tomorrowT09:30". A bare time falls on the due
+// date when there is one, otherwise on its next occurrence.
+func ParseReminder(spec, dueDate string, now time.Time) (Reminder, bool) {
+	spec = strings.ToLower(spec)
+	if amount, ok := strings.CutSuffix(spec, "-before"); ok {
+		before, ok := parseReminderOffset(amount)
+		return Reminder{Before: before}, ok
+	}
+
+	day, clock, hasDay := spec, "", true
+	if i := strings.LastIndexAny(spec, "t@"); i >= 0 && strings.Contains(spec[i:], ":") {
+		day, clock = spec[:i], spec[i+1:]
+	} else if strings.Contains(spec, ":") {
+		day, clock, hasDay = "", spec, false
+	}
+
+	offset := DueTimeOfDay
+	if clock != "" {
+		t, err := time.Parse("15:04", clock)
+		if err != nil {
+			return Reminder{}, false
+		}
+		offset = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	date := dueDate
+	if hasDay {
+		date = ParseDueDate(day)
+	}
+	if date == "" {
+		at := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Add(offset)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return Reminder{At: at}, true
+	}
+	base, err := time.ParseInLocation("2006-01-02", date, now.Location())
+	if err != nil {
+		return Reminder{}, false
+	}
+	return Reminder{At: base.Add(offset)}, true
+}
+
+func parseReminderOffset(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	switch s[len(s)-1] {
+	case 'm':
+		return time.Duration(n) * time.Minute, true
+	case 'h':
+		return time.Duration(n) * time.Hour, true
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// ParseTaskRef accepts a task ID ("42", "#42", "~42") or a UUID prefix of
+// at least four hex digits, returning it in a form db.ResolveTask takes.
+// The sigil is kept, since it makes a number an ID and never a prefix.
+func ParseTaskRef(s string) (string, bool) {
+	ref := strings.ToLower(strings.TrimSpace(s))
+	bare := strings.TrimLeft(ref, "#~")
+	if id, err := strconv.ParseInt(bare, 10, 64); err == nil {
+		return ref, id > 0
+	}
+	return bare, len(bare) >= 4 && strings.Trim(bare, "0123456789abcdef-") == ""
+}
+
+// isTaskID reports whether a reference from ParseTaskRef is a task ID.
+func isTaskID(ref string) bool {
+	_, err := strconv.ParseInt(strings.TrimLeft(ref, "#~"), 10, 64)
+	return err == nil
+}
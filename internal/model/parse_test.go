@@ -0,0 +1,100 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// refs is a fixed set of workspace and task references.
+type refs struct {
+	workspaces, tasks map[string]bool
+}
+
+func (r refs) IsWorkspaceRef(ref string) bool { return r.workspaces[ref] }
+func (r refs) IsTaskRef(ref string) bool      { return r.tasks[ref] }
+
+func TestParseTaskInput(t *testing.T) {
+	known := refs{
+		workspaces: map[string]bool{"work": true},
+		tasks:      map[string]bool{"beef": true, "1a2b3c4d": true},
+	}
+	tests := []struct {
+		input string
+		refs  Refs
+		want  ParsedTask
+	}{
+		{input: "Buy milk", want: ParsedTask{Title: "Buy milk"}},
+		{input: "Buy milk #home #errand", want: ParsedTask{Title: "Buy milk", Tags: []string{"home", "errand"}}},
+		{input: "Ship it !high", want: ParsedTask{Title: "Ship it", Priority: 2}},
+		{input: "Ship it !l", want: ParsedTask{Title: "Ship it", Priority: 1}},
+		{input: "Ship it !blocked", want: ParsedTask{Title: "Ship it", Status: StatusBlocked}},
+		{input: "Ship it %wip", want: ParsedTask{Title: "Ship it", Status: StatusInProgress}},
+		{input: "Grow 100%", want: ParsedTask{Title: "Grow 100%"}},
+		{input: "Grow %faster", want: ParsedTask{Title: "Grow %faster"}},
+		{input: "Report @2026-03-01", want: ParsedTask{Title: "Report", DueDate: "2026-03-01"}},
+		{input: "Report =1h30m =2p", want: ParsedTask{Title: "Report", Estimate: Estimate{Minutes: 90, Points: 2}}},
+		{input: "Pay a=b", want: ParsedTask{Title: "Pay a=b"}},
+		{input: "Pay =soon", want: ParsedTask{Title: "Pay =soon"}},
+
+		// "~" followed by a number is always a task ID; the sigil is kept so
+		// it is never taken for a UUID prefix.
+		{input: "Deploy ~42", want: ParsedTask{Title: "Deploy", DependsOn: []string{"~42"}}},
+		{input: "Deploy ~12345678", want: ParsedTask{Title: "Deploy", DependsOn: []string{"~12345678"}}},
+		{input: "Deploy ~0", want: ParsedTask{Title: "Deploy ~0"}},
+		// A hex word only depends on a task that exists.
+		{input: "Meet at ~cafe", want: ParsedTask{Title: "Meet at ~cafe"}},
+		{input: "Meet at ~cafe", refs: known, want: ParsedTask{Title: "Meet at ~cafe"}},
+		{input: "Deploy ~BEEF ~1a2b3c4d", refs: known, want: ParsedTask{Title: "Deploy", DependsOn: []string{"beef", "1a2b3c4d"}}},
+		{input: "About ~5 minutes", want: ParsedTask{Title: "About minutes", DependsOn: []string{"~5"}}},
+		{input: "Roughly ~ten", refs: known, want: ParsedTask{Title: "Roughly ~ten"}},
+
+		// "+word" only moves the task when the workspace exists.
+		{input: "Reply +1", want: ParsedTask{Title: "Reply +1"}},
+		{input: "Reply +1", refs: known, want: ParsedTask{Title: "Reply +1"}},
+		{input: "Standup +work", refs: known, want: ParsedTask{Title: "Standup", Workspace: "work"}},
+
+		{input: "Call remind:never", want: ParsedTask{Title: "Call remind:never"}},
+	}
+	for _, tt := range tests {
+		got := ParseTaskInput(tt.input, tt.refs)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTaskInput(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseTaskInputReminders(t *testing.T) {
+	got := ParseTaskInput("Dentist @2026-03-01 remind:1d-before", nil)
+	want := []Reminder{{Before: 24 * time.Hour}}
+	if got.Title != "Dentist" || !reflect.DeepEqual(got.Reminders, want) {
+		t.Errorf("got title %q, reminders %+v; want %q, %+v", got.Title, got.Reminders, "Dentist", want)
+	}
+}
+
+func TestParseTaskRef(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"42", "42", true},
+		{"#42", "#42", true},
+		{"~42", "~42", true},
+		{" 7 ", "7", true},
+		{"0", "0", false},
+		{"-3", "-3", false},
+		{"CAFE", "cafe", true},
+		{"~cafe", "cafe", true},
+		{"#1a2b-3c", "1a2b-3c", true},
+		{"caf", "caf", false},
+		{"coffee", "coffee", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseTaskRef(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseTaskRef(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// DueTimeOfDay is when a task with a due date counts as due, for reminders
+// relative to the due date.
+var DueTimeOfDay = 9 * time.Hour
+
+// Reminder fires either at a fixed moment or a fixed time before the task's
+// due date.
+type Reminder struct {
+	ID      int64         `json:"id"`
+	At      time.Time     `json:"at,omitempty"`     // absolute reminder; zero when relative
+	Before  time.Duration `json:"before,omitempty"` // relative to the due date
+	FiredAt time.Time     `json:"fired_at,omitempty"`
+}
+
+func (r Reminder) Relative() bool {
+	return r.At.IsZero()
+}
+
+// FireTime returns when the reminder goes off for a task due on dueDate. A
+// relative reminder on a task without a due date never fires.
+func (r Reminder) FireTime(dueDate string) (time.Time, bool) {
+	if !r.Relative() {
+		return r.At, true
+	}
+	day, err := time.ParseInLocation("2006-01-02", dueDate, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return day.Add(DueTimeOfDay - r.Before), true
+}
+
+// String renders the reminder in inline syntax, without the "remind:" prefix.
+func (r Reminder) String() string {
+	if r.Relative() {
+		return formatBefore(r.Before) + "-before"
+	}
+	return r.At.Format("2006-01-02T15:04")
+}
+
+func formatBefore(d time.Duration) string {
+	minutes := int(d.Minutes())
+	switch {
+	case minutes > 0 && minutes%(24*60) == 0:
+		return fmt.Sprintf("%dd", minutes/(24*60))
+	case minutes > 0 && minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
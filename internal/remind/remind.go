@@ -0,0 +1,84 @@
+// Package remind finds reminders that are due and announces them, either in
+// the terminal or through a user-supplied command.
+package remind
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appgram/td/internal/db"
+)
+
+// Due returns the reminders that should have fired by now and claims them as
+// fired, so each one is announced once even if several notifiers are running.
+func Due(database *db.DB, now time.Time) ([]db.PendingReminder, error) {
+	pending, err := database.GetPendingReminders()
+	if err != nil {
+		return nil, err
+	}
+	var due []db.PendingReminder
+	for _, p := range pending {
+		if p.FireAt.After(now) {
+			break
+		}
+		claimed, err := database.MarkReminderFired(p.ID, now)
+		if err != nil {
+			return due, err
+		}
+		if claimed {
+			due = append(due, p)
+		}
+	}
+	return due, nil
+}
+
+// Message is the one-line text of a notification.
+func Message(p db.PendingReminder) string {
+	if p.DueDate == "" {
+		return p.Title
+	}
+	return fmt.Sprintf("%s (due %s)", p.Title, p.DueDate)
+}
+
+// TerminalAlert is a bell followed by the OSC 9 and OSC 777 escapes, which
+// terminals such as iTerm2, kitty, WezTerm and foot turn into desktop
+// notifications. Terminals that don't know them ignore them.
+func TerminalAlert(title, body string) string {
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r < 0x20 || r == 0x7f || r == ';' {
+				return ' '
+			}
+			return r
+		}, s)
+	}
+	title, body = clean(title), clean(body)
+	return "\a\x1b]9;" + title + ": " + body + "\x07\x1b]777;notify;" + title + ";" + body + "\x07"
+}
+
+// RunHook runs a notification command through the shell. The reminder is
+// passed in the TD_MESSAGE, TD_TITLE, TD_DUE, TD_WORKSPACE and TD_TASK_ID
+// environment variables, e.g. `notify-send td "$TD_MESSAGE"`.
+func RunHook(command string, p db.PendingReminder) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		"TD_MESSAGE="+Message(p),
+		"TD_TITLE="+p.Title,
+		"TD_DUE="+p.DueDate,
+		"TD_WORKSPACE="+p.WorkspaceName,
+		"TD_TASK_ID="+strconv.FormatInt(p.TaskID, 10),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
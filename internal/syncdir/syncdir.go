@@ -0,0 +1,503 @@
+// Package syncdir merges td databases on different machines through a shared
+// directory, such as a git checkout or a synced folder.
+//
+// Each machine writes a single file, <machine>.jsonl, holding one change
+// record per task: every synced field with the time it last changed and the
+// machine that changed it, or a tombstone for deleted tasks. Since no two
+// machines write the same file, git merges and file-sync services never see
+// conflicting edits. Merging takes the newest value of each field.
+package syncdir
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+// Field is a synced value, when it was set (Unix milliseconds) and by which
+// machine.
+type Field struct {
+	Value json.RawMessage `json:"value,omitempty"`
+	At    int64           `json:"at"`
+	By    string          `json:"by"`
+}
+
+// newer reports whether f wins over g. Ties go to the machine that sorts
+// last so every machine picks the same value.
+func (f Field) newer(g Field) bool {
+	return f.At > g.At || (f.At == g.At && f.By > g.By)
+}
+
+// Record is one task's change record.
+type Record struct {
+	UUID    string           `json:"uuid"`
+	Fields  map[string]Field `json:"fields,omitempty"`
+	Deleted *Field           `json:"deleted,omitempty"`
+}
+
+// alive reports whether the task exists: it was never deleted, or it was
+// edited after the deletion.
+func (r *Record) alive() bool {
+	if r.Deleted == nil {
+		return true
+	}
+	for _, f := range r.Fields {
+		if f.newer(*r.Deleted) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Record) lastEdit() Field {
+	var last Field
+	for _, f := range r.Fields {
+		if f.newer(last) {
+			last = f
+		}
+	}
+	return last
+}
+
+// merge folds o into r, keeping the newest value of each field.
+func (r *Record) merge(o *Record) {
+	if r.Fields == nil {
+		r.Fields = map[string]Field{}
+	}
+	for name, f := range o.Fields {
+		if cur, ok := r.Fields[name]; !ok || f.newer(cur) {
+			r.Fields[name] = f
+		}
+	}
+	if o.Deleted != nil && (r.Deleted == nil || o.Deleted.newer(*r.Deleted)) {
+		d := *o.Deleted
+		r.Deleted = &d
+	}
+}
+
+// Conflict is a field both this machine and another one changed since the
+// last sync. Field is "deleted" when one side deleted a task the other
+// edited, and "parent" when moves on both sides would have nested tasks in
+// a loop.
+type Conflict struct {
+	UUID  string
+	Title string
+	Field string
+	Kept  Field
+	Lost  Field
+}
+
+// Report summarises a sync.
+type Report struct {
+	Machines  []string // other machines found in the directory
+	Created   int
+	Updated   int
+	Deleted   int
+	Conflicts []Conflict
+	Wrote     bool // whether this machine's file changed
+}
+
+// Sync merges the records of the other machines in dir into the database,
+// then writes the merged state to dir/<machine>.jsonl. since is the time of
+// this machine's previous sync, in Unix milliseconds; changes on both sides
+// after it are reported as conflicts.
+func Sync(database *db.DB, dir, machine string, since int64) (*Report, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	local, err := localRecords(database, machine)
+	if err != nil {
+		return nil, err
+	}
+	remote, machines, err := readRecords(dir, machine)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Machines: machines}
+
+	merged := map[string]*Record{}
+	for id, l := range local {
+		r := &Record{UUID: id}
+		r.merge(l)
+		merged[id] = r
+	}
+	for id, rem := range remote {
+		if l, ok := local[id]; ok {
+			report.Conflicts = append(report.Conflicts, conflicts(l, rem, since)...)
+		}
+		if merged[id] == nil {
+			merged[id] = &Record{UUID: id}
+		}
+		merged[id].merge(rem)
+	}
+	report.Conflicts = append(report.Conflicts, breakParentLoops(merged)...)
+
+	if err := apply(database, local, merged, report); err != nil {
+		return nil, err
+	}
+	wrote, err := writeRecords(dir, machine, merged)
+	if err != nil {
+		return nil, err
+	}
+	report.Wrote = wrote
+	sort.Slice(report.Conflicts, func(i, j int) bool { return report.Conflicts[i].Title < report.Conflicts[j].Title })
+	return report, nil
+}
+
+// localRecords turns the database into change records. Clocks without a
+// machine belong to this one.
+func localRecords(database *db.DB, machine string) (map[string]*Record, error) {
+	tasks, err := database.GetSyncTasks()
+	if err != nil {
+		return nil, err
+	}
+	tombstones, err := database.GetTombstones()
+	if err != nil {
+		return nil, err
+	}
+
+	records := map[string]*Record{}
+	for _, t := range tasks {
+		values := map[string]interface{}{
+			"title":     t.Title,
+			"notes":     t.Notes,
+			"tags":      nonNil(t.Tags),
+			"due_date":  t.DueDate,
+			"priority":  t.Priority,
+			"status":    t.Status,
+			"estimate":  t.Estimate,
+			"parent":    t.ParentUUID,
+			"workspace": t.Workspace,
+		}
+		r := &Record{UUID: t.UUID, Fields: map[string]Field{}}
+		for _, name := range db.SyncFields {
+			raw, err := json.Marshal(values[name])
+			if err != nil {
+				return nil, err
+			}
+			c := t.Clocks[name]
+			r.Fields[name] = Field{Value: raw, At: c.At, By: machineOr(c.Machine, machine)}
+		}
+		records[t.UUID] = r
+	}
+	for id, c := range tombstones {
+		if _, ok := records[id]; ok {
+			continue
+		}
+		records[id] = &Record{UUID: id, Deleted: &Field{At: c.At, By: machineOr(c.Machine, machine)}}
+	}
+	return records, nil
+}
+
+func machineOr(m, fallback string) string {
+	if m == "" {
+		return fallback
+	}
+	return m
+}
+
+func nonNil(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// readRecords merges the files of every other machine in dir.
+func readRecords(dir, machine string) (map[string]*Record, []string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, nil, err
+	}
+	records := map[string]*Record{}
+	var machines []string
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+		if name == machine {
+			continue
+		}
+		machines = append(machines, name)
+		if err := readFile(path, records); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+		}
+	}
+	return records, machines, nil
+}
+
+func readFile(path string, records map[string]*Record) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if r.UUID == "" {
+			continue
+		}
+		if records[r.UUID] == nil {
+			records[r.UUID] = &Record{UUID: r.UUID}
+		}
+		records[r.UUID].merge(&r)
+	}
+	return scanner.Err()
+}
+
+// conflicts lists the fields of a task that changed both locally and
+// remotely since the last sync, to different values.
+func conflicts(l, r *Record, since int64) []Conflict {
+	title := l.title()
+	if title == "" {
+		title = r.title()
+	}
+	var out []Conflict
+	if l.alive() != r.alive() {
+		edit, del := l, r
+		if !l.alive() {
+			edit, del = r, l
+		}
+		e := edit.lastEdit()
+		if e.At > since && del.Deleted.At > since {
+			c := Conflict{UUID: l.UUID, Title: title, Field: "deleted", Kept: e, Lost: *del.Deleted}
+			if del.Deleted.newer(e) {
+				c.Kept, c.Lost = c.Lost, c.Kept
+			}
+			out = append(out, c)
+		}
+		return out
+	}
+	for _, name := range db.SyncFields {
+		lf, lok := l.Fields[name]
+		rf, rok := r.Fields[name]
+		if !lok || !rok || lf.By == rf.By || bytes.Equal(lf.Value, rf.Value) {
+			continue
+		}
+		if lf.At <= since || rf.At <= since {
+			continue
+		}
+		c := Conflict{UUID: l.UUID, Title: title, Field: name, Kept: lf, Lost: rf}
+		if rf.newer(lf) {
+			c.Kept, c.Lost = rf, lf
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (r *Record) title() string {
+	var s string
+	if f, ok := r.Fields["title"]; ok {
+		json.Unmarshal(f.Value, &s)
+	}
+	return s
+}
+
+func (r *Record) parent() string {
+	var s string
+	if f, ok := r.Fields["parent"]; ok {
+		json.Unmarshal(f.Value, &s)
+	}
+	return s
+}
+
+// breakParentLoops detaches tasks whose merged parents form a loop, which
+// happens when two machines nest two tasks under each other.
+func breakParentLoops(merged map[string]*Record) []Conflict {
+	var out []Conflict
+	ids := make([]string, 0, len(merged))
+	for id := range merged {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		seen := map[string]bool{id: true}
+		for p := merged[id].parent(); p != ""; {
+			if seen[p] {
+				f := merged[id].Fields["parent"]
+				out = append(out, Conflict{UUID: id, Title: merged[id].title(), Field: "parent", Lost: f})
+				f.Value = json.RawMessage(`""`)
+				merged[id].Fields["parent"] = f
+				break
+			}
+			seen[p] = true
+			next, ok := merged[p]
+			if !ok {
+				break
+			}
+			p = next.parent()
+		}
+	}
+	return out
+}
+
+// apply writes the merged records that differ from the local ones to the
+// database: deletions first, then tasks ordered so parents exist before
+// their subtasks.
+func apply(database *db.DB, local, merged map[string]*Record, report *Report) error {
+	var save []*Record
+	for id, m := range merged {
+		l, exists := local[id]
+		exists = exists && l.alive()
+		switch {
+		case !m.alive():
+			if exists {
+				if err := database.DeleteSyncedTask(id, clock(*m.Deleted)); err != nil {
+					return err
+				}
+				report.Deleted++
+			}
+		case !exists || !sameFields(l, m):
+			save = append(save, m)
+		}
+	}
+
+	depth := func(r *Record) int {
+		d := 0
+		for p := r.parent(); p != "" && d < len(merged); d++ {
+			next, ok := merged[p]
+			if !ok {
+				break
+			}
+			p = next.parent()
+		}
+		return d
+	}
+	sort.SliceStable(save, func(i, j int) bool {
+		if di, dj := depth(save[i]), depth(save[j]); di != dj {
+			return di < dj
+		}
+		return save[i].UUID < save[j].UUID
+	})
+
+	for _, m := range save {
+		t, err := toSyncTask(m)
+		if err != nil {
+			return fmt.Errorf("task %s: %v", m.UUID, err)
+		}
+		created, err := database.SaveSyncTask(t)
+		if err != nil {
+			return err
+		}
+		if created {
+			report.Created++
+		} else if !sameValues(local[m.UUID], m) {
+			report.Updated++
+		}
+	}
+	return nil
+}
+
+func clock(f Field) db.Clock {
+	return db.Clock{At: f.At, Machine: f.By}
+}
+
+func sameFields(a, b *Record) bool {
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for name, f := range a.Fields {
+		g, ok := b.Fields[name]
+		if !ok || f.At != g.At || f.By != g.By || !bytes.Equal(f.Value, g.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameValues(a, b *Record) bool {
+	if a == nil || !a.alive() {
+		return false
+	}
+	for name, f := range b.Fields {
+		if g, ok := a.Fields[name]; !ok || !bytes.Equal(f.Value, g.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// toSyncTask decodes a merged record. Fields missing from the record keep
+// their zero value and are dated to the beginning of time, so any real
+// value wins over them later.
+func toSyncTask(r *Record) (db.SyncTask, error) {
+	t := db.SyncTask{UUID: r.UUID, Clocks: map[string]db.Clock{}, Status: model.StatusOpen, Workspace: db.SyncWorkspace{Name: "Default"}}
+	targets := map[string]interface{}{
+		"title":     &t.Title,
+		"notes":     &t.Notes,
+		"tags":      &t.Tags,
+		"due_date":  &t.DueDate,
+		"priority":  &t.Priority,
+		"status":    &t.Status,
+		"estimate":  &t.Estimate,
+		"parent":    &t.ParentUUID,
+		"workspace": &t.Workspace,
+	}
+	for _, name := range db.SyncFields {
+		f, ok := r.Fields[name]
+		if !ok {
+			continue
+		}
+		if name == "workspace" && json.Unmarshal(f.Value, &t.Workspace.Name) == nil {
+			// written by versions that named workspaces only
+		} else if err := json.Unmarshal(f.Value, targets[name]); err != nil {
+			return t, fmt.Errorf("%s: %v", name, err)
+		}
+		t.Clocks[name] = clock(f)
+	}
+	if _, ok := model.ParseStatus(string(t.Status)); !ok {
+		t.Status = model.StatusOpen
+	}
+	return t, nil
+}
+
+// writeRecords replaces dir/<machine>.jsonl with the merged records, sorted
+// by UUID so that successive versions diff cleanly. The file is left alone
+// when nothing changed.
+func writeRecords(dir, machine string, merged map[string]*Record) (bool, error) {
+	ids := make([]string, 0, len(merged))
+	for id := range merged {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var buf bytes.Buffer
+	for _, id := range ids {
+		r := merged[id]
+		if !r.alive() {
+			r = &Record{UUID: id, Deleted: r.Deleted}
+		}
+		line, err := json.Marshal(r)
+		if err != nil {
+			return false, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	path := filepath.Join(dir, machine+".jsonl")
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, buf.Bytes()) {
+		return false, nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, path)
+}
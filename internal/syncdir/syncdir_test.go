@@ -0,0 +1,299 @@
+package syncdir
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+// machine is one side of a sync: its own database and the time of its last
+// sync, the way td sync keeps it in the sync_last setting.
+type machine struct {
+	name string
+	db   *db.DB
+	last int64
+}
+
+func newMachine(t *testing.T, name string) *machine {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "td.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return &machine{name: name, db: database}
+}
+
+func (m *machine) sync(t *testing.T, dir string) *Report {
+	t.Helper()
+	started := time.Now().UnixMilli()
+	report, err := Sync(m.db, dir, m.name, m.last)
+	if err != nil {
+		t.Fatalf("%s: sync: %v", m.name, err)
+	}
+	m.last = started
+	// Keep edits made after this sync clear of its start, since clocks
+	// count in milliseconds.
+	tick()
+	return report
+}
+
+// add creates a task, under parent when it is not empty, and returns its
+// UUID.
+func (m *machine) add(t *testing.T, title, parent string) string {
+	t.Helper()
+	workspaces, err := m.db.GetWorkspaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wsID int64
+	if len(workspaces) > 0 {
+		wsID = workspaces[0].ID
+	} else if wsID, err = m.db.CreateWorkspace("Home"); err != nil {
+		t.Fatal(err)
+	}
+	var parentID *int64
+	if parent != "" {
+		p := m.task(t, parent).ID
+		parentID = &p
+	}
+	id, err := m.db.AddTask(wsID, title, parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := m.db.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tick()
+	return task.UUID
+}
+
+// task returns the task with the given UUID, failing the test if it is gone.
+func (m *machine) task(t *testing.T, uuid string) *model.Task {
+	t.Helper()
+	task := m.find(t, uuid)
+	if task == nil {
+		t.Fatalf("%s: task %s not found", m.name, uuid)
+	}
+	return task
+}
+
+// find returns the task with the given UUID, or nil.
+func (m *machine) find(t *testing.T, uuid string) *model.Task {
+	t.Helper()
+	id, err := m.db.ResolveTask(uuid)
+	if err != nil {
+		return nil
+	}
+	task, err := m.db.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func (m *machine) edit(t *testing.T, uuid string, change func(*model.Task)) {
+	t.Helper()
+	task := m.task(t, uuid)
+	change(task)
+	if err := m.db.UpdateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	tick()
+}
+
+func (m *machine) move(t *testing.T, uuid, parent string) {
+	t.Helper()
+	p := m.task(t, parent).ID
+	if err := m.db.MoveTask(m.task(t, uuid).ID, &p); err != nil {
+		t.Fatal(err)
+	}
+	tick()
+}
+
+func (m *machine) parentOf(t *testing.T, uuid string) string {
+	t.Helper()
+	task := m.task(t, uuid)
+	if task.ParentID == nil {
+		return ""
+	}
+	parent, err := m.db.GetTask(*task.ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parent.UUID
+}
+
+// tick lets the millisecond clocks move on, so successive edits are ordered.
+func tick() {
+	time.Sleep(5 * time.Millisecond)
+}
+
+func TestRecordMerge(t *testing.T) {
+	raw := func(s string) json.RawMessage { return json.RawMessage(`"` + s + `"`) }
+	r := &Record{UUID: "u", Fields: map[string]Field{
+		"title": {Value: raw("old"), At: 10, By: "a"},
+		"notes": {Value: raw("mine"), At: 30, By: "a"},
+		"tags":  {Value: raw("tie a"), At: 20, By: "a"},
+	}}
+	r.merge(&Record{UUID: "u", Fields: map[string]Field{
+		"title": {Value: raw("new"), At: 20, By: "b"},
+		"notes": {Value: raw("theirs"), At: 25, By: "b"},
+		"tags":  {Value: raw("tie b"), At: 20, By: "b"},
+	}, Deleted: &Field{At: 15, By: "b"}})
+
+	for name, want := range map[string]string{"title": "new", "notes": "mine", "tags": "tie b"} {
+		if got := string(r.Fields[name].Value); got != `"`+want+`"` {
+			t.Errorf("%s = %s, want %q", name, got, want)
+		}
+	}
+	if r.Deleted == nil || r.Deleted.At != 15 {
+		t.Errorf("deleted = %+v, want the tombstone at 15", r.Deleted)
+	}
+	if !r.alive() {
+		t.Error("a task edited after its deletion should be alive")
+	}
+}
+
+func TestSyncMergesFieldsLastWriterWins(t *testing.T) {
+	dir := t.TempDir()
+	a, b := newMachine(t, "a"), newMachine(t, "b")
+	id := a.add(t, "Write report", "")
+	a.sync(t, dir)
+	if r := b.sync(t, dir); r.Created != 1 {
+		t.Fatalf("b created %d tasks, want 1", r.Created)
+	}
+
+	// Different fields on each side both survive; the same field on both
+	// sides goes to the later edit.
+	a.edit(t, id, func(t *model.Task) { t.Title = "Write the report"; t.Priority = 2 })
+	b.edit(t, id, func(t *model.Task) { t.DueDate = "2026-03-01"; t.Priority = 1 })
+
+	if r := a.sync(t, dir); len(r.Conflicts) != 0 {
+		t.Errorf("a reported conflicts before b wrote anything: %+v", r.Conflicts)
+	}
+	r := b.sync(t, dir)
+	if len(r.Conflicts) != 1 || r.Conflicts[0].Field != "priority" {
+		t.Fatalf("b conflicts = %+v, want one on priority", r.Conflicts)
+	}
+	if c := r.Conflicts[0]; c.Kept.By != "b" || c.Lost.By != "a" {
+		t.Errorf("priority conflict kept %s's value and lost %s's, want b's kept", c.Kept.By, c.Lost.By)
+	}
+	if r := a.sync(t, dir); len(r.Conflicts) != 0 || r.Updated != 1 {
+		t.Errorf("a: %d updated, conflicts %+v; want 1 updated and no conflicts", r.Updated, r.Conflicts)
+	}
+
+	for _, m := range []*machine{a, b} {
+		task := m.task(t, id)
+		if task.Title != "Write the report" || task.DueDate != "2026-03-01" || task.Priority != 1 {
+			t.Errorf("%s: title %q, due %q, priority %d; want a's title, b's due date and b's priority",
+				m.name, task.Title, task.DueDate, task.Priority)
+		}
+	}
+}
+
+func TestSyncDeletesAndResurrects(t *testing.T) {
+	dir := t.TempDir()
+	a, b := newMachine(t, "a"), newMachine(t, "b")
+	gone := a.add(t, "Gone", "")
+	kept := a.add(t, "Kept", "")
+	a.sync(t, dir)
+	b.sync(t, dir)
+
+	// A plain delete reaches the other machine.
+	if err := a.db.DeleteTask(a.task(t, gone).ID); err != nil {
+		t.Fatal(err)
+	}
+	tick()
+	a.sync(t, dir)
+	if r := b.sync(t, dir); r.Deleted != 1 || len(r.Conflicts) != 0 {
+		t.Fatalf("b: %d deleted, conflicts %+v; want 1 deleted and no conflicts", r.Deleted, r.Conflicts)
+	}
+	if b.find(t, gone) != nil {
+		t.Error("b still has the deleted task")
+	}
+
+	// An edit made after the other side deleted the task brings it back,
+	// and the delete is reported as the losing side of a conflict.
+	if err := a.db.DeleteTask(a.task(t, kept).ID); err != nil {
+		t.Fatal(err)
+	}
+	tick()
+	b.edit(t, kept, func(t *model.Task) { t.Title = "Kept after all" })
+	a.sync(t, dir)
+	r := b.sync(t, dir)
+	if len(r.Conflicts) != 1 || r.Conflicts[0].Field != "deleted" {
+		t.Fatalf("b conflicts = %+v, want one on deleted", r.Conflicts)
+	}
+	if c := r.Conflicts[0]; c.Kept.By != "b" || c.Lost.By != "a" {
+		t.Errorf("deleted conflict kept %s's side, want b's edit", c.Kept.By)
+	}
+	if r := a.sync(t, dir); r.Created != 1 {
+		t.Errorf("a created %d tasks, want the resurrected one", r.Created)
+	}
+	for _, m := range []*machine{a, b} {
+		if task := m.task(t, kept); task.Title != "Kept after all" {
+			t.Errorf("%s: title %q, want the edit that resurrected it", m.name, task.Title)
+		}
+	}
+}
+
+func TestSyncBreaksParentLoops(t *testing.T) {
+	dir := t.TempDir()
+	a, b := newMachine(t, "a"), newMachine(t, "b")
+	p := a.add(t, "P", "")
+	q := a.add(t, "Q", "")
+	a.sync(t, dir)
+	b.sync(t, dir)
+
+	// Each machine nests one task under the other.
+	a.move(t, q, p)
+	b.move(t, p, q)
+	a.sync(t, dir)
+	r := b.sync(t, dir)
+	loops := 0
+	for _, c := range r.Conflicts {
+		if c.Field == "parent" {
+			loops++
+		}
+	}
+	if loops != 1 {
+		t.Errorf("b conflicts = %+v, want one parent loop broken", r.Conflicts)
+	}
+	a.sync(t, dir)
+
+	for _, m := range []*machine{a, b} {
+		pp, qp := m.parentOf(t, p), m.parentOf(t, q)
+		if (pp == q) == (qp == p) {
+			t.Errorf("%s: P under %q, Q under %q; want exactly one nested in the other", m.name, pp, qp)
+		}
+	}
+	if a.parentOf(t, p) != b.parentOf(t, p) || a.parentOf(t, q) != b.parentOf(t, q) {
+		t.Error("the machines disagree on the tree after breaking the loop")
+	}
+}
+
+func TestSyncCreatesParentsBeforeSubtasks(t *testing.T) {
+	dir := t.TempDir()
+	a, b := newMachine(t, "a"), newMachine(t, "b")
+	// Deep enough that UUID order alone would put some subtask before its
+	// parent.
+	ids := []string{a.add(t, "Level 0", "")}
+	for i := 1; i < 6; i++ {
+		ids = append(ids, a.add(t, "Level", ids[i-1]))
+	}
+	a.sync(t, dir)
+	if r := b.sync(t, dir); r.Created != len(ids) {
+		t.Fatalf("b created %d tasks, want %d", r.Created, len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if got := b.parentOf(t, ids[i]); got != ids[i-1] {
+			t.Errorf("level %d: parent %q, want %q", i, got, ids[i-1])
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package tui
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// terminalOutput is the program's output. The renderer writes each frame in
+// one call, and alerts go through the same lock, so a bell or notification
+// escape lands between frames rather than inside one. It is still the
+// terminal's file, so bubbletea can size it and put it in raw mode.
+type terminalOutput struct {
+	*os.File
+	mu sync.Mutex
+}
+
+func (o *terminalOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.File.Write(p)
+}
+
+func (o *terminalOutput) WriteString(s string) (int, error) {
+	return o.Write([]byte(s))
+}
+
+// alert writes a bell or notification escape straight to the terminal. The
+// renderer only keeps the latest frame, so an escape drawn as part of one
+// could be replaced before it is ever flushed.
+func (a *App) alert(seq string) tea.Cmd {
+	out := a.output
+	if out == nil || seq == "" {
+		return nil
+	}
+	return func() tea.Msg {
+		_, _ = io.WriteString(out, seq)
+		return nil
+	}
+}
@@ -0,0 +1,288 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/appgram/td/internal/model"
+)
+
+// minBoardColumnW is the narrowest a board column may get before columns are
+// dropped from view and the board scrolls horizontally instead.
+const minBoardColumnW = 22
+
+type boardColumn struct {
+	title  string
+	status model.Status
+}
+
+var boardColumns = []boardColumn{
+	{"Todo", model.StatusOpen},
+	{"In progress", model.StatusInProgress},
+	{"Blocked", model.StatusBlocked},
+	{"Done", model.StatusDone},
+}
+
+func (a *App) executeBoardCommand(fields []string) {
+	show := !a.showBoard
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "on", "show":
+			show = true
+		case "off", "hide":
+			show = false
+		}
+	}
+	a.showBoard = show
+	if show {
+		a.showFind = false
+		a.showHelp = false
+		a.showAsciiList = false
+		a.showTags = false
+		a.showHistory = false
+		a.state.ActivePane = model.PaneTasks
+		a.boardCol = 0
+		a.boardRow = 0
+	}
+}
+
+// boardColumnOf returns the index of the column a task belongs in.
+func (a *App) boardColumnOf(task *model.Task) int {
+	status := task.EffectiveStatus()
+	switch {
+	case status.Closed() || a.taskIsComplete(task):
+		return 3
+	case status == model.StatusBlocked || status == model.StatusWaiting:
+		return 2
+	case status == model.StatusInProgress:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// boardCards lays out every task of the workspace, at any depth, into columns.
+func (a *App) boardCards() [][]*model.Task {
+	tasks := a.applyFilter(a.tasks, a.state.SearchQuery)
+	a.sortTasks(tasks)
+	var all []*model.Task
+	var collect func([]*model.Task)
+	collect = func(tasks []*model.Task) {
+		for _, t := range tasks {
+			all = append(all, t)
+			collect(t.Children)
+		}
+	}
+	collect(tasks)
+	if a.sortMode != sortManual {
+		sort.SliceStable(all, func(i, j int) bool {
+			return a.taskLess(all[i], all[j])
+		})
+	}
+
+	cards := make([][]*model.Task, len(boardColumns))
+	for _, t := range all {
+		col := a.boardColumnOf(t)
+		cards[col] = append(cards[col], t)
+	}
+	return cards
+}
+
+func (a *App) boardSelectedTask() *model.Task {
+	cards := a.boardCards()
+	if a.boardCol < 0 || a.boardCol >= len(cards) {
+		return nil
+	}
+	col := cards[a.boardCol]
+	if a.boardRow < 0 || a.boardRow >= len(col) {
+		return nil
+	}
+	return col[a.boardRow]
+}
+
+func (a *App) clampBoardCursor(cards [][]*model.Task) {
+	a.boardCol = clamp(a.boardCol, 0, len(boardColumns)-1)
+	a.boardRow = clamp(a.boardRow, 0, max(0, len(cards[a.boardCol])-1))
+}
+
+// handleBoardKey handles navigation and card moves while the board is shown,
+// given the key pressed and the action it is bound to: collapse and expand
+// change column and unindent and indent move the card, as do the arrow keys
+// with and without shift. It reports whether the key was consumed.
+func (a *App) handleBoardKey(key, action string) bool {
+	cards := a.boardCards()
+	switch key {
+	case "left":
+		action = "collapse"
+	case "right":
+		action = "expand"
+	case "shift+left":
+		action = "unindent"
+	case "shift+right":
+		action = "indent"
+	}
+	switch action {
+	case "collapse":
+		a.boardCol--
+	case "expand":
+		a.boardCol++
+	case "down":
+		a.boardRow++
+	case "up":
+		a.boardRow--
+	case "top":
+		a.boardRow = 0
+	case "bottom":
+		a.boardRow = len(cards[clamp(a.boardCol, 0, len(cards)-1)]) - 1
+	case "unindent":
+		a.moveBoardCard(-1)
+		return true
+	case "indent":
+		a.moveBoardCard(1)
+		return true
+	case "close":
+		a.showBoard = false
+		return true
+	default:
+		return false
+	}
+	a.clampBoardCursor(cards)
+	return true
+}
+
+// moveBoardCard moves the selected card to the neighbouring column, which
+// changes its status, and keeps the cursor on it.
+func (a *App) moveBoardCard(dir int) {
+	task := a.boardSelectedTask()
+	if task == nil {
+		return
+	}
+	target := a.boardColumnOf(task) + dir
+	if target < 0 || target >= len(boardColumns) {
+		return
+	}
+	status := boardColumns[target].status
+	if len(task.Children) > 0 && (status == model.StatusDone || a.boardColumnOf(task) == 3) {
+		// A parent's column follows its subtasks, so move them along with it.
+		a.setTaskTreeCompleted(task, status == model.StatusDone)
+	}
+	a.db.SetTaskStatus(task.ID, status)
+	a.loadTasks()
+	if len(task.BlockedBy) > 0 && !status.Closed() {
+		a.setMessage(fmt.Sprintf("#%d is still waiting on %d task(s)", task.ID, len(task.BlockedBy)))
+	}
+
+	id := task.ID
+	cards := a.boardCards()
+	for col, column := range cards {
+		for row, t := range column {
+			if t.ID == id {
+				a.boardCol, a.boardRow = col, row
+				return
+			}
+		}
+	}
+	a.clampBoardCursor(cards)
+}
+
+func (a *App) renderBoard(width, height int) string {
+	if height < 1 || width < 1 {
+		return ""
+	}
+	cards := a.boardCards()
+	a.clampBoardCursor(cards)
+
+	// Fit as many columns as the width allows, keeping the focused one visible.
+	visible := clamp(width/minBoardColumnW, 1, len(boardColumns))
+	first := clamp(a.boardCol-visible/2, 0, len(boardColumns)-visible)
+	colW := width / visible
+
+	var rendered []string
+	for i := first; i < first+visible; i++ {
+		w := colW
+		if i == first+visible-1 {
+			w = width - colW*(visible-1)
+		}
+		rendered = append(rendered, a.renderBoardColumn(i, cards[i], w, height, first > 0 && i == first, i == first+visible-1 && first+visible < len(boardColumns)))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, rendered...)
+}
+
+func (a *App) renderBoardColumn(idx int, cards []*model.Task, width, height int, moreLeft, moreRight bool) string {
+	innerW := width - 2
+	if innerW < 1 {
+		innerW = 1
+	}
+	focused := idx == a.boardCol
+
+	title := fmt.Sprintf("%s (%d)", boardColumns[idx].title, len(cards))
+	if moreLeft {
+		title = "‹ " + title
+	}
+	if moreRight {
+		title = title + " ›"
+	}
+	titleStyle := lipgloss.NewStyle().Foreground(dim)
+	if focused {
+		titleStyle = lipgloss.NewStyle().Foreground(accent).Bold(true)
+	}
+	lines := []string{
+		titleStyle.Render(truncateText(title, innerW)),
+		lipgloss.NewStyle().Foreground(borderColor).Render(strings.Repeat("─", innerW)),
+	}
+
+	visible := height - len(lines)
+	start := 0
+	if focused && a.boardRow >= visible {
+		start = a.boardRow - visible + 1
+	}
+	for i := start; i < len(cards) && i < start+visible; i++ {
+		line := a.renderBoardCard(cards[i], innerW)
+		if focused && i == a.boardRow {
+			line = lipgloss.NewStyle().Width(innerW).Background(cursorBg).Render(line)
+		}
+		lines = append(lines, line)
+	}
+
+	return lipgloss.NewStyle().
+		Width(width).
+		Height(height).
+		PaddingRight(2).
+		Background(bgColor).
+		Render(strings.Join(lines, "\n"))
+}
+
+func (a *App) renderBoardCard(task *model.Task, width int) string {
+	var meta []string
+	if icon := priorityIcon(task.Priority); icon != "" {
+		meta = append(meta, icon)
+	}
+	if task.DueDate != "" {
+		meta = append(meta, a.formatDue(task.DueDate))
+	}
+	right := strings.Join(meta, " ")
+
+	title := task.Title
+	avail := width
+	if right != "" {
+		avail = width - lipgloss.Width(right) - 1
+	}
+	title = truncateText(title, max(avail, 0))
+
+	style := lipgloss.NewStyle().Foreground(textColor)
+	if a.boardColumnOf(task) == 3 {
+		style = lipgloss.NewStyle().Strikethrough(true).Foreground(doneColor)
+	}
+	line := style.Render(title)
+	if right != "" {
+		padding := width - lipgloss.Width(title) - lipgloss.Width(right)
+		if padding < 1 {
+			padding = 1
+		}
+		line += strings.Repeat(" ", padding) + lipgloss.NewStyle().Foreground(dim).Render(right)
+	}
+	return line
+}
@@ -0,0 +1,389 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/appgram/td/internal/model"
+)
+
+// completion is the popup offering words for the one being typed before
+// the cursor. Tab and shift+Tab cycle through items, writing the selected
+// one into the buffer in place of what was typed.
+type completion struct {
+	start    int    // byte offset in the buffer of the word being completed
+	typed    string // the word as typed, restored when cycling back past the ends
+	tail     string // the input after the cursor
+	sigil    string // "#", "@" or "+", kept in front of every item
+	context  string // what the items are, for counting how often each is used
+	items    []string
+	selected int // -1 until Tab picks an item
+}
+
+// candidate is a word that can be offered, with how often it has been used.
+type candidate struct {
+	text string
+	uses int
+}
+
+// commandNames are the commands offered at the start of the command line,
+// roughly in order of how often they are typed.
+var commandNames = []string{
+	"due", "tag", "tags", "note", "priority", "status", "clear", "depends", "estimate",
+	"remind", "timer", "focus", "next", "find", "search", "sort", "group", "board",
+	"info", "dashboard", "ws", "scheme", "settings", "weather", "ascii", "history", "map", "config", "help", "q",
+}
+
+// commandAliases maps alternative command names to the one in commandNames
+// whose arguments they share.
+var commandAliases = map[string]string{
+	"workspace": "ws", "workspaces": "ws", "date": "due", "notes": "note",
+	"p": "priority", "s": "status", "depend": "depends", "dep": "depends", "deps": "depends",
+	"time": "timer", "est": "estimate", "reminder": "remind", "reminders": "remind",
+	"groupby": "group", "kanban": "board", "dash": "dashboard", "db": "dashboard", "art": "ascii",
+	"hist": "history",
+}
+
+// commandArgs are the fixed first arguments of commands.
+var commandArgs = map[string][]string{
+	"group":     {"tag", "due", "priority", "off"},
+	"board":     {"on", "off"},
+	"info":      {"on", "off"},
+	"dashboard": {"on", "off"},
+	"weather":   {"on", "off", "refresh", "city"},
+	"timer":     {"start", "stop"},
+	"priority":  {"high", "low", "normal"},
+	"status":    {"open", "wip", "waiting", "blocked", "done", "cancelled"},
+	"clear":     {"due", "tags", "priority", "notes", "status", "estimate", "remind", "deps", "all"},
+	"ascii":     {"list", "random", "hide"},
+	"settings":  {"pomodoro", "weather", "city", "unit"},
+	"depends":   {"rm"},
+	"remind":    {"clear"},
+	"history":   {"commands", "searches", "tasks", "clear"},
+	"config":    {"reload"},
+}
+
+// dateKeywords are the due date shortcuts model.ParseDueDate understands.
+var dateKeywords = []string{
+	"today", "tomorrow", "week", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday",
+}
+
+// workspaceSlug is how a workspace name is written as a single word, in
+// +workspace and :ws <name>.
+func workspaceSlug(name string) string {
+	return strings.ReplaceAll(name, " ", "-")
+}
+
+// inputBuffer is the buffer the current mode edits, or nil in normal mode.
+func (a *App) inputBuffer() *string {
+	switch a.state.Mode {
+	case model.ModeInsert:
+		return &a.taskInputBuf
+	case model.ModeCommand:
+		return &a.state.CommandBuf
+	case model.ModeSearch:
+		return &a.state.SearchBuf
+	}
+	return nil
+}
+
+// handleCompletionKey handles Tab and shift+Tab, and Esc while cycling,
+// which puts back what was typed. It reports whether the key was consumed.
+func (a *App) handleCompletionKey(msg tea.KeyMsg) bool {
+	buf := a.inputBuffer()
+	if buf == nil {
+		return false
+	}
+	c := a.completion
+	switch msg.String() {
+	case "tab", "shift+tab":
+		if c == nil {
+			if c = a.newCompletion(true); c == nil {
+				return true
+			}
+			a.completion = c
+		}
+		// Cycling passes through the typed word between the last item
+		// and the first.
+		n := len(c.items) + 1
+		step := 1
+		if msg.String() == "shift+tab" {
+			step = -1
+		}
+		c.selected = (c.selected+1+step+n)%n - 1
+		word := c.typed
+		if c.selected >= 0 {
+			word = c.sigil + c.items[c.selected]
+		}
+		*buf = (*buf)[:c.start] + word + c.tail
+		a.setInputCursor(c.start + len(word))
+		return true
+	case "esc":
+		if c == nil || c.selected < 0 {
+			return false
+		}
+		*buf = (*buf)[:c.start] + c.typed + c.tail
+		a.setInputCursor(c.start + len(c.typed))
+		a.completion = nil
+		return true
+	}
+	return false
+}
+
+// updateCompletion refreshes the popup after a key has changed the input,
+// counting a use of the item that was picked when typing carried on past it.
+func (a *App) updateCompletion() {
+	if c := a.completion; c != nil && c.selected >= 0 {
+		a.completionUses[c.context+c.items[c.selected]]++
+	}
+	a.completion = a.newCompletion(false)
+}
+
+// newCompletion offers completions for the word being typed. Unless force
+// is set, an empty word gets none.
+func (a *App) newCompletion(force bool) *completion {
+	if a.inputBuffer() == nil {
+		return nil
+	}
+	before, tail := a.inputBeforeCursor()
+	prefix, word := wordBefore(before)
+	if word == "" && !force {
+		return nil
+	}
+
+	sigil, context, candidates := a.completionCandidates(strings.Fields(prefix), word)
+	query := strings.TrimPrefix(word, sigil)
+	items := rankCandidates(query, candidates)
+	if len(items) == 0 || (len(items) == 1 && items[0] == query) {
+		return nil
+	}
+	return &completion{start: len(prefix), typed: word, tail: tail, sigil: sigil, context: context, items: items, selected: -1}
+}
+
+// completionCandidates picks what to offer for word, given the words before
+// it: tags after "#" anywhere, due dates after "@" and workspaces after "+"
+// in task input, and command names and arguments on the command line.
+func (a *App) completionCandidates(before []string, word string) (sigil, context string, candidates []candidate) {
+	mode := a.state.Mode
+	switch {
+	case strings.HasPrefix(word, "#"):
+		return "#", "#", a.tagCandidates()
+	case mode == model.ModeInsert && strings.HasPrefix(word, "@"):
+		return "@", "@", a.wordCandidates("@", dateKeywords)
+	case mode == model.ModeInsert && strings.HasPrefix(word, "+"):
+		return "+", "+", a.workspaceCandidates()
+	case mode != model.ModeCommand:
+		return "", "", nil
+	case len(before) == 0:
+		return "", ":", a.wordCandidates(":", commandNames)
+	}
+
+	command := strings.ToLower(before[0])
+	if alias, ok := commandAliases[command]; ok {
+		command = alias
+	}
+	context = ":" + command + " "
+	args := before[1:]
+	switch command {
+	case "due":
+		if len(args) == 0 {
+			candidates = a.wordCandidates(context, dateKeywords)
+		}
+	case "sort":
+		if len(args) == 0 {
+			candidates = a.wordCandidates(context, sortModes)
+		}
+	case "scheme":
+		if len(args) == 0 {
+			candidates = a.wordCandidates(context, append([]string{"list"}, availableSchemes()...))
+		}
+	case "ws":
+		switch {
+		case len(args) == 0:
+			candidates = append(a.wordCandidates(context, []string{"add", "rename", "delete", "select"}), a.workspaceCandidates()...)
+		case len(args) == 1 && (args[0] == "select" || args[0] == "open"):
+			candidates = a.workspaceCandidates()
+		}
+	case "tag":
+		switch {
+		case len(args) == 0:
+			candidates = append(a.wordCandidates(context, []string{"rename", "merge", "color"}), a.tagCandidates()...)
+		case len(args) == 2 && (args[0] == "color" || args[0] == "colour"):
+			colors := make([]string, 0, len(tagColorNames))
+			for name := range tagColorNames {
+				if name != "grey" {
+					colors = append(colors, name)
+				}
+			}
+			sort.Strings(colors)
+			candidates = a.wordCandidates(context+"color ", append(colors, "none"))
+		default:
+			candidates = a.tagCandidates()
+		}
+	case "map":
+		switch {
+		case len(args) == 0:
+			candidates = a.wordCandidates(context, []string{"preset"})
+		case args[0] == "preset":
+			if len(args) == 1 {
+				candidates = a.wordCandidates(context+"preset ", []string{"vim", "emacs"})
+			}
+		default:
+			names := make([]string, len(actions))
+			for i, act := range actions {
+				names[i] = act.name
+			}
+			candidates = a.wordCandidates(context, names)
+		}
+	default:
+		if len(args) == 0 {
+			candidates = a.wordCandidates(context, commandArgs[command])
+		}
+	}
+	return "", context, candidates
+}
+
+func (a *App) wordCandidates(context string, words []string) []candidate {
+	candidates := make([]candidate, len(words))
+	for i, w := range words {
+		candidates[i] = candidate{text: w, uses: a.completionUses[context+w]}
+	}
+	return candidates
+}
+
+// tagCandidates offers every tag, used as often as it is on tasks.
+func (a *App) tagCandidates() []candidate {
+	tags, err := a.db.GetTags()
+	if err != nil {
+		return nil
+	}
+	candidates := make([]candidate, len(tags))
+	for i, t := range tags {
+		candidates[i] = candidate{text: t.Name, uses: t.Total + a.completionUses["#"+t.Name]}
+	}
+	return candidates
+}
+
+// workspaceCandidates offers every workspace, used as often as it has tasks.
+func (a *App) workspaceCandidates() []candidate {
+	candidates := make([]candidate, len(a.workspaces))
+	for i, ws := range a.workspaces {
+		slug := workspaceSlug(ws.Name)
+		candidates[i] = candidate{text: slug, uses: ws.TaskCount + a.completionUses["+"+slug]}
+	}
+	return candidates
+}
+
+// rankCandidates returns the candidates matching query, best first: prefix
+// matches, then substring matches, then fuzzy ones; within each, the most
+// used first and otherwise in the order given.
+func rankCandidates(query string, candidates []candidate) []string {
+	type ranked struct {
+		text             string
+		tier, uses, gaps int
+	}
+	var matches []ranked
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if seen[c.text] {
+			continue
+		}
+		if tier, gaps, ok := fuzzyMatch(query, c.text); ok {
+			seen[c.text] = true
+			matches = append(matches, ranked{c.text, tier, c.uses, gaps})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].tier != matches[j].tier {
+			return matches[i].tier > matches[j].tier
+		}
+		if matches[i].uses != matches[j].uses {
+			return matches[i].uses > matches[j].uses
+		}
+		return matches[i].gaps < matches[j].gaps
+	})
+	items := make([]string, len(matches))
+	for i, m := range matches {
+		items[i] = m.text
+	}
+	return items
+}
+
+// fuzzyMatch reports whether the letters of query appear in text in order,
+// ignoring case. tier is 3 for a prefix match, 2 for a substring and 1 for
+// scattered letters; gaps counts the letters skipped between them.
+func fuzzyMatch(query, text string) (tier, gaps int, ok bool) {
+	q, t := strings.ToLower(query), strings.ToLower(text)
+	switch {
+	case q == "" || strings.HasPrefix(t, q):
+		return 3, 0, true
+	case strings.Contains(t, q):
+		return 2, strings.Index(t, q), true
+	}
+	qr := []rune(q)
+	i, last := 0, -1
+	for j, r := range []rune(t) {
+		if i < len(qr) && r == qr[i] {
+			if last >= 0 {
+				gaps += j - last - 1
+			}
+			last = j
+			i++
+		}
+	}
+	return 1, gaps, i == len(qr)
+}
+
+// renderCompletion draws the popup as one line of items, scrolled so the
+// selected one is in view.
+func (a *App) renderCompletion(width int) string {
+	c := a.completion
+	if c == nil || width <= 0 {
+		return ""
+	}
+	itemStyle := lipgloss.NewStyle().Foreground(dim).Background(borderColor)
+	selectedStyle := lipgloss.NewStyle().Foreground(accent).Background(cursorBg)
+
+	labels := make([]string, len(c.items))
+	for i, item := range c.items {
+		labels[i] = " " + c.sigil + item + " "
+	}
+	// Scroll forward until the selected item fits, leaving room for the
+	// markers that show items are cut off on either side.
+	first := 0
+	for first < c.selected {
+		w := 2
+		for _, l := range labels[first : c.selected+1] {
+			w += lipgloss.Width(l)
+		}
+		if w <= width {
+			break
+		}
+		first++
+	}
+
+	var b strings.Builder
+	used := 0
+	if first > 0 {
+		b.WriteString(itemStyle.Render("‹"))
+		used++
+	}
+	for i := first; i < len(labels); i++ {
+		w := lipgloss.Width(labels[i])
+		if used+w > width-1 && i < len(labels)-1 || used+w > width {
+			b.WriteString(itemStyle.Render("›"))
+			used++
+			break
+		}
+		style := itemStyle
+		if i == c.selected {
+			style = selectedStyle
+		}
+		b.WriteString(style.Render(labels[i]))
+		used += w
+	}
+	return b.String()
+}
@@ -0,0 +1,156 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/appgram/td/internal/config"
+)
+
+// defaultSidebarWidth is the width of the workspace list unless
+// config.toml sets sidebar_width.
+const defaultSidebarWidth = 24
+
+func (a *App) configPath() string {
+	return config.Path(filepath.Dir(a.db.Path()))
+}
+
+// loadConfig reads config.toml and puts its settings into effect. If the
+// file can't be read the settings already in effect stay.
+func (a *App) loadConfig() error {
+	cfg, err := config.Load(a.configPath())
+	if err != nil {
+		return err
+	}
+	a.config = cfg
+	return a.applyConfig()
+}
+
+// applyConfig puts the settings of config.toml back over whatever commands
+// changed during the session. A theme or art that doesn't exist is reported
+// without holding up the other settings.
+func (a *App) applyConfig() error {
+	cfg := a.config
+	var errs []error
+	if cfg.Dashboard != nil {
+		a.showDashboard = *cfg.Dashboard
+	}
+	if cfg.Theme != "" && !a.applyScheme(cfg.Theme) {
+		errs = append(errs, fmt.Errorf("%s: theme: unknown scheme %q", config.FileName, cfg.Theme))
+	}
+	switch cfg.AsciiArt {
+	case "":
+	case "random":
+		a.pickRandomAscii()
+	default:
+		found := false
+		for i, name := range a.asciiNames {
+			if name == cfg.AsciiArt {
+				a.asciiArt = a.asciiArts[i]
+				found = true
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("%s: ascii_art: no art named %q", config.FileName, cfg.AsciiArt))
+		}
+	}
+	a.headerCache = ""
+	return errors.Join(errs...)
+}
+
+// applyKeyConfig sets up the keymap from the [keys] table, which keys.conf
+// then builds on.
+func (a *App) applyKeyConfig() error {
+	keys := a.config.Keys
+	if keys.Preset != "" {
+		km, err := newKeymap(keys.Preset)
+		if err != nil {
+			return err
+		}
+		a.keymap = km
+	}
+	seqs := make([]string, 0, len(keys.Map))
+	for seq := range keys.Map {
+		seqs = append(seqs, seq)
+	}
+	sort.Strings(seqs)
+	for _, seq := range seqs {
+		if err := a.keymap.bind(seq, keys.Map[seq]); err != nil {
+			return err
+		}
+	}
+	for _, seq := range keys.Unmap {
+		if err := a.keymap.unbind(seq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectDefaultWorkspace opens the workspace config.toml names, when the
+// TUI starts.
+func (a *App) selectDefaultWorkspace() error {
+	if a.config.DefaultWorkspace == "" || len(a.workspaces) == 0 {
+		return nil
+	}
+	id, err := a.db.ResolveWorkspace(a.config.DefaultWorkspace)
+	if err != nil {
+		return fmt.Errorf("%s: default_workspace: %v", config.FileName, err)
+	}
+	for i, ws := range a.workspaces {
+		if ws.ID == id {
+			a.selectWorkspace(i)
+		}
+	}
+	return nil
+}
+
+// sidebarWidth is the width of the workspace list, at most half the screen.
+func (a *App) sidebarWidth() int {
+	w := defaultSidebarWidth
+	if a.config.SidebarWidth > 0 {
+		w = a.config.SidebarWidth
+	}
+	return min(w, a.width/2)
+}
+
+// formatDue shows a due date in the configured date format.
+func (a *App) formatDue(date string) string {
+	return a.config.FormatDate(date)
+}
+
+// executeConfigCommand handles :config, which shows where config.toml is,
+// and :config reload, which reads it again.
+func (a *App) executeConfigCommand(fields []string) {
+	if len(fields) < 2 {
+		path := a.configPath()
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			a.setMessage("config: " + path + " (not found)")
+			return
+		}
+		a.setMessage("config: " + path)
+		return
+	}
+	if fields[1] != "reload" {
+		a.setMessage("usage: :config [reload]")
+		return
+	}
+	if err := a.loadConfig(); err != nil {
+		a.setMessage("config: " + err.Error())
+		return
+	}
+	if err := a.loadKeymap(); err != nil {
+		a.setMessage("keys: " + err.Error())
+		return
+	}
+	a.setMessage("config reloaded")
+}
+
+// weekStart is the weekday weeks start on.
+func (a *App) weekStart() time.Weekday {
+	return a.config.FirstDayOfWeek()
+}
@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+// taskDeps caches the blockers and dependents of the task shown in the
+// details panel so they are not queried on every frame.
+type taskDeps struct {
+	taskID     int64
+	blockers   []db.DependencyRef
+	dependents []db.DependencyRef
+}
+
+func (a *App) dependenciesOf(task *model.Task) *taskDeps {
+	if a.deps == nil || a.deps.taskID != task.ID {
+		blockers, dependents, _ := a.db.GetDependencies(task.ID)
+		a.deps = &taskDeps{taskID: task.ID, blockers: blockers, dependents: dependents}
+	}
+	return a.deps
+}
+
+// addDependencies records the prerequisites typed inline with ~id and
+// reports the first one that could not be added.
+func (a *App) addDependencies(taskID int64, refs []string) {
+	for _, ref := range refs {
+		id, err := a.db.ResolveTask(ref)
+		if err == nil {
+			err = a.db.AddDependency(taskID, id)
+		}
+		if err != nil {
+			a.setMessage(dependencyError(ref, err))
+			return
+		}
+	}
+}
+
+func dependencyError(ref string, err error) string {
+	ref = strings.TrimLeft(ref, "#~")
+	if _, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		ref = "#" + ref
+	}
+	if errors.Is(err, db.ErrDependencyCycle) {
+		return fmt.Sprintf("cannot depend on %s: would create a cycle", ref)
+	}
+	return fmt.Sprintf("cannot depend on %s: %v", ref, err)
+}
+
+// executeDependsCommand handles ":depends <id>..." and ":depends rm <id>...".
+func (a *App) executeDependsCommand(fields []string) {
+	task := a.selectedTask()
+	if task == nil {
+		a.setMessage("no task selected")
+		return
+	}
+	if len(fields) < 2 {
+		deps := a.dependenciesOf(task)
+		if len(deps.blockers) == 0 {
+			a.setMessage(fmt.Sprintf("#%d has no dependencies", task.ID))
+		} else {
+			a.setMessage(fmt.Sprintf("#%d depends on %s", task.ID, formatDependencyRefs(deps.blockers)))
+		}
+		return
+	}
+
+	remove := false
+	args := fields[1:]
+	switch args[0] {
+	case "rm", "remove", "del", "delete":
+		remove = true
+		args = args[1:]
+	case "add":
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		a.setMessage("usage: :depends [rm] <id>")
+		return
+	}
+
+	for _, arg := range args {
+		ref, ok := model.ParseTaskRef(arg)
+		if !ok {
+			a.setMessage("invalid task id: " + arg)
+			return
+		}
+		id, err := a.db.ResolveTask(ref)
+		if err != nil {
+			a.setMessage(err.Error())
+			a.loadTasks()
+			return
+		}
+		if remove {
+			a.db.RemoveDependency(task.ID, id)
+			continue
+		}
+		if err := a.db.AddDependency(task.ID, id); err != nil {
+			a.setMessage(dependencyError(arg, err))
+			a.loadTasks()
+			return
+		}
+	}
+	a.loadTasks()
+	if remove {
+		a.setMessage(fmt.Sprintf("#%d: removed dependency", task.ID))
+	} else {
+		a.setMessage(fmt.Sprintf("#%d: added dependency", task.ID))
+	}
+}
+
+// executeNextCommand lists the unblocked next actions across all workspaces
+// in the search results view.
+func (a *App) executeNextCommand() {
+	hits, err := a.db.NextActions(findLimit)
+	if err != nil {
+		a.setMessage("next actions failed: " + err.Error())
+		return
+	}
+	if len(hits) == 0 {
+		a.setMessage("nothing to do right now")
+		return
+	}
+	a.showFindResults("", hits)
+}
+
+func formatDependencyRefs(refs []db.DependencyRef) string {
+	parts := make([]string, len(refs))
+	for i, r := range refs {
+		parts[i] = fmt.Sprintf("#%d %s", r.ID, r.Title)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"github.com/appgram/td/internal/model"
+)
+
+// remainingEstimate is the effort still ahead on a task. A parent sums the
+// remaining estimates of its open subtasks and only falls back to its own
+// estimate when none of them are estimated.
+func (a *App) remainingEstimate(task *model.Task) model.Estimate {
+	if a.taskIsComplete(task) {
+		return model.Estimate{}
+	}
+	var sum model.Estimate
+	for _, c := range task.Children {
+		sum = sum.Add(a.remainingEstimate(c))
+	}
+	if sum.IsZero() {
+		return task.Estimate
+	}
+	return sum
+}
+
+// workspaceRemaining sums the remaining effort of the top-level tasks.
+func (a *App) workspaceRemaining() model.Estimate {
+	var sum model.Estimate
+	for _, t := range a.tasks {
+		sum = sum.Add(a.remainingEstimate(t))
+	}
+	return sum
+}
+
+func (a *App) executeEstimateCommand(fields []string) {
+	task := a.selectedTask()
+	if task == nil {
+		a.setMessage("no task selected")
+		return
+	}
+	if len(fields) < 2 {
+		if task.Estimate.IsZero() {
+			a.setMessage("no estimate (e.g. :estimate 2h or :estimate 3p)")
+		} else {
+			a.setMessage("estimate: " + task.Estimate.String())
+		}
+		return
+	}
+	var est model.Estimate
+	for _, arg := range fields[1:] {
+		e, ok := model.ParseEstimate(arg)
+		if !ok {
+			a.setMessage("invalid estimate: " + arg)
+			return
+		}
+		est = est.Add(e)
+	}
+	task.Estimate = est
+	a.db.UpdateTask(task)
+	a.loadTasks()
+	a.setMessage("estimate: " + est.String())
+}
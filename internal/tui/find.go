@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+const findLimit = 200
+
+func (a *App) executeFindCommand(fields []string) {
+	query := strings.TrimSpace(strings.Join(fields[1:], " "))
+	if query == "" {
+		a.setMessage("usage: :find <query>")
+		return
+	}
+	hits, err := a.db.SearchTasks(query, findLimit)
+	if err != nil {
+		a.setMessage("search failed: " + err.Error())
+		return
+	}
+	if len(hits) == 0 {
+		a.setMessage("no matches for " + query)
+		return
+	}
+	a.showFindResults(query, hits)
+}
+
+// showFindResults opens the results view. An empty query means the hits are
+// the next actions list rather than search matches.
+func (a *App) showFindResults(query string, hits []db.SearchHit) {
+	a.findQuery = query
+	a.findResults = hits
+	a.findSelected = 0
+	a.findScroll = 0
+	a.showFind = true
+	a.showHelp = false
+	a.showAsciiList = false
+	a.showTags = false
+	a.showHistory = false
+	a.state.ActivePane = model.PaneTasks
+}
+
+// handleFindKey handles navigation inside the global search results, given
+// the action the key is bound to. It reports whether the key was consumed.
+func (a *App) handleFindKey(action string) bool {
+	switch action {
+	case "down":
+		a.findSelected = clamp(a.findSelected+1, 0, len(a.findResults)-1)
+	case "up":
+		a.findSelected = clamp(a.findSelected-1, 0, len(a.findResults)-1)
+	case "top":
+		a.findSelected = 0
+	case "bottom":
+		a.findSelected = len(a.findResults) - 1
+	case "open":
+		a.jumpToFindResult()
+	case "close":
+		a.showFind = false
+	default:
+		return false
+	}
+	return true
+}
+
+// jumpToFindResult switches to the workspace of the selected hit, expands its
+// ancestors and puts the cursor on it.
+func (a *App) jumpToFindResult() {
+	if a.findSelected < 0 || a.findSelected >= len(a.findResults) {
+		return
+	}
+	hit := a.findResults[a.findSelected]
+	a.showFind = false
+
+	wsIdx := -1
+	for i, ws := range a.workspaces {
+		if ws.ID == hit.WorkspaceID {
+			wsIdx = i
+			break
+		}
+	}
+	if wsIdx < 0 {
+		a.setMessage("workspace not found")
+		return
+	}
+
+	a.state.SearchQuery = ""
+	for _, id := range hit.AncestorIDs {
+		a.state.ExpandedTasks[id] = true
+	}
+	a.selectWorkspace(wsIdx)
+	a.state.ActivePane = model.PaneTasks
+	for i, line := range a.flatTasks {
+		if line.Task != nil && line.Task.ID == hit.TaskID {
+			a.state.SelectedTask = i
+			break
+		}
+	}
+}
+
+func (a *App) renderFindResults(width, height int) string {
+	if height < 1 {
+		return ""
+	}
+	dimStyle := lipgloss.NewStyle().Foreground(dim)
+	noun, what := "matches", fmt.Sprintf(" for %q", a.findQuery)
+	if a.findQuery == "" {
+		noun, what = "next actions", ""
+	}
+	if len(a.findResults) == 1 {
+		noun = strings.TrimSuffix(noun, "es")
+		noun = strings.TrimSuffix(noun, "s")
+	}
+	summary := fmt.Sprintf("%d %s%s  ·  enter to jump, esc to close", len(a.findResults), noun, what)
+	lines := []string{dimStyle.Render(truncateText(summary, width))}
+
+	visible := height - 1
+	if visible < 1 {
+		return strings.Join(lines, "\n")
+	}
+	if a.findSelected < a.findScroll {
+		a.findScroll = a.findSelected
+	} else if a.findSelected >= a.findScroll+visible {
+		a.findScroll = a.findSelected - visible + 1
+	}
+	end := a.findScroll + visible
+	if end > len(a.findResults) {
+		end = len(a.findResults)
+	}
+
+	for i := a.findScroll; i < end; i++ {
+		hit := a.findResults[i]
+		checkbox := "☐"
+		if hit.Completed {
+			checkbox = "☑"
+		}
+		crumbs := append([]string{hit.WorkspaceName}, hit.Path...)
+		where := strings.Join(crumbs, " › ") + " › "
+		tags := ""
+		if len(hit.Tags) > 0 {
+			tags = " " + formatTags(hit.Tags)
+		}
+		line := checkbox + " " + where + hit.Title + tags
+		if lipgloss.Width(line) > width {
+			line = truncateText(line, width)
+		} else {
+			line = checkbox + " " + dimStyle.Render(where) + hit.Title + dimStyle.Render(tags)
+		}
+		if i == a.findSelected {
+			line = lipgloss.NewStyle().Width(width).Background(cursorBg).Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
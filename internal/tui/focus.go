@@ -0,0 +1,335 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/appgram/td/internal/model"
+)
+
+type focusPhase int
+
+const (
+	focusWork focusPhase = iota
+	focusShortBreak
+	focusLongBreak
+)
+
+func (p focusPhase) String() string {
+	switch p {
+	case focusShortBreak:
+		return "short break"
+	case focusLongBreak:
+		return "long break"
+	default:
+		return "focus"
+	}
+}
+
+// pomodoroCycle is the length of a work interval, of the short and long
+// breaks, and how many work intervals come before a long break.
+type pomodoroCycle struct {
+	work      time.Duration
+	short     time.Duration
+	long      time.Duration
+	longEvery int
+}
+
+var defaultPomodoroCycle = pomodoroCycle{25 * time.Minute, 5 * time.Minute, 15 * time.Minute, 4}
+
+// parsePomodoroCycle reads "work/short/long/every" in minutes, e.g. "25/5/15/4".
+// Trailing parts may be left out and keep their defaults.
+func parsePomodoroCycle(s string) (pomodoroCycle, bool) {
+	cycle := defaultPomodoroCycle
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '/' || r == ' ' })
+	if len(parts) == 0 || len(parts) > 4 {
+		return cycle, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n <= 0 {
+			return cycle, false
+		}
+		switch i {
+		case 0:
+			cycle.work = time.Duration(n) * time.Minute
+		case 1:
+			cycle.short = time.Duration(n) * time.Minute
+		case 2:
+			cycle.long = time.Duration(n) * time.Minute
+		case 3:
+			cycle.longEvery = n
+		}
+	}
+	return cycle, true
+}
+
+func (c pomodoroCycle) String() string {
+	return fmt.Sprintf("%d/%d/%d/%d", int(c.work.Minutes()), int(c.short.Minutes()), int(c.long.Minutes()), c.longEvery)
+}
+
+func (c pomodoroCycle) length(p focusPhase) time.Duration {
+	switch p {
+	case focusShortBreak:
+		return c.short
+	case focusLongBreak:
+		return c.long
+	default:
+		return c.work
+	}
+}
+
+// focusSession is a running pomodoro cycle on one task.
+type focusSession struct {
+	taskID     int64
+	title      string
+	cycle      pomodoroCycle
+	phase      focusPhase
+	phaseStart time.Time
+	phaseEnd   time.Time
+	pausedLeft time.Duration // time left in the phase while paused, zero when running
+	done       int           // work intervals finished in this session
+	total      int           // pomodoros recorded on the task, earlier sessions included
+}
+
+func (f *focusSession) remaining(now time.Time) time.Duration {
+	if f.pausedLeft > 0 {
+		return f.pausedLeft
+	}
+	return f.phaseEnd.Sub(now)
+}
+
+func (a *App) loadPomodoroCycle() pomodoroCycle {
+	value, _ := a.db.GetSetting("pomodoro")
+	if cycle, ok := parsePomodoroCycle(value); ok {
+		return cycle
+	}
+	return defaultPomodoroCycle
+}
+
+// isPaneName reports whether ":focus <arg>" names a pane, which predates
+// focus mode and still switches panes.
+func isPaneName(fields []string) bool {
+	if len(fields) < 2 {
+		return false
+	}
+	switch fields[1] {
+	case "ws", "workspaces", "workspace", "tasks", "todos":
+		return true
+	}
+	return false
+}
+
+func (a *App) executeFocusCommand(fields []string) {
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "off", "stop", "quit":
+			a.stopFocus()
+		case "pause", "resume":
+			a.toggleFocusPause()
+		case "skip", "next":
+			a.advanceFocus(time.Now(), false)
+		default:
+			a.setMessage("usage: :focus [off|pause|skip]")
+		}
+		return
+	}
+	task := a.selectedTask()
+	if task == nil {
+		a.setMessage("no task selected")
+		return
+	}
+	a.startFocus(task)
+}
+
+func (a *App) startFocus(task *model.Task) {
+	now := time.Now()
+	cycle := a.loadPomodoroCycle()
+	total, _ := a.db.CountPomodoros(task.ID)
+	a.focus = &focusSession{
+		taskID:     task.ID,
+		title:      task.Title,
+		cycle:      cycle,
+		phase:      focusWork,
+		phaseStart: now,
+		phaseEnd:   now.Add(cycle.work),
+		total:      total,
+	}
+	a.showHelp = false
+	a.showFind = false
+	a.showAsciiList = false
+	a.showTags = false
+	a.showHistory = false
+}
+
+func (a *App) stopFocus() {
+	if a.focus == nil {
+		return
+	}
+	a.setMessage(fmt.Sprintf("focus ended: %d pomodoro(s) on %s", a.focus.done, a.focus.title))
+	a.focus = nil
+}
+
+func (a *App) toggleFocusPause() {
+	f := a.focus
+	if f == nil {
+		return
+	}
+	if f.pausedLeft > 0 {
+		f.phaseEnd = time.Now().Add(f.pausedLeft)
+		f.pausedLeft = 0
+		return
+	}
+	f.pausedLeft = f.remaining(time.Now())
+	if f.pausedLeft <= 0 {
+		f.pausedLeft = time.Second
+	}
+}
+
+// advanceFocus moves to the next phase. A work interval that ran to the end is
+// recorded as a pomodoro; skipped ones are not.
+func (a *App) advanceFocus(now time.Time, finished bool) {
+	f := a.focus
+	if f == nil {
+		return
+	}
+	next := focusWork
+	if f.phase == focusWork {
+		if finished {
+			if err := a.db.AddPomodoro(f.taskID, f.phaseStart, now); err == nil {
+				f.total++
+			}
+			f.done++
+		}
+		next = focusShortBreak
+		if f.cycle.longEvery > 0 && f.done > 0 && f.done%f.cycle.longEvery == 0 && finished {
+			next = focusLongBreak
+		}
+	}
+	f.phase = next
+	f.phaseStart = now
+	f.phaseEnd = now.Add(f.cycle.length(next))
+	f.pausedLeft = 0
+}
+
+// tickFocus advances the cycle when the current phase runs out, returning
+// a command that rings the bell at each transition.
+func (a *App) tickFocus(now time.Time) tea.Cmd {
+	f := a.focus
+	if f == nil || f.pausedLeft > 0 || now.Before(f.phaseEnd) {
+		return nil
+	}
+	a.advanceFocus(now, true)
+	return a.alert("\a")
+}
+
+// handleFocusKey handles keys while the focus view is shown. Everything but
+// command mode and ctrl+c is swallowed so the hidden list can't change. It
+// reports whether the key was consumed.
+func (a *App) handleFocusKey(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case ":", "ctrl+c":
+		return false
+	case " ", "p":
+		a.toggleFocusPause()
+	case "n":
+		a.advanceFocus(time.Now(), false)
+	case "x":
+		if task := a.taskIndex[a.focus.taskID]; task != nil && !a.taskIsComplete(task) {
+			if len(task.Children) > 0 {
+				a.setTaskTreeCompleted(task, true)
+			} else {
+				a.db.SetTaskCompleted(task.ID, true)
+			}
+			a.loadTasks()
+		}
+		a.stopFocus()
+	case "esc", "q":
+		a.stopFocus()
+	}
+	return true
+}
+
+func (a *App) renderFocus(width, height int) string {
+	f := a.focus
+	now := time.Now()
+
+	color := accent
+	if f.phase != focusWork {
+		color = headerColor
+	}
+	if f.pausedLeft > 0 {
+		color = dim
+	}
+	dimStyle := lipgloss.NewStyle().Foreground(dim).Background(bgColor)
+	titleStyle := lipgloss.NewStyle().Foreground(textColor).Background(bgColor).Bold(true)
+
+	left := f.remaining(now)
+	if left < 0 {
+		left = 0
+	}
+	secs := int(left.Round(time.Second).Seconds())
+	clock := fmt.Sprintf("%02d:%02d", secs/60, secs%60)
+
+	phase := f.phase.String()
+	if f.pausedLeft > 0 {
+		phase += " · paused"
+	}
+	round := fmt.Sprintf("pomodoro %d of %d before a long break", f.done%f.cycle.longEvery+1, f.cycle.longEvery)
+	if f.phase != focusWork {
+		round = fmt.Sprintf("%d done this session", f.done)
+	}
+
+	lines := []string{
+		titleStyle.Render(truncateText(f.title, width-4)),
+		"",
+		lipgloss.NewStyle().Foreground(color).Background(bgColor).Render(bigText(clock)),
+		"",
+		lipgloss.NewStyle().Foreground(color).Background(bgColor).Render(phase),
+		dimStyle.Render(round),
+		dimStyle.Render(fmt.Sprintf("%s %d on this task", strings.Repeat("●", min(f.total, 12)), f.total)),
+		"",
+		dimStyle.Render("space pause · n skip · x complete · esc leave"),
+	}
+	content := lipgloss.JoinVertical(lipgloss.Center, lines...)
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, content,
+		lipgloss.WithWhitespaceBackground(bgColor))
+}
+
+// bigDigits is a 5-row block font for the focus countdown.
+var bigDigits = map[rune][5]string{
+	'0': {"█████", "█   █", "█   █", "█   █", "█████"},
+	'1': {"  █  ", " ██  ", "  █  ", "  █  ", " ███ "},
+	'2': {"█████", "    █", "█████", "█    ", "█████"},
+	'3': {"█████", "    █", " ████", "    █", "█████"},
+	'4': {"█   █", "█   █", "█████", "    █", "    █"},
+	'5': {"█████", "█    ", "█████", "    █", "█████"},
+	'6': {"█████", "█    ", "█████", "█   █", "█████"},
+	'7': {"█████", "    █", "   █ ", "  █  ", "  █  "},
+	'8': {"█████", "█   █", "█████", "█   █", "█████"},
+	'9': {"█████", "█   █", "█████", "    █", "█████"},
+	':': {"   ", " █ ", "   ", " █ ", "   "},
+}
+
+func bigText(s string) string {
+	var rows [5][]string
+	for _, r := range s {
+		glyph, ok := bigDigits[r]
+		if !ok {
+			continue
+		}
+		for i := range rows {
+			rows[i] = append(rows[i], glyph[i])
+		}
+	}
+	out := make([]string, len(rows))
+	for i, row := range rows {
+		out[i] = strings.Join(row, " ")
+	}
+	return strings.Join(out, "\n")
+}
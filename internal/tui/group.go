@@ -0,0 +1,267 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/appgram/td/internal/model"
+)
+
+const (
+	groupNone     = ""
+	groupTag      = "tag"
+	groupDue      = "due"
+	groupPriority = "priority"
+)
+
+// taskGroup is one collapsible section of a grouped view.
+type taskGroup struct {
+	key    string
+	label  string
+	prefix string // inline syntax pre-filled when adding a task to this group
+	tasks  []*model.Task
+	done   int
+}
+
+func parseGroupMode(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "off", "none", "tree", "":
+		return groupNone, true
+	case "tag", "tags", "t":
+		return groupTag, true
+	case "due", "date", "d":
+		return groupDue, true
+	case "priority", "p":
+		return groupPriority, true
+	}
+	return "", false
+}
+
+func groupSettingKey(workspaceID int64) string {
+	return fmt.Sprintf("group_by_%d", workspaceID)
+}
+
+// loadGroupMode reads the persisted group-by mode for the current workspace.
+func (a *App) loadGroupMode() {
+	a.groupBy = groupNone
+	if a.state.SelectedWS >= len(a.workspaces) {
+		return
+	}
+	value, _ := a.db.GetSetting(groupSettingKey(a.workspaces[a.state.SelectedWS].ID))
+	if mode, ok := parseGroupMode(value); ok {
+		a.groupBy = mode
+	}
+}
+
+func (a *App) executeGroupCommand(fields []string) {
+	if len(fields) < 2 {
+		current := a.groupBy
+		if current == groupNone {
+			current = "off"
+		}
+		a.setMessage("group: " + current + " (tag, due, priority, off)")
+		return
+	}
+	mode, ok := parseGroupMode(fields[1])
+	if !ok {
+		a.setMessage("unknown group: " + fields[1])
+		return
+	}
+	a.groupBy = mode
+	if a.state.SelectedWS < len(a.workspaces) {
+		_ = a.db.SetSetting(groupSettingKey(a.workspaces[a.state.SelectedWS].ID), mode)
+	}
+	a.state.SelectedTask = 0
+	a.taskScroll = 0
+	a.flattenTasks()
+	if mode == groupNone {
+		a.setMessage("group: off")
+	} else {
+		a.setMessage("group: " + mode)
+	}
+}
+
+// walkGroups is the grouped counterpart of walkTasks: it flattens the whole
+// tree and lays the tasks out under one header line per non-empty group.
+func (a *App) walkGroups(tasks []*model.Task) {
+	var all []*model.Task
+	var collect func([]*model.Task)
+	collect = func(tasks []*model.Task) {
+		for _, t := range tasks {
+			all = append(all, t)
+			collect(t.Children)
+		}
+	}
+	collect(tasks)
+	if a.sortMode != sortManual {
+		sort.SliceStable(all, func(i, j int) bool {
+			return a.taskLess(all[i], all[j])
+		})
+	}
+
+	for _, group := range a.buildGroups(all) {
+		a.flatTasks = append(a.flatTasks, TaskLine{
+			Group:    group,
+			Expanded: !a.collapsedGroups[group.key],
+			Index:    len(a.flatTasks),
+		})
+		if a.collapsedGroups[group.key] {
+			continue
+		}
+		for _, t := range group.tasks {
+			a.flatTasks = append(a.flatTasks, TaskLine{
+				Task:  t,
+				Depth: 1,
+				Group: group,
+				Index: len(a.flatTasks),
+			})
+		}
+	}
+}
+
+func (a *App) buildGroups(tasks []*model.Task) []*taskGroup {
+	var groups []*taskGroup
+	byKey := make(map[string]*taskGroup)
+	add := func(key, label, prefix string, t *model.Task) {
+		g := byKey[key]
+		if g == nil {
+			g = &taskGroup{key: key, label: label, prefix: prefix}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.tasks = append(g.tasks, t)
+		if a.taskIsComplete(t) {
+			g.done++
+		}
+	}
+
+	switch a.groupBy {
+	case groupTag:
+		for _, t := range tasks {
+			if len(t.Tags) == 0 {
+				add("tag:", "untagged", "", t)
+				continue
+			}
+			for _, tag := range t.Tags {
+				add("tag:"+strings.ToLower(tag), "#"+tag, "#"+tag+" ", t)
+			}
+		}
+		sort.SliceStable(groups, func(i, j int) bool {
+			// Untagged goes last; tags are alphabetical.
+			if groups[i].key == "tag:" || groups[j].key == "tag:" {
+				return groups[j].key == "tag:"
+			}
+			return groups[i].key < groups[j].key
+		})
+	case groupDue:
+		for _, t := range tasks {
+			bucket := dueBucket(t.DueDate, time.Now(), a.weekStart())
+			add("due:"+bucket, bucket, dueBucketPrefix[bucket], t)
+		}
+		sortGroupsBy(groups, dueBuckets)
+	case groupPriority:
+		for _, t := range tasks {
+			name := priorityName(t.Priority)
+			add("priority:"+name, name, "!"+name+" ", t)
+		}
+		sortGroupsBy(groups, []string{"high", "normal", "low"})
+	}
+	return groups
+}
+
+var dueBuckets = []string{"overdue", "today", "this week", "later", "none"}
+
+var dueBucketPrefix = map[string]string{
+	"today": "@today ",
+}
+
+// dueBucket classifies a due date relative to now. "this week" runs until the
+// end of the current week, which starts on weekStart.
+func dueBucket(due string, now time.Time, weekStart time.Weekday) string {
+	if due == "" {
+		return "none"
+	}
+	today := now.Format("2006-01-02")
+	switch {
+	case due < today:
+		return "overdue"
+	case due == today:
+		return "today"
+	}
+	daysLeft := (int(weekStart) + 6 - int(now.Weekday())) % 7
+	endOfWeek := now.AddDate(0, 0, daysLeft).Format("2006-01-02")
+	if due <= endOfWeek {
+		return "this week"
+	}
+	return "later"
+}
+
+func sortGroupsBy(groups []*taskGroup, order []string) {
+	rank := func(g *taskGroup) int {
+		for i, name := range order {
+			if g.label == name {
+				return i
+			}
+		}
+		return len(order)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return rank(groups[i]) < rank(groups[j])
+	})
+}
+
+func priorityName(priority int) string {
+	switch {
+	case priority >= 2:
+		return "high"
+	case priority == 1:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+func (a *App) renderGroupHeader(group *taskGroup, collapsed bool, width int) string {
+	arrow := "▾"
+	if collapsed {
+		arrow = "▸"
+	}
+	count := fmt.Sprintf("%d/%d", group.done, len(group.tasks))
+	label := truncateText(arrow+" "+group.label, width-lipgloss.Width(count)-2)
+	return lipgloss.NewStyle().Foreground(accent).Bold(true).Render(label) + "  " +
+		lipgloss.NewStyle().Foreground(dim).Render(count)
+}
+
+// selectedGroup returns the section the cursor is in, if the view is grouped.
+func (a *App) selectedGroup() *taskGroup {
+	line, ok := a.selectedLine()
+	if !ok {
+		return nil
+	}
+	return line.Group
+}
+
+// toggleGroup collapses or expands the section under the cursor and keeps the
+// cursor on its header.
+func (a *App) toggleGroup(collapse bool) {
+	group := a.selectedGroup()
+	if group == nil {
+		return
+	}
+	if collapse {
+		a.collapsedGroups[group.key] = true
+	} else {
+		delete(a.collapsedGroups, group.key)
+	}
+	a.flattenTasks()
+	for i, line := range a.flatTasks {
+		if line.Task == nil && line.Group != nil && line.Group.key == group.key {
+			a.state.SelectedTask = i
+			break
+		}
+	}
+}
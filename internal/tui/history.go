@@ -0,0 +1,375 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+// historyKinds are the kinds of history :history cycles through with Tab,
+// with the names the command accepts for them.
+var historyKinds = []struct{ kind, name string }{
+	{db.HistoryCommand, "commands"},
+	{db.HistorySearch, "searches"},
+	{db.HistoryTask, "tasks"},
+}
+
+// inputHistory is what up and down recall in the current input mode.
+type inputHistory struct {
+	lines []string // oldest first
+	index int      // the line recalled; len(lines) when back at the draft
+	draft string   // what was typed before recalling; recall only offers lines starting with it
+}
+
+// historySearch is a ctrl+r search back through the history.
+type historySearch struct {
+	query          string
+	match          int // index of the line found, -1 for none yet
+	failed         bool
+	original       string
+	originalCursor int
+}
+
+// historyKind is the kind of history the current mode records and recalls.
+func (a *App) historyKind() string {
+	switch a.state.Mode {
+	case model.ModeCommand:
+		return db.HistoryCommand
+	case model.ModeSearch:
+		return db.HistorySearch
+	case model.ModeInsert:
+		return db.HistoryTask
+	}
+	return ""
+}
+
+// startHistory loads the history for the input mode just entered.
+func (a *App) startHistory() {
+	a.history = nil
+	a.histSearch = nil
+	kind := a.historyKind()
+	if kind == "" {
+		return
+	}
+	lines, _ := a.db.History(kind)
+	a.history = &inputHistory{lines: lines, index: len(lines)}
+}
+
+// addHistory records line in the history of the given kind.
+func (a *App) addHistory(kind, line string) {
+	if err := a.db.AddHistory(kind, line); err != nil {
+		a.setMessage("history: " + err.Error())
+	}
+}
+
+// loadHistoryUses counts how often each command was run in earlier
+// sessions, so completion ranks the usual ones first.
+func (a *App) loadHistoryUses() {
+	lines, _ := a.db.History(db.HistoryCommand)
+	for _, line := range lines {
+		fields := strings.Fields(strings.ToLower(line))
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		if alias, ok := commandAliases[name]; ok {
+			name = alias
+		}
+		a.completionUses[":"+name]++
+	}
+}
+
+// handleHistoryKey handles up and down, which recall earlier lines, and
+// ctrl+r, which searches them. It reports whether the key was consumed.
+func (a *App) handleHistoryKey(msg tea.KeyMsg) bool {
+	if a.history == nil || a.inputBuffer() == nil {
+		return false
+	}
+	if a.histSearch != nil {
+		return a.handleHistorySearchKey(msg)
+	}
+	switch msg.String() {
+	case "up", "ctrl+p":
+		a.recallHistory(-1)
+	case "down", "ctrl+n":
+		a.recallHistory(1)
+	case "ctrl+r":
+		buf := a.inputBuffer()
+		a.histSearch = &historySearch{match: -1, original: *buf, originalCursor: a.inputCursor}
+		a.searchHistory(len(a.history.lines))
+	default:
+		return false
+	}
+	a.completion = nil
+	return true
+}
+
+// recallHistory steps dir lines back or forward through the lines that
+// start with what was typed, ending back at the typed text itself.
+func (a *App) recallHistory(dir int) {
+	h := a.history
+	buf := a.inputBuffer()
+	if h.index == len(h.lines) {
+		h.draft = *buf
+	}
+	for i := h.index + dir; i >= 0 && i <= len(h.lines); i += dir {
+		if i == len(h.lines) {
+			h.index = i
+			*buf = h.draft
+			a.cursorToEnd()
+			return
+		}
+		if strings.HasPrefix(h.lines[i], h.draft) {
+			h.index = i
+			*buf = h.lines[i]
+			a.cursorToEnd()
+			return
+		}
+	}
+}
+
+// searchHistory finds the newest line before index from that contains the
+// query, ignoring case, and shows it in the input.
+func (a *App) searchHistory(from int) {
+	s := a.histSearch
+	query := strings.ToLower(s.query)
+	for i := from - 1; i >= 0; i-- {
+		if strings.Contains(strings.ToLower(a.history.lines[i]), query) {
+			s.match = i
+			s.failed = false
+			return
+		}
+	}
+	s.failed = true
+}
+
+// handleHistorySearchKey edits the ctrl+r query. ctrl+r again finds an
+// older match and Esc gives up; any other key takes the match into the
+// input and then does what it normally does, so Enter runs it.
+func (a *App) handleHistorySearchKey(msg tea.KeyMsg) bool {
+	s := a.histSearch
+	buf := a.inputBuffer()
+	switch msg.String() {
+	case "ctrl+r":
+		from := s.match
+		if from < 0 {
+			from = len(a.history.lines)
+		}
+		a.searchHistory(from)
+	case "backspace", "ctrl+h":
+		if q := []rune(s.query); len(q) > 0 {
+			s.query = string(q[:len(q)-1])
+		}
+		a.searchHistory(len(a.history.lines))
+	case "esc", "ctrl+g", "ctrl+c":
+		*buf = s.original
+		a.inputCursor = s.originalCursor
+		a.histSearch = nil
+	default:
+		switch {
+		case msg.Type == tea.KeySpace:
+			s.query += " "
+		case msg.Type == tea.KeyRunes && !msg.Alt:
+			s.query += string(msg.Runes)
+		default:
+			return a.acceptHistorySearch()
+		}
+		// A longer query can still match the line already found.
+		from := s.match + 1
+		if s.match < 0 {
+			from = len(a.history.lines)
+		}
+		a.searchHistory(from)
+	}
+	return true
+}
+
+// acceptHistorySearch takes the line found into the input and ends the
+// search, leaving the key that ended it to be handled as usual.
+func (a *App) acceptHistorySearch() bool {
+	s := a.histSearch
+	buf := a.inputBuffer()
+	if s.match >= 0 {
+		*buf = a.history.lines[s.match]
+		a.history.index = s.match
+		a.cursorToEnd()
+	}
+	a.histSearch = nil
+	return false
+}
+
+// renderHistorySearch draws the ctrl+r prompt in place of the input.
+func (a *App) renderHistorySearch(width int) string {
+	s := a.histSearch
+	prompt := "(reverse-i-search)"
+	if s.failed {
+		prompt = "(failed reverse-i-search)"
+	}
+	match := ""
+	if s.match >= 0 {
+		match = a.history.lines[s.match]
+	}
+	line := lipgloss.NewStyle().Foreground(dim).Render(fmt.Sprintf("%s'%s': ", prompt, s.query)) +
+		lipgloss.NewStyle().Foreground(accent).Render(match)
+	return truncateText(line, width)
+}
+
+func (a *App) executeHistoryCommand(fields []string) {
+	kind := db.HistoryCommand
+	clearing := false
+	for _, f := range fields[1:] {
+		if f == "clear" {
+			clearing = true
+			kind = ""
+			continue
+		}
+		k, ok := historyKindNamed(f)
+		if !ok {
+			a.setMessage("usage: :history [clear] [commands|searches|tasks]")
+			return
+		}
+		kind = k
+	}
+	if clearing {
+		n, err := a.db.ClearHistory(kind)
+		if err != nil {
+			a.setMessage("history: " + err.Error())
+			return
+		}
+		a.setMessage(fmt.Sprintf("cleared %d history line(s)", n))
+		return
+	}
+	a.showHistoryList(kind)
+}
+
+func historyKindNamed(name string) (string, bool) {
+	for _, k := range historyKinds {
+		if name == k.name || name == k.kind {
+			return k.kind, true
+		}
+	}
+	return "", false
+}
+
+// showHistoryList opens the list of history lines of the given kind,
+// newest first.
+func (a *App) showHistoryList(kind string) {
+	lines, err := a.db.History(kind)
+	if err != nil {
+		a.setMessage("history: " + err.Error())
+		return
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	a.historyListKind = kind
+	a.historyList = lines
+	a.historySelected = 0
+	a.historyScroll = 0
+	a.showHistory = true
+	a.showFind = false
+	a.showHelp = false
+	a.showAsciiList = false
+	a.showTags = false
+	a.state.ActivePane = model.PaneTasks
+}
+
+// handleHistoryListKey handles navigation inside the :history list, given
+// the key pressed and the action it is bound to. Tab always switches kind.
+// It reports whether the key was consumed.
+func (a *App) handleHistoryListKey(key, action string) bool {
+	if key == "tab" {
+		for i, k := range historyKinds {
+			if k.kind == a.historyListKind {
+				a.showHistoryList(historyKinds[(i+1)%len(historyKinds)].kind)
+				break
+			}
+		}
+		return true
+	}
+	switch action {
+	case "down":
+		a.historySelected = clamp(a.historySelected+1, 0, len(a.historyList)-1)
+	case "up":
+		a.historySelected = clamp(a.historySelected-1, 0, len(a.historyList)-1)
+	case "top":
+		a.historySelected = 0
+	case "bottom":
+		a.historySelected = len(a.historyList) - 1
+	case "open":
+		if a.historySelected < len(a.historyList) {
+			a.reuseHistoryLine(a.historyListKind, a.historyList[a.historySelected])
+		}
+	case "close":
+		a.showHistory = false
+	default:
+		return false
+	}
+	return true
+}
+
+// reuseHistoryLine puts line back into the input it was typed in, ready to
+// be edited or run again.
+func (a *App) reuseHistoryLine(kind, line string) {
+	switch kind {
+	case db.HistoryCommand:
+		a.showHistory = false
+		a.openCommandWithBuffer(":", line)
+	case db.HistorySearch:
+		a.showHistory = false
+		a.state.Mode = model.ModeSearch
+		a.state.SearchBuf = line
+	case db.HistoryTask:
+		if a.state.SelectedWS >= len(a.workspaces) {
+			return
+		}
+		a.showHistory = false
+		a.addTask()
+		a.taskInputBuf = line
+	}
+}
+
+func (a *App) renderHistoryList(width, height int) string {
+	if height < 1 {
+		return ""
+	}
+	dimStyle := lipgloss.NewStyle().Foreground(dim)
+	name := a.historyListKind
+	for _, k := range historyKinds {
+		if k.kind == a.historyListKind {
+			name = k.name
+		}
+	}
+	summary := fmt.Sprintf("%s (%d), newest first  ·  enter reuse, tab next kind, esc close", name, len(a.historyList))
+	lines := []string{dimStyle.Render(truncateText(summary, width))}
+	if len(a.historyList) == 0 {
+		lines = append(lines, dimStyle.Render("nothing yet"))
+		return strings.Join(lines, "\n")
+	}
+
+	visible := height - 1
+	if visible < 1 {
+		return strings.Join(lines, "\n")
+	}
+	if a.historySelected < a.historyScroll {
+		a.historyScroll = a.historySelected
+	} else if a.historySelected >= a.historyScroll+visible {
+		a.historyScroll = a.historySelected - visible + 1
+	}
+	end := min(a.historyScroll+visible, len(a.historyList))
+
+	leader := map[string]string{db.HistoryCommand: ":", db.HistorySearch: "?", db.HistoryTask: "+ "}[a.historyListKind]
+	for i := a.historyScroll; i < end; i++ {
+		line := truncateText(dimStyle.Render(leader)+a.historyList[i], width)
+		if i == a.historySelected {
+			line = lipgloss.NewStyle().Width(width).Background(cursorBg).Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,401 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/appgram/td/internal/config"
+	"github.com/appgram/td/internal/model"
+)
+
+// keySequenceTimeout is how long a multi-key sequence like dd waits for its
+// next key.
+const keySequenceTimeout = 800 * time.Millisecond
+
+// action is something a key or key sequence in normal mode can be bound to.
+type action struct {
+	name string
+	help string
+	run  func(a *App)
+}
+
+// actions is every bindable action, in the order :map lists them.
+var actions = []action{
+	{"down", "move down", func(a *App) {
+		if a.state.ActivePane == model.PaneWorkspaces {
+			a.moveWorkspace(1)
+		} else if a.showAsciiList {
+			a.scrollAscii(1)
+		} else {
+			a.moveCursor(1)
+		}
+	}},
+	{"up", "move up", func(a *App) {
+		if a.state.ActivePane == model.PaneWorkspaces {
+			a.moveWorkspace(-1)
+		} else if a.showAsciiList {
+			a.scrollAscii(-1)
+		} else {
+			a.moveCursor(-1)
+		}
+	}},
+	{"top", "go to the first task", func(a *App) {
+		if a.state.ActivePane == model.PaneTasks && a.showAsciiList {
+			a.scrollAsciiToTop()
+		} else if a.state.ActivePane == model.PaneTasks {
+			a.moveToTop()
+		}
+	}},
+	{"bottom", "go to the last task", func(a *App) {
+		if a.state.ActivePane == model.PaneTasks && a.showAsciiList {
+			a.scrollAsciiToEnd()
+		} else if a.state.ActivePane == model.PaneTasks {
+			a.moveToBottom()
+		}
+	}},
+	{"page-down", "scroll the ascii art list down a page", func(a *App) {
+		if a.state.ActivePane == model.PaneTasks && a.showAsciiList {
+			a.scrollAscii(a.asciiPageStep())
+		}
+	}},
+	{"page-up", "scroll the ascii art list up a page", func(a *App) {
+		if a.state.ActivePane == model.PaneTasks && a.showAsciiList {
+			a.scrollAscii(-a.asciiPageStep())
+		}
+	}},
+	{"open", "open the workspace, or toggle the task", func(a *App) {
+		if a.state.ActivePane == model.PaneWorkspaces {
+			a.selectWorkspace(a.state.SelectedWS)
+			a.state.ActivePane = model.PaneTasks
+		} else {
+			a.toggleTask()
+		}
+	}},
+	{"toggle", "toggle the task done", onTasks((*App).toggleTask)},
+	{"add", "add a task", onTasks((*App).addTask)},
+	{"edit", "edit the task", onTasks((*App).editTask)},
+	{"delete", "delete the task", onTasks((*App).deleteTask)},
+	{"indent", "make the task a subtask of the one above", onTasks((*App).indentTask)},
+	{"unindent", "move the task up a level", onTasks((*App).unindentTask)},
+	{"collapse", "collapse subtasks", onTasks((*App).collapseTask)},
+	{"expand", "expand subtasks", onTasks((*App).expandTask)},
+	{"details", "toggle the details panel", onTasks((*App).toggleTaskInfo)},
+	{"cycle-sort", "cycle the sort mode", onTasks((*App).cycleSortMode)},
+	{"timer", "start or stop the timer", onTasks((*App).toggleTimer)},
+	{"switch-pane", "switch between workspaces and tasks", (*App).togglePane},
+	{"command", "open the command line", func(a *App) { a.openCommand(":") }},
+	{"command-slash", "open the command line with /", func(a *App) { a.openCommand("/") }},
+	{"search", "filter tasks", func(a *App) {
+		a.state.Mode = model.ModeSearch
+		a.state.SearchBuf = ""
+	}},
+	{"find", "search all workspaces", func(a *App) { a.openCommandWithBuffer(":", "find ") }},
+	{"add-workspace", "add a workspace", func(a *App) { a.openCommandWithBuffer(":", "ws add ") }},
+	{"rename-workspace", "rename the workspace", func(a *App) { a.openCommandWithBuffer(":", "ws rename ") }},
+	{"delete-workspace", "delete the workspace", func(a *App) { a.openCommandWithBuffer(":", "ws delete") }},
+	{"help", "toggle the help screen", func(a *App) {
+		a.showHelp = !a.showHelp
+		a.showKeys = false
+	}},
+	{"close", "close the open list or help", func(a *App) {
+		a.showAsciiList = false
+		a.showHelp = false
+		a.showKeys = false
+		a.showFind = false
+		a.showTags = false
+		a.showHistory = false
+	}},
+	{"quit", "quit", func(a *App) { a.quitRequested = true }},
+}
+
+// onTasks runs f only while the task pane is active.
+func onTasks(f func(*App)) func(*App) {
+	return func(a *App) {
+		if a.state.ActivePane == model.PaneTasks {
+			f(a)
+		}
+	}
+}
+
+func findAction(name string) (action, bool) {
+	for _, act := range actions {
+		if act.name == name {
+			return act, true
+		}
+	}
+	return action{}, false
+}
+
+// keyPresets are the built-in keymaps. vim is the default; emacs keeps it
+// and adds control-key equivalents, moving find off ctrl+f.
+var keyPresets = map[string]map[string]string{
+	"vim": {
+		"j": "down", "down": "down",
+		"k": "up", "up": "up",
+		"g g": "top", "G": "bottom",
+		"pgdown": "page-down", "pgup": "page-up",
+		"enter": "open",
+		"x":     "toggle", "space": "toggle",
+		"a": "add", "i": "edit", "d d": "delete",
+		">": "indent", "<": "unindent", "shift+tab": "unindent",
+		"h": "collapse", "l": "expand",
+		"m": "details", "S": "cycle-sort", "s": "timer",
+		"tab": "switch-pane",
+		":":   "command", "/": "command-slash", "?": "search", "ctrl+f": "find",
+		"W": "add-workspace", "R": "rename-workspace", "X": "delete-workspace",
+		"H": "help", "esc": "close", "q": "quit",
+	},
+	"emacs": {
+		"ctrl+n": "down", "ctrl+p": "up",
+		"alt+<": "top", "alt+>": "bottom",
+		"ctrl+v": "page-down", "alt+v": "page-up",
+		"ctrl+f": "expand", "ctrl+b": "collapse",
+		"ctrl+o": "add", "ctrl+e": "edit", "ctrl+k": "delete", "ctrl+t": "toggle",
+		"alt+x": "command", "ctrl+s": "search", "ctrl+x ctrl+f": "find",
+		"ctrl+g": "close", "ctrl+x ctrl+c": "quit",
+	},
+}
+
+// namedKeys are the multi-letter key names that are a single key, as
+// opposed to sequences of letters like "dd".
+var namedKeys = map[string]bool{
+	"enter": true, "esc": true, "tab": true, "space": true, "backspace": true, "delete": true, "insert": true,
+	"up": true, "down": true, "left": true, "right": true, "home": true, "end": true, "pgup": true, "pgdown": true,
+}
+
+// parseKeys turns a binding like "dd", "g g" or "ctrl+x ctrl+c" into its
+// keys, in the names bubbletea gives them.
+func parseKeys(s string) ([]string, error) {
+	var keys []string
+	for _, field := range strings.Fields(s) {
+		switch {
+		case len([]rune(field)) == 1, namedKeys[field], strings.Contains(field, "+") && len(field) > 1,
+			len(field) > 1 && field[0] == 'f' && strings.Trim(field[1:], "0123456789") == "":
+			keys = append(keys, field)
+		default:
+			for _, r := range field {
+				keys = append(keys, string(r))
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys given")
+	}
+	return keys, nil
+}
+
+// keymap binds key sequences, as their keys joined by spaces, to actions.
+type keymap map[string]string
+
+// newKeymap returns the keymap of a preset; every preset builds on vim.
+func newKeymap(preset string) (keymap, error) {
+	extra, ok := keyPresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (vim, emacs)", preset)
+	}
+	km := keymap{}
+	for seq, name := range keyPresets["vim"] {
+		km[seq] = name
+	}
+	for seq, name := range extra {
+		km[seq] = name
+	}
+	return km, nil
+}
+
+// bind maps the keys in spec to the named action.
+func (km keymap) bind(spec, name string) error {
+	keys, err := parseKeys(spec)
+	if err != nil {
+		return err
+	}
+	if _, ok := findAction(name); !ok {
+		return fmt.Errorf("unknown action %q", name)
+	}
+	km[strings.Join(keys, " ")] = name
+	return nil
+}
+
+// unbind removes the binding of the keys in spec.
+func (km keymap) unbind(spec string) error {
+	keys, err := parseKeys(spec)
+	if err != nil {
+		return err
+	}
+	seq := strings.Join(keys, " ")
+	if _, ok := km[seq]; !ok {
+		return fmt.Errorf("%s is not bound", spec)
+	}
+	delete(km, seq)
+	return nil
+}
+
+// isPrefix reports whether seq is the start of a longer bound sequence.
+func (km keymap) isPrefix(seq string) bool {
+	for bound := range km {
+		if strings.HasPrefix(bound, seq+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// keysPath is the key bindings file, next to the database.
+func (a *App) keysPath() string {
+	return filepath.Join(filepath.Dir(a.db.Path()), "keys.conf")
+}
+
+// loadKeymap builds the keymap from keys.conf. Each line is a :map command
+// without the colon: "preset emacs", "map <keys> <action>" or
+// "unmap <keys>"; blank lines and lines starting with # are skipped.
+func (a *App) loadKeymap() error {
+	a.keymap, _ = newKeymap("vim")
+	if err := a.applyKeyConfig(); err != nil {
+		return fmt.Errorf("%s: [keys]: %v", config.FileName, err)
+	}
+	f, err := os.Open(a.keysPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := a.applyKeyCommand(strings.Fields(line)); err != nil {
+			return fmt.Errorf("%s:%d: %v", filepath.Base(a.keysPath()), n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// applyKeyCommand runs one "preset", "map" or "unmap" command against the
+// keymap.
+func (a *App) applyKeyCommand(fields []string) error {
+	switch {
+	case len(fields) == 2 && fields[0] == "preset":
+		km, err := newKeymap(fields[1])
+		if err != nil {
+			return err
+		}
+		a.keymap = km
+		return nil
+	case len(fields) >= 3 && fields[0] == "map":
+		return a.keymap.bind(strings.Join(fields[1:len(fields)-1], " "), fields[len(fields)-1])
+	case len(fields) >= 2 && fields[0] == "unmap":
+		return a.keymap.unbind(strings.Join(fields[1:], " "))
+	}
+	return fmt.Errorf("expected preset <name>, map <keys> <action> or unmap <keys>")
+}
+
+// mappedAction returns the name of the action bound to key, or to the
+// sequence it completes. A key that starts a longer sequence waits for the
+// next one, and like an unbound key has no action.
+func (a *App) mappedAction(key string) string {
+	if len(a.pendingKeys) > 0 && time.Since(a.pendingAt) > keySequenceTimeout {
+		a.pendingKeys = nil
+	}
+	seq := strings.Join(append(a.pendingKeys, key), " ")
+	switch name, ok := a.keymap[seq]; {
+	case a.keymap.isPrefix(seq):
+		a.pendingKeys = append(a.pendingKeys, key)
+		a.pendingAt = time.Now()
+	case ok:
+		a.pendingKeys = nil
+		return name
+	case len(a.pendingKeys) > 0:
+		// The sequence went nowhere: start over from this key, so "d j"
+		// still moves down.
+		a.pendingKeys = nil
+		return a.mappedAction(key)
+	}
+	return ""
+}
+
+func (a *App) clearPendingKey() {
+	if len(a.pendingKeys) > 0 && time.Since(a.pendingAt) > keySequenceTimeout {
+		a.pendingKeys = nil
+	}
+}
+
+// keyName is how a key press is written in bindings.
+func keyName(msg tea.KeyMsg) string {
+	if msg.Type == tea.KeySpace || msg.String() == " " {
+		return "space"
+	}
+	return msg.String()
+}
+
+// formatKeys writes a bound sequence the way it is typed: "gg" rather than
+// "g g" when every key is a single character.
+func formatKeys(seq string) string {
+	keys := strings.Fields(seq)
+	for _, k := range keys {
+		if len([]rune(k)) > 1 {
+			return seq
+		}
+	}
+	return strings.Join(keys, "")
+}
+
+// executeMapCommand handles :map and :unmap. With no arguments :map lists
+// the bindings; bindings made here last for the session, and keys.conf
+// holds the ones to keep.
+func (a *App) executeMapCommand(fields []string) {
+	fields[0] = strings.ToLower(fields[0])
+	if fields[0] == "map" && len(fields) == 1 {
+		a.showHelp = true
+		a.showKeys = true
+		return
+	}
+	if fields[0] == "map" && len(fields) == 3 && fields[1] == "preset" {
+		fields = fields[1:]
+	}
+	if err := a.applyKeyCommand(fields); err != nil {
+		a.setMessage(fields[0] + ": " + err.Error())
+		return
+	}
+	switch fields[0] {
+	case "map":
+		a.setMessage(fmt.Sprintf("%s → %s", strings.Join(fields[1:len(fields)-1], " "), fields[len(fields)-1]))
+	case "unmap":
+		a.setMessage(strings.Join(fields[1:], " ") + " unbound")
+	default:
+		a.setMessage("keys: " + fields[1] + " preset")
+	}
+}
+
+// keyBindingLines lists each action with its keys, for :map.
+func (a *App) keyBindingLines() []string {
+	bound := map[string][]string{}
+	for seq, name := range a.keymap {
+		bound[name] = append(bound[name], seq)
+	}
+	lines := []string{"", ":map <keys> <action> and :unmap <keys> last for the session; keys.conf keeps them", ""}
+	for _, act := range actions {
+		keys := bound[act.name]
+		sort.Strings(keys)
+		for i, k := range keys {
+			keys[i] = formatKeys(k)
+		}
+		shown := strings.Join(keys, ", ")
+		if shown == "" {
+			shown = "-"
+		}
+		lines = append(lines, fmt.Sprintf("  %-18s %-22s %s", act.name, shown, act.help))
+	}
+	return append(lines, "", "Press H or Esc to close.")
+}
@@ -0,0 +1,197 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// lineEdit is a single line of input being edited, with the cursor between
+// runes. Insert, command and search modes all edit through it.
+type lineEdit struct {
+	text   []rune
+	cursor int
+}
+
+// handleKey applies an editing key: typing and pasting, moving by rune and
+// by word, and deleting. It reports whether the key was an editing key.
+func (e *lineEdit) handleKey(msg tea.KeyMsg) bool {
+	e.cursor = clamp(e.cursor, 0, len(e.text))
+	switch msg.String() {
+	case "left", "ctrl+b":
+		e.cursor = max(e.cursor-1, 0)
+	case "right", "ctrl+f":
+		e.cursor = min(e.cursor+1, len(e.text))
+	case "home", "ctrl+a":
+		e.cursor = 0
+	case "end", "ctrl+e":
+		e.cursor = len(e.text)
+	case "alt+b", "ctrl+left", "alt+left":
+		e.cursor = e.wordStart()
+	case "alt+f", "ctrl+right", "alt+right":
+		e.cursor = e.wordEnd()
+	case "backspace", "ctrl+h":
+		if e.cursor > 0 {
+			e.delete(e.cursor-1, e.cursor)
+		}
+	case "delete", "ctrl+d":
+		if e.cursor < len(e.text) {
+			e.delete(e.cursor, e.cursor+1)
+		}
+	case "ctrl+w", "alt+backspace":
+		e.delete(e.wordStart(), e.cursor)
+	case "alt+d":
+		e.delete(e.cursor, e.wordEnd())
+	case "ctrl+u":
+		e.delete(0, e.cursor)
+	case "ctrl+k":
+		e.delete(e.cursor, len(e.text))
+	default:
+		switch {
+		case msg.Type == tea.KeySpace:
+			e.insert([]rune{' '})
+		case msg.Type == tea.KeyRunes && (!msg.Alt || msg.Paste):
+			e.insert(msg.Runes)
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// insert types runes at the cursor. Line breaks and tabs in pasted text
+// become spaces, since the input is a single line.
+func (e *lineEdit) insert(runes []rune) {
+	runes = []rune(strings.ReplaceAll(string(runes), "\r\n", "\n"))
+	clean := make([]rune, 0, len(runes))
+	for _, r := range runes {
+		switch {
+		case unicode.IsSpace(r):
+			r = ' '
+		case unicode.IsControl(r):
+			continue
+		}
+		clean = append(clean, r)
+	}
+	text := make([]rune, 0, len(e.text)+len(clean))
+	text = append(text, e.text[:e.cursor]...)
+	text = append(text, clean...)
+	e.text = append(text, e.text[e.cursor:]...)
+	e.cursor += len(clean)
+}
+
+func (e *lineEdit) delete(from, to int) {
+	e.text = append(e.text[:from], e.text[to:]...)
+	e.cursor = from
+}
+
+// wordStart is where the word before the cursor starts, skipping the spaces
+// just before it, the way ctrl+w and alt+b move in a shell.
+func (e *lineEdit) wordStart() int {
+	i := e.cursor
+	for i > 0 && e.text[i-1] == ' ' {
+		i--
+	}
+	for i > 0 && e.text[i-1] != ' ' {
+		i--
+	}
+	return i
+}
+
+// wordEnd is where the word after the cursor ends.
+func (e *lineEdit) wordEnd() int {
+	i := e.cursor
+	for i < len(e.text) && e.text[i] == ' ' {
+		i++
+	}
+	for i < len(e.text) && e.text[i] != ' ' {
+		i++
+	}
+	return i
+}
+
+// editInput applies an editing key to the current mode's input buffer. It
+// reports whether the key was an editing key.
+func (a *App) editInput(msg tea.KeyMsg) bool {
+	buf := a.inputBuffer()
+	if buf == nil {
+		return false
+	}
+	e := lineEdit{text: []rune(*buf), cursor: a.inputCursor}
+	if !e.handleKey(msg) {
+		return false
+	}
+	*buf = string(e.text)
+	a.inputCursor = e.cursor
+	return true
+}
+
+// inputBeforeCursor splits the current input at the cursor.
+func (a *App) inputBeforeCursor() (before, after string) {
+	buf := a.inputBuffer()
+	if buf == nil {
+		return "", ""
+	}
+	runes := []rune(*buf)
+	i := clamp(a.inputCursor, 0, len(runes))
+	return string(runes[:i]), string(runes[i:])
+}
+
+// setInputCursor puts the cursor after the first n bytes of the input.
+func (a *App) setInputCursor(n int) {
+	if buf := a.inputBuffer(); buf != nil {
+		a.inputCursor = utf8.RuneCountInString((*buf)[:n])
+	}
+}
+
+// cursorToEnd moves the cursor to the end of the input, as when a mode
+// opens with text already in it.
+func (a *App) cursorToEnd() {
+	if buf := a.inputBuffer(); buf != nil {
+		a.inputCursor = utf8.RuneCountInString(*buf)
+	}
+}
+
+// renderInput draws text in style with a reverse-video cursor, scrolled
+// horizontally so the cursor stays within width.
+func (a *App) renderInput(text string, style lipgloss.Style, width int) string {
+	runes := []rune(text)
+	cursor := clamp(a.inputCursor, 0, len(runes))
+	if width > 0 {
+		// Drop runes from the left until the text up to the cursor fits.
+		from := 0
+		for from < cursor && lipgloss.Width(string(runes[from:cursor]))+1 > width {
+			from++
+		}
+		runes, cursor = runes[from:], cursor-from
+	}
+	at := " "
+	rest := ""
+	if cursor < len(runes) {
+		at = string(runes[cursor])
+		rest = string(runes[cursor+1:])
+	}
+	out := style.Render(string(runes[:cursor])) + style.Reverse(true).Render(at) + style.Render(rest)
+	if width > 0 {
+		out = truncateText(out, width)
+	}
+	return out
+}
+
+// wordBefore splits text into what comes before its last word and the word.
+func wordBefore(text string) (string, string) {
+	i := strings.LastIndexByte(text, ' ') + 1
+	return text[:i], text[i:]
+}
+
+// renderTaskInput draws the line a task is typed into.
+func (a *App) renderTaskInput(width int) string {
+	if a.histSearch != nil {
+		return a.renderHistorySearch(width)
+	}
+	style := lipgloss.NewStyle().Foreground(accent)
+	return style.Render("+ ") + a.renderInput(a.taskInputBuf, style, width-2)
+}
@@ -0,0 +1,66 @@
+package tui
+
+import "github.com/appgram/td/internal/model"
+
+// refreshIfChanged reloads workspaces and tasks when another process, such
+// as "td -a", a script or the API server, has written to the database since
+// the last tick. It waits while a task is being typed so the edit isn't
+// applied to a different task.
+func (a *App) refreshIfChanged() {
+	v, err := a.db.DataVersion()
+	if err != nil || v == a.dataVersion {
+		return
+	}
+	if a.dataVersion == 0 {
+		a.dataVersion = v
+		return
+	}
+	if a.state.Mode == model.ModeInsert {
+		return
+	}
+	a.dataVersion = v
+	a.reload()
+}
+
+// ownWrites runs f, which may write to the database, and takes the commits
+// it makes as already seen: data_version changes for the app's own pool
+// connections too, and those changes are on screen already. A change
+// another process made before f ran still triggers a reload.
+func (a *App) ownWrites(f func()) {
+	before, err := a.db.DataVersion()
+	f()
+	if err != nil || before != a.dataVersion {
+		return
+	}
+	if v, err := a.db.DataVersion(); err == nil {
+		a.dataVersion = v
+	}
+}
+
+// reload re-reads everything from the database, keeping the same workspace
+// and task selected and the list scrolled where it was. Expansion state is
+// keyed by task ID and survives as is.
+func (a *App) reload() {
+	var wsID int64
+	if a.state.SelectedWS < len(a.workspaces) {
+		wsID = a.workspaces[a.state.SelectedWS].ID
+	}
+	taskID := a.selectedTaskID()
+	scroll := a.taskScroll
+
+	a.loadWorkspaces()
+	for i, ws := range a.workspaces {
+		if ws.ID == wsID && i != a.state.SelectedWS {
+			a.state.SelectedWS = i
+			a.loadTasks()
+			break
+		}
+	}
+	for i, line := range a.flatTasks {
+		if line.Task != nil && line.Task.ID == taskID {
+			a.state.SelectedTask = i
+			break
+		}
+	}
+	a.taskScroll = scroll
+}
@@ -0,0 +1,96 @@
+package tui
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/appgram/td/internal/model"
+	"github.com/appgram/td/internal/remind"
+)
+
+// reminderCheckInterval is how often the running TUI looks for due reminders.
+const reminderCheckInterval = 15 * time.Second
+
+func (a *App) addReminders(taskID int64, reminders []model.Reminder) {
+	for _, r := range reminders {
+		a.db.AddReminder(taskID, r)
+	}
+}
+
+func (a *App) executeRemindCommand(fields []string) {
+	task := a.selectedTask()
+	if task == nil {
+		a.setMessage("no task selected")
+		return
+	}
+	if len(fields) < 2 {
+		if len(task.Reminders) == 0 {
+			a.setMessage("no reminders (e.g. :remind 30m-before or :remind 14:00)")
+		} else {
+			a.setMessage("reminders: " + formatReminders(task))
+		}
+		return
+	}
+	if fields[1] == "clear" || fields[1] == "off" || fields[1] == "rm" {
+		a.db.ClearReminders(task.ID)
+		a.loadTasks()
+		a.setMessage("reminders cleared")
+		return
+	}
+	// ":remind friday 9:00" reads like "remind:friday@9:00".
+	r, ok := model.ParseReminder(strings.Join(fields[1:], "@"), task.DueDate, time.Now())
+	if !ok {
+		a.setMessage("invalid reminder: " + strings.Join(fields[1:], " "))
+		return
+	}
+	if r.Relative() && task.DueDate == "" {
+		a.setMessage("set a due date first; relative reminders count back from it")
+		return
+	}
+	a.db.AddReminder(task.ID, r)
+	a.loadTasks()
+	fire, _ := r.FireTime(task.DueDate)
+	a.setMessage("reminder set for " + fire.Format("Mon Jan 2 15:04"))
+}
+
+// formatReminders lists a task's reminders with the time each one fires.
+func formatReminders(task *model.Task) string {
+	parts := make([]string, 0, len(task.Reminders))
+	for _, r := range task.Reminders {
+		fire, ok := r.FireTime(task.DueDate)
+		switch {
+		case !ok:
+			parts = append(parts, r.String()+" (no due date)")
+		case !r.FiredAt.IsZero():
+			parts = append(parts, fire.Format("Jan 2 15:04")+" ✓")
+		default:
+			parts = append(parts, fire.Format("Jan 2 15:04"))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// tickReminders announces due reminders while the TUI runs: a status message
+// plus a bell and desktop notification escape, written by the returned
+// command.
+func (a *App) tickReminders(now time.Time) tea.Cmd {
+	if now.Sub(a.lastReminderCheck) < reminderCheckInterval {
+		return nil
+	}
+	a.lastReminderCheck = now
+	due, err := remind.Due(a.db, now)
+	if err != nil || len(due) == 0 {
+		return nil
+	}
+	messages := make([]string, len(due))
+	var alerts strings.Builder
+	for i, p := range due {
+		messages[i] = remind.Message(p)
+		alerts.WriteString(remind.TerminalAlert("td", messages[i]))
+	}
+	a.setMessage("⏰ " + strings.Join(messages, " · "))
+	a.loadTasks()
+	return a.alert(alerts.String())
+}
@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/appgram/td/internal/model"
+)
+
+const (
+	sortManual        = "manual"
+	sortDue           = "due"
+	sortPriority      = "priority"
+	sortCreated       = "created"
+	sortAlpha         = "alpha"
+	sortCompletedLast = "completed-last"
+)
+
+var sortModes = []string{sortManual, sortDue, sortPriority, sortCreated, sortAlpha, sortCompletedLast}
+
+func parseSortMode(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "manual", "order", "m":
+		return sortManual, true
+	case "due", "date", "d":
+		return sortDue, true
+	case "priority", "p":
+		return sortPriority, true
+	case "created", "new", "newest", "c":
+		return sortCreated, true
+	case "alpha", "alphabetical", "title", "a":
+		return sortAlpha, true
+	case "completed-last", "completed", "done-last", "done":
+		return sortCompletedLast, true
+	}
+	return "", false
+}
+
+func sortSettingKey(workspaceID int64) string {
+	return fmt.Sprintf("sort_mode_%d", workspaceID)
+}
+
+// loadSortMode reads the persisted sort mode for the current workspace.
+func (a *App) loadSortMode() {
+	a.sortMode = sortManual
+	if a.state.SelectedWS >= len(a.workspaces) {
+		return
+	}
+	value, _ := a.db.GetSetting(sortSettingKey(a.workspaces[a.state.SelectedWS].ID))
+	if mode, ok := parseSortMode(value); ok {
+		a.sortMode = mode
+	}
+}
+
+func (a *App) setSortMode(mode string) {
+	a.sortMode = mode
+	if a.state.SelectedWS < len(a.workspaces) {
+		_ = a.db.SetSetting(sortSettingKey(a.workspaces[a.state.SelectedWS].ID), mode)
+	}
+	a.flattenTasks()
+	a.setMessage("sort: " + mode)
+}
+
+func (a *App) cycleSortMode() {
+	next := 0
+	for i, mode := range sortModes {
+		if mode == a.sortMode {
+			next = (i + 1) % len(sortModes)
+			break
+		}
+	}
+	a.setSortMode(sortModes[next])
+}
+
+func (a *App) executeSortCommand(fields []string) {
+	if len(fields) < 2 {
+		a.setMessage("sort: " + a.sortMode + " (" + strings.Join(sortModes, ", ") + ")")
+		return
+	}
+	if fields[1] == "next" {
+		a.cycleSortMode()
+		return
+	}
+	mode, ok := parseSortMode(fields[1])
+	if !ok {
+		a.setMessage("unknown sort mode: " + fields[1])
+		return
+	}
+	a.setSortMode(mode)
+}
+
+// sortTasks orders tasks and, recursively, their children by the current sort
+// mode. Ties always fall back to manual order so the result is stable.
+func (a *App) sortTasks(tasks []*model.Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return a.taskLess(tasks[i], tasks[j])
+	})
+	for _, t := range tasks {
+		if len(t.Children) > 0 {
+			a.sortTasks(t.Children)
+		}
+	}
+}
+
+func (a *App) taskLess(x, y *model.Task) bool {
+	switch a.sortMode {
+	case sortDue:
+		if x.DueDate != y.DueDate {
+			if x.DueDate == "" || y.DueDate == "" {
+				return y.DueDate == ""
+			}
+			return x.DueDate < y.DueDate
+		}
+	case sortPriority:
+		if rx, ry := priorityRank(x.Priority), priorityRank(y.Priority); rx != ry {
+			return rx < ry
+		}
+	case sortCreated:
+		if x.CreatedAt != y.CreatedAt {
+			return x.CreatedAt > y.CreatedAt
+		}
+	case sortAlpha:
+		if tx, ty := strings.ToLower(x.Title), strings.ToLower(y.Title); tx != ty {
+			return tx < ty
+		}
+	case sortCompletedLast:
+		if cx, cy := a.taskIsComplete(x), a.taskIsComplete(y); cx != cy {
+			return !cx
+		}
+	}
+	if x.Order != y.Order {
+		return x.Order < y.Order
+	}
+	return x.ID < y.ID
+}
+
+// priorityRank orders priorities from most to least urgent.
+func priorityRank(priority int) int {
+	switch {
+	case priority >= 2:
+		return 0
+	case priority == 1:
+		return 2
+	default:
+		return 1
+	}
+}
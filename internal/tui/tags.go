@@ -0,0 +1,250 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
+)
+
+// tagColorNames are the colors :tag color accepts by name, as 256-color
+// palette entries that read well on the dark schemes.
+var tagColorNames = map[string]string{
+	"red":    "203",
+	"orange": "208",
+	"yellow": "220",
+	"green":  "114",
+	"cyan":   "80",
+	"blue":   "75",
+	"purple": "141",
+	"pink":   "212",
+	"gray":   "245",
+	"grey":   "245",
+}
+
+// parseTagColor accepts a color name, a palette number or a #rgb/#rrggbb
+// hex color. "none" resets the tag to the default color.
+func parseTagColor(s string) (string, bool) {
+	s = strings.ToLower(s)
+	if c, ok := tagColorNames[s]; ok {
+		return c, true
+	}
+	switch s {
+	case "none", "off", "default", "-":
+		return "", true
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return s, n >= 0 && n <= 255
+	}
+	if hex, ok := strings.CutPrefix(s, "#"); ok && (len(hex) == 3 || len(hex) == 6) {
+		_, err := strconv.ParseUint(hex, 16, 32)
+		return s, err == nil
+	}
+	return "", false
+}
+
+// renderTags is formatTags with each tag in its own color, or in base when
+// it hasn't been given one.
+func (a *App) renderTags(tags []string, base lipgloss.Style) string {
+	var parts []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		style := base
+		if c, ok := a.tagColors[strings.TrimPrefix(tag, "#")]; ok {
+			style = style.Foreground(lipgloss.Color(c))
+		}
+		if !strings.HasPrefix(tag, "#") {
+			tag = "#" + tag
+		}
+		parts = append(parts, style.Render(tag))
+	}
+	return strings.Join(parts, base.Render(" "))
+}
+
+// executeTagAdminCommand handles the :tag subcommands that work on tags
+// themselves rather than on the selected task. It reports whether fields
+// was one of them, so a subcommand with the wrong arguments shows its usage
+// instead of being added to the task as tags.
+func (a *App) executeTagAdminCommand(fields []string) bool {
+	if len(fields) < 2 {
+		a.showTagBrowser()
+		return true
+	}
+	name := func(s string) string { return strings.TrimPrefix(s, "#") }
+
+	switch strings.ToLower(fields[1]) {
+	case "rename", "mv":
+		if len(fields) != 4 {
+			a.setMessage("usage: :tag rename <old> <new>")
+			return true
+		}
+		n, err := a.db.RenameTag(name(fields[2]), fields[3])
+		if err != nil {
+			a.setMessage(tagError(err))
+			return true
+		}
+		a.setMessage(fmt.Sprintf("renamed #%s to #%s on %d task(s)", name(fields[2]), name(fields[3]), n))
+	case "merge":
+		if len(fields) != 4 {
+			a.setMessage("usage: :tag merge <from> <into>")
+			return true
+		}
+		n, err := a.db.MergeTags(name(fields[2]), fields[3])
+		if err != nil {
+			a.setMessage(tagError(err))
+			return true
+		}
+		a.setMessage(fmt.Sprintf("merged #%s into #%s on %d task(s)", name(fields[2]), name(fields[3]), n))
+	case "color", "colour":
+		if len(fields) < 3 || len(fields) > 4 {
+			a.setMessage("usage: :tag color <tag> [color]")
+			return true
+		}
+		color := "none"
+		if len(fields) == 4 {
+			color = fields[3]
+		}
+		c, ok := parseTagColor(color)
+		if !ok {
+			a.setMessage("colors: a name (red, orange, yellow, green, cyan, blue, purple, pink, gray), 0-255, #rrggbb or none")
+			return true
+		}
+		if err := a.db.SetTagColor(name(fields[2]), c); err != nil {
+			a.setMessage(tagError(err))
+			return true
+		}
+		a.setMessage(fmt.Sprintf("#%s color: %s", name(fields[2]), color))
+	default:
+		return false
+	}
+	a.loadTasks()
+	return true
+}
+
+func tagError(err error) string {
+	if errors.Is(err, db.ErrNoTag) {
+		return err.Error()
+	}
+	return "tag: " + err.Error()
+}
+
+func (a *App) loadTagList() {
+	tags, err := a.db.GetTags()
+	if err != nil {
+		a.setMessage("tags: " + err.Error())
+		return
+	}
+	a.tagList = tags
+	a.tagSelected = clamp(a.tagSelected, 0, len(tags)-1)
+}
+
+// showTagBrowser opens the list of all tags with their task counts.
+func (a *App) showTagBrowser() {
+	a.loadTagList()
+	if len(a.tagList) == 0 {
+		a.setMessage("no tags yet")
+		return
+	}
+	a.showTags = true
+	a.showHistory = false
+	a.showFind = false
+	a.showHelp = false
+	a.showAsciiList = false
+	a.state.ActivePane = model.PaneTasks
+}
+
+// handleTagBrowserKey handles navigation inside the tag browser, given the
+// key pressed and the action it is bound to. The browser's own commands are
+// plain keys. It reports whether the key was consumed.
+func (a *App) handleTagBrowserKey(key, action string) bool {
+	var selected string
+	if a.tagSelected >= 0 && a.tagSelected < len(a.tagList) {
+		selected = a.tagList[a.tagSelected].Name
+	}
+	switch key {
+	case "r":
+		a.openCommandWithBuffer(":", "tag rename "+selected+" ")
+		return true
+	case "M":
+		a.openCommandWithBuffer(":", "tag merge "+selected+" ")
+		return true
+	case "c":
+		a.openCommandWithBuffer(":", "tag color "+selected+" ")
+		return true
+	}
+	switch action {
+	case "down":
+		a.tagSelected = clamp(a.tagSelected+1, 0, len(a.tagList)-1)
+	case "up":
+		a.tagSelected = clamp(a.tagSelected-1, 0, len(a.tagList)-1)
+	case "top":
+		a.tagSelected = 0
+	case "bottom":
+		a.tagSelected = len(a.tagList) - 1
+	case "open":
+		if selected != "" {
+			a.showTags = false
+			a.state.SearchQuery = "#" + selected
+			a.state.SelectedTask = 0
+			a.flattenTasks()
+		}
+	case "close":
+		a.showTags = false
+	default:
+		return false
+	}
+	return true
+}
+
+func (a *App) renderTagBrowser(width, height int) string {
+	if height < 1 {
+		return ""
+	}
+	dimStyle := lipgloss.NewStyle().Foreground(dim)
+	summary := fmt.Sprintf("%d tags  ·  enter filter, r rename, M merge, c color, esc close", len(a.tagList))
+	lines := []string{dimStyle.Render(truncateText(summary, width))}
+
+	visible := height - 1
+	if visible < 1 {
+		return strings.Join(lines, "\n")
+	}
+	if a.tagSelected < a.tagScroll {
+		a.tagScroll = a.tagSelected
+	} else if a.tagSelected >= a.tagScroll+visible {
+		a.tagScroll = a.tagSelected - visible + 1
+	}
+	end := a.tagScroll + visible
+	if end > len(a.tagList) {
+		end = len(a.tagList)
+	}
+
+	for i := a.tagScroll; i < end; i++ {
+		tag := a.tagList[i]
+		swatch := dimStyle.Render("●")
+		name := "#" + tag.Name
+		if tag.Color != "" {
+			swatch = lipgloss.NewStyle().Foreground(lipgloss.Color(tag.Color)).Render("●")
+			name = lipgloss.NewStyle().Foreground(lipgloss.Color(tag.Color)).Render(name)
+		}
+		counts := fmt.Sprintf("%d open · %d", tag.Open, tag.Total)
+		left := truncateText(swatch+" "+name, width-lipgloss.Width(counts)-1)
+		padding := width - lipgloss.Width(left) - lipgloss.Width(counts)
+		if padding < 1 {
+			padding = 1
+		}
+		line := left + strings.Repeat(" ", padding) + dimStyle.Render(counts)
+		if i == a.tagSelected {
+			line = lipgloss.NewStyle().Width(width).Background(cursorBg).Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
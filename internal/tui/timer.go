@@ -0,0 +1,98 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// loadTimer picks up the running timer from the database, so a timer started
+// in an earlier session keeps counting.
+func (a *App) loadTimer() {
+	a.timer, _ = a.db.GetRunningTimer()
+}
+
+// toggleTimer starts timing the selected task, or stops the timer if it is
+// already running on it. Starting a timer stops any other one.
+func (a *App) toggleTimer() {
+	task := a.selectedTask()
+	if task == nil {
+		return
+	}
+	if a.timer != nil && a.timer.TaskID == task.ID {
+		a.stopTimer()
+		return
+	}
+	if err := a.db.StartTimer(task.ID); err != nil {
+		a.setMessage("failed to start timer: " + err.Error())
+		return
+	}
+	a.loadTimer()
+	a.setMessage("timer started: " + task.Title)
+}
+
+func (a *App) stopTimer() {
+	stopped, err := a.db.StopTimer()
+	if err != nil {
+		a.setMessage("failed to stop timer: " + err.Error())
+		return
+	}
+	a.timer = nil
+	if stopped == nil {
+		a.setMessage("no timer running")
+		return
+	}
+	a.setMessage(fmt.Sprintf("logged %s on %s", formatElapsed(time.Since(stopped.StartedAt)), stopped.Title))
+}
+
+func (a *App) executeTimerCommand(fields []string) {
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "start":
+			if a.timer != nil && a.timer.TaskID == a.selectedTaskID() {
+				return
+			}
+			a.toggleTimer()
+		case "stop":
+			a.stopTimer()
+		default:
+			a.setMessage("usage: :timer [start|stop]")
+		}
+		return
+	}
+	if a.timer == nil {
+		a.setMessage("no timer running")
+		return
+	}
+	a.setMessage(fmt.Sprintf("timing %s for %s", a.timer.Title, formatElapsed(time.Since(a.timer.StartedAt))))
+}
+
+func (a *App) selectedTaskID() int64 {
+	if task := a.selectedTask(); task != nil {
+		return task.ID
+	}
+	return 0
+}
+
+// renderTimer is the status line segment for the running timer.
+func (a *App) renderTimer(maxWidth int) string {
+	if a.timer == nil || maxWidth < 12 {
+		return ""
+	}
+	elapsed := " ⏱ " + formatElapsed(time.Since(a.timer.StartedAt))
+	title := truncateText(a.timer.Title, maxWidth-lipgloss.Width(elapsed)-2)
+	return lipgloss.NewStyle().Foreground(accent).Render(" " + title + elapsed + " ")
+}
+
+// formatElapsed renders a duration as m:ss or h:mm:ss.
+func formatElapsed(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	s := int(d.Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -3,6 +3,7 @@ package tui
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"os"
@@ -13,7 +14,9 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
 
+	"github.com/appgram/td/internal/config"
 	"github.com/appgram/td/internal/db"
 	"github.com/appgram/td/internal/model"
 )
@@ -34,96 +37,6 @@ var (
 
 const weatherUnknown = "--°"
 
-// ParsedTask holds the result of parsing inline task syntax
-type ParsedTask struct {
-	Title    string
-	Tags     []string
-	DueDate  string
-	Priority int
-}
-
-// ParseTaskInput parses inline task syntax: "task #tag @date !priority"
-func ParseTaskInput(input string) ParsedTask {
-	var result ParsedTask
-	var titleParts []string
-
-	words := strings.Fields(input)
-	for _, word := range words {
-		switch {
-		case strings.HasPrefix(word, "#"):
-			tag := strings.TrimPrefix(word, "#")
-			if tag != "" {
-				result.Tags = append(result.Tags, tag)
-			}
-		case strings.HasPrefix(word, "!"):
-			p := strings.ToLower(strings.TrimPrefix(word, "!"))
-			switch p {
-			case "high", "h":
-				result.Priority = 2
-			case "low", "l":
-				result.Priority = 1
-			case "blocked", "b":
-				result.Priority = -1
-			case "normal", "n":
-				result.Priority = 0
-			}
-		case strings.HasPrefix(word, "@"):
-			date := strings.TrimPrefix(word, "@")
-			result.DueDate = parseDueDate(date)
-		default:
-			titleParts = append(titleParts, word)
-		}
-	}
-
-	result.Title = strings.Join(titleParts, " ")
-	return result
-}
-
-func parseDueDate(input string) string {
-	input = strings.ToLower(input)
-	now := time.Now()
-
-	switch input {
-	case "today":
-		return now.Format("2006-01-02")
-	case "tomorrow", "tmr":
-		return now.AddDate(0, 0, 1).Format("2006-01-02")
-	case "week", "nextweek":
-		return now.AddDate(0, 0, 7).Format("2006-01-02")
-	case "mon", "monday":
-		return nextWeekday(now, time.Monday)
-	case "tue", "tuesday":
-		return nextWeekday(now, time.Tuesday)
-	case "wed", "wednesday":
-		return nextWeekday(now, time.Wednesday)
-	case "thu", "thursday":
-		return nextWeekday(now, time.Thursday)
-	case "fri", "friday":
-		return nextWeekday(now, time.Friday)
-	case "sat", "saturday":
-		return nextWeekday(now, time.Saturday)
-	case "sun", "sunday":
-		return nextWeekday(now, time.Sunday)
-	default:
-		// Try parsing as date (YYYY-MM-DD or MM-DD)
-		if t, err := time.Parse("2006-01-02", input); err == nil {
-			return t.Format("2006-01-02")
-		}
-		if t, err := time.Parse("01-02", input); err == nil {
-			return time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location()).Format("2006-01-02")
-		}
-		return input
-	}
-}
-
-func nextWeekday(from time.Time, weekday time.Weekday) string {
-	daysUntil := int(weekday) - int(from.Weekday())
-	if daysUntil <= 0 {
-		daysUntil += 7
-	}
-	return from.AddDate(0, 0, daysUntil).Format("2006-01-02")
-}
-
 type colorScheme struct {
 	name        string
 	bg          lipgloss.Color
@@ -217,6 +130,7 @@ type TaskLine struct {
 	Depth    int
 	Expanded bool
 	Index    int
+	Group    *taskGroup // section in grouped views; Task is nil on its header line
 }
 
 type App struct {
@@ -232,7 +146,10 @@ type App struct {
 	taskInputBuf string
 	newTaskParent *int64
 	editingTaskID *int64
-	pendingKey    string
+	pendingKeys   []string
+	keymap        keymap
+	config        *config.Config
+	showKeys      bool
 	pendingAt     time.Time
 	headerCache   string
 	headerDate    string
@@ -249,6 +166,39 @@ type App struct {
 	taskScroll    int
 	showHelp      bool
 	showDashboard bool
+	showFind      bool
+	findQuery     string
+	findResults   []db.SearchHit
+	findSelected  int
+	findScroll    int
+	showTags      bool
+	tagList       []db.Tag
+	tagSelected   int
+	tagScroll     int
+	tagColors     map[string]string
+	inputCursor   int // in runes, into the buffer of the current input mode
+	completion    *completion
+	history       *inputHistory
+	histSearch    *historySearch
+	showHistory   bool
+	historyListKind string
+	historyList   []string
+	historySelected int
+	historyScroll int
+	completionUses map[string]int
+	sortMode      string
+	groupBy       string
+	collapsedGroups map[string]bool
+	showBoard     bool
+	boardCol      int
+	boardRow      int
+	newTaskStatus model.Status
+	deps          *taskDeps
+	timer         *db.RunningTimer
+	focus         *focusSession
+	lastReminderCheck time.Time
+	output        io.Writer // the terminal, for alerts; nil outside Run
+	dataVersion   int64
 	weatherEnabled bool
 	weatherCity    string
 	weatherLat     float64
@@ -267,10 +217,15 @@ func New(database *db.DB) *App {
 			ExpandedTasks: make(map[int64]bool),
 		},
 		commandLeader: ":",
+		sortMode:      sortManual,
+		collapsedGroups: make(map[string]bool),
+		completionUses: make(map[string]int),
 		weatherTemp:   weatherUnknown,
 		weatherUnit:   "f",
 		showDashboard: true,
+		config:        &config.Config{},
 	}
+	app.keymap, _ = newKeymap("vim")
 	app.applyScheme("black")
 	return app
 }
@@ -282,7 +237,7 @@ func (a *App) Init() tea.Cmd {
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		a.handleKey(msg)
+		a.ownWrites(func() { a.handleKey(msg) })
 		if a.quitRequested {
 			a.quitRequested = false
 			return a, tea.Quit
@@ -290,10 +245,17 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tickMsg:
 		a.clearPendingKey()
 		a.tickMessage()
+		next := tickCmd()
 		if cmd := a.maybeFetchWeather(); cmd != nil {
-			return a, cmd
+			next = cmd
 		}
-		return a, tickCmd()
+		a.refreshIfChanged()
+		var bell, alert tea.Cmd
+		a.ownWrites(func() {
+			bell = a.tickFocus(time.Time(msg))
+			alert = a.tickReminders(time.Time(msg))
+		})
+		return a, tea.Batch(next, bell, alert)
 	case tea.WindowSizeMsg:
 		a.width = msg.Width
 		a.height = msg.Height
@@ -306,8 +268,10 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if msg.lat != 0 || msg.lon != 0 {
 				a.weatherLat = msg.lat
 				a.weatherLon = msg.lon
-				_ = a.db.SetSetting("weather_lat", fmt.Sprintf("%0.4f", a.weatherLat))
-				_ = a.db.SetSetting("weather_lon", fmt.Sprintf("%0.4f", a.weatherLon))
+				a.ownWrites(func() {
+					_ = a.db.SetSetting("weather_lat", fmt.Sprintf("%0.4f", a.weatherLat))
+					_ = a.db.SetSetting("weather_lon", fmt.Sprintf("%0.4f", a.weatherLon))
+				})
 			}
 		}
 	}
@@ -315,6 +279,10 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (a *App) handleKey(msg tea.KeyMsg) {
+	if a.handleHistoryKey(msg) || a.handleCompletionKey(msg) {
+		return
+	}
+	mode := a.state.Mode
 	switch a.state.Mode {
 	case model.ModeNormal:
 		a.handleNormalMode(msg)
@@ -325,124 +293,39 @@ func (a *App) handleKey(msg tea.KeyMsg) {
 	case model.ModeSearch:
 		a.handleSearchMode(msg)
 	}
+	if a.state.Mode != mode {
+		a.cursorToEnd()
+		a.startHistory()
+	}
+	a.updateCompletion()
 }
 
 func (a *App) handleNormalMode(msg tea.KeyMsg) {
-	if msg.Type == tea.KeyRunes && len(msg.Runes) == 1 && msg.Runes[0] == ' ' {
-		if a.state.ActivePane == model.PaneTasks {
-			a.toggleTask()
-		}
+	if a.focus != nil && a.handleFocusKey(msg) {
 		return
 	}
-
-	switch msg.String() {
-	case "ctrl+c", "q":
+	if msg.String() == "ctrl+c" {
 		a.quitRequested = true
-	case "tab":
-		a.togglePane()
-	case ":":
-		a.openCommand(":")
-	case "/":
-		a.openCommand("/")
-	case "?":
-		a.state.Mode = model.ModeSearch
-		a.state.SearchBuf = ""
-	case "esc":
-		if a.showAsciiList {
-			a.showAsciiList = false
-		}
-		if a.showHelp {
-			a.showHelp = false
-		}
-	case "i":
-		if a.state.ActivePane == model.PaneTasks {
-			a.editTask()
-		}
-	case "a":
-		if a.state.ActivePane == model.PaneTasks {
-			a.addTask()
-		}
-	case "m":
-		if a.state.ActivePane == model.PaneTasks {
-			a.toggleTaskInfo()
-		}
-	case "W":
-		a.openCommandWithBuffer(":", "ws add ")
-	case "R":
-		a.openCommandWithBuffer(":", "ws rename ")
-	case "X":
-		a.openCommandWithBuffer(":", "ws delete")
-	case "H":
-		a.showHelp = !a.showHelp
-	case "x", " ", "space":
-		if a.state.ActivePane == model.PaneTasks {
-			a.toggleTask()
-		}
-	case "h":
-		if a.state.ActivePane == model.PaneTasks {
-			a.collapseTask()
-		}
-	case "l":
-		if a.state.ActivePane == model.PaneTasks {
-			a.expandTask()
-		}
-	case "j", "down":
-		if a.state.ActivePane == model.PaneWorkspaces {
-			a.moveWorkspace(1)
-		} else if a.showAsciiList {
-			a.scrollAscii(1)
-		} else {
-			a.moveCursor(1)
-		}
-	case "k", "up":
-		if a.state.ActivePane == model.PaneWorkspaces {
-			a.moveWorkspace(-1)
-		} else if a.showAsciiList {
-			a.scrollAscii(-1)
-		} else {
-			a.moveCursor(-1)
-		}
-	case "G":
-		if a.state.ActivePane == model.PaneTasks && a.showAsciiList {
-			a.scrollAsciiToEnd()
-		} else if a.state.ActivePane == model.PaneTasks {
-			a.moveToBottom()
-		}
-	case "gg":
-		if a.state.ActivePane == model.PaneTasks && a.showAsciiList {
-			a.scrollAsciiToTop()
-		} else if a.state.ActivePane == model.PaneTasks {
-			a.moveToTop()
-		}
-	case "pgdown":
-		if a.state.ActivePane == model.PaneTasks && a.showAsciiList {
-			a.scrollAscii(a.asciiPageStep())
-		}
-	case "pgup":
-		if a.state.ActivePane == model.PaneTasks && a.showAsciiList {
-			a.scrollAscii(-a.asciiPageStep())
-		}
-	case "enter":
-		if a.state.ActivePane == model.PaneWorkspaces {
-			a.selectWorkspace(a.state.SelectedWS)
-			a.state.ActivePane = model.PaneTasks
-		} else {
-			a.toggleTask()
-		}
-	case ">":
-		if a.state.ActivePane == model.PaneTasks {
-			a.indentTask()
-		}
-	case "<", "shift+tab":
-		if a.state.ActivePane == model.PaneTasks {
-			a.unindentTask()
-		}
-	default:
-		if msg.Type == tea.KeySpace && a.state.ActivePane == model.PaneTasks {
-			a.toggleTask()
-			return
-		}
-		a.handlePendingKey(msg.String())
+		return
+	}
+	// The lists shown over the tasks move by the same actions as the task
+	// list, so a rebound key works in all of them.
+	key := keyName(msg)
+	name := a.mappedAction(key)
+	if a.showFind && a.state.ActivePane == model.PaneTasks && a.handleFindKey(name) {
+		return
+	}
+	if a.showTags && a.state.ActivePane == model.PaneTasks && a.handleTagBrowserKey(key, name) {
+		return
+	}
+	if a.showHistory && a.state.ActivePane == model.PaneTasks && a.handleHistoryListKey(key, name) {
+		return
+	}
+	if a.showBoard && a.state.ActivePane == model.PaneTasks && !a.showHelp && a.handleBoardKey(key, name) {
+		return
+	}
+	if act, ok := findAction(name); ok {
+		act.run(a)
 	}
 }
 
@@ -461,16 +344,8 @@ func (a *App) handleInsertMode(msg tea.KeyMsg) {
 				a.saveNewTask()
 			}
 		}
-	case "backspace":
-		if len(a.taskInputBuf) > 0 {
-			a.taskInputBuf = a.taskInputBuf[:len(a.taskInputBuf)-1]
-		}
 	default:
-		if msg.Type == tea.KeyRunes {
-			a.taskInputBuf += string(msg.Runes)
-		} else if msg.Type == tea.KeySpace {
-			a.taskInputBuf += " "
-		}
+		a.editInput(msg)
 	}
 }
 
@@ -481,16 +356,8 @@ func (a *App) handleCommandMode(msg tea.KeyMsg) {
 		a.state.CommandBuf = ""
 	case "enter":
 		a.executeCommand()
-	case "backspace":
-		if len(a.state.CommandBuf) > 0 {
-			a.state.CommandBuf = a.state.CommandBuf[:len(a.state.CommandBuf)-1]
-		}
 	default:
-		if msg.Type == tea.KeyRunes {
-			a.state.CommandBuf += string(msg.Runes)
-		} else if msg.Type == tea.KeySpace {
-			a.state.CommandBuf += " "
-		}
+		a.editInput(msg)
 	}
 }
 
@@ -501,16 +368,8 @@ func (a *App) handleSearchMode(msg tea.KeyMsg) {
 		a.state.SearchBuf = ""
 	case "enter":
 		a.performSearch()
-	case "backspace":
-		if len(a.state.SearchBuf) > 0 {
-			a.state.SearchBuf = a.state.SearchBuf[:len(a.state.SearchBuf)-1]
-		}
 	default:
-		if msg.Type == tea.KeyRunes {
-			a.state.SearchBuf += string(msg.Runes)
-		} else if msg.Type == tea.KeySpace {
-			a.state.SearchBuf += " "
-		}
+		a.editInput(msg)
 	}
 }
 
@@ -519,6 +378,14 @@ func (a *App) View() string {
 		return "Loading..."
 	}
 
+	if a.focus != nil {
+		status := a.renderStatus()
+		return lipgloss.JoinVertical(lipgloss.Left,
+			a.renderFocus(a.width, a.height-lipgloss.Height(status)),
+			status,
+		)
+	}
+
 	header := a.renderHeader()
 	headerH := lipgloss.Height(header)
 	statusH := a.statusHeight()
@@ -527,10 +394,7 @@ func (a *App) View() string {
 		contentH = 0
 	}
 
-	sidebarW := 24
-	if sidebarW > a.width/2 {
-		sidebarW = a.width / 2
-	}
+	sidebarW := a.sidebarWidth()
 
 	sidebar := a.renderSidebar(sidebarW, contentH)
 	tasks := a.renderTasks(a.width-sidebarW, contentH)
@@ -549,7 +413,9 @@ type dashboardStats struct {
 	dueToday     int
 	overdue      int
 	highPriority int
+	inProgress   int
 	blocked      int
+	remaining    model.Estimate
 	todayTasks   []string
 }
 
@@ -561,7 +427,7 @@ func (a *App) getDashboardStats() dashboardStats {
 	countTasks = func(tasks []*model.Task) {
 		for _, t := range tasks {
 			stats.totalTasks++
-			if t.Completed {
+			if a.taskIsComplete(t) {
 				stats.completed++
 			} else {
 				if t.DueDate == today {
@@ -575,8 +441,11 @@ func (a *App) getDashboardStats() dashboardStats {
 				if t.Priority >= 2 {
 					stats.highPriority++
 				}
-				if t.Priority < 0 {
+				switch t.EffectiveStatus() {
+				case model.StatusBlocked:
 					stats.blocked++
+				case model.StatusInProgress:
+					stats.inProgress++
 				}
 			}
 			if len(t.Children) > 0 {
@@ -585,6 +454,7 @@ func (a *App) getDashboardStats() dashboardStats {
 		}
 	}
 	countTasks(a.tasks)
+	stats.remaining = a.workspaceRemaining()
 	return stats
 }
 
@@ -621,11 +491,21 @@ func (a *App) renderDashboard() string {
 		parts = append(parts, warnStyle.Render(fmt.Sprintf("!high:%d", stats.highPriority)))
 	}
 
+	// In progress
+	if stats.inProgress > 0 {
+		parts = append(parts, accentStyle.Render(fmt.Sprintf("wip:%d", stats.inProgress)))
+	}
+
 	// Blocked
 	if stats.blocked > 0 {
 		parts = append(parts, dangerStyle.Render(fmt.Sprintf("blocked:%d", stats.blocked)))
 	}
 
+	// Remaining effort
+	if !stats.remaining.IsZero() {
+		parts = append(parts, dimStyle.Render("left:"+stats.remaining.String()))
+	}
+
 	return dimStyle.Render("[ ") + strings.Join(parts, dimStyle.Render(" · ")) + dimStyle.Render(" ]")
 }
 
@@ -739,6 +619,23 @@ func (a *App) renderTasks(w, h int) string {
 	if a.state.SearchQuery != "" {
 		title = fmt.Sprintf("%s  %s", title, lipgloss.NewStyle().Foreground(dim).Render("[filter: "+a.state.SearchQuery+"]"))
 	}
+	if a.sortMode != sortManual {
+		title = fmt.Sprintf("%s  %s", title, lipgloss.NewStyle().Foreground(dim).Render("[sort: "+a.sortMode+"]"))
+	}
+	if a.showBoard {
+		title = fmt.Sprintf("%s  %s", title, lipgloss.NewStyle().Foreground(dim).Render("[board]"))
+	} else if a.groupBy != groupNone {
+		title = fmt.Sprintf("%s  %s", title, lipgloss.NewStyle().Foreground(dim).Render("[group: "+a.groupBy+"]"))
+	}
+	if a.showFind && a.findQuery == "" {
+		title = fmt.Sprintf("%s  %s", title, lipgloss.NewStyle().Foreground(dim).Render("[next]"))
+	} else if a.showFind {
+		title = fmt.Sprintf("%s  %s", title, lipgloss.NewStyle().Foreground(dim).Render("[find: "+a.findQuery+"]"))
+	} else if a.showTags {
+		title = fmt.Sprintf("%s  %s", title, lipgloss.NewStyle().Foreground(dim).Render("[tags]"))
+	} else if a.showHistory {
+		title = fmt.Sprintf("%s  %s", title, lipgloss.NewStyle().Foreground(dim).Render("[history]"))
+	}
 
 	b.WriteString(title + "\n")
 	b.WriteString(lipgloss.NewStyle().Foreground(borderColor).Render(strings.Repeat("─", innerW)) + "\n")
@@ -754,6 +651,36 @@ func (a *App) renderTasks(w, h int) string {
 			PaddingRight(1).
 			Render(b.String())
 	}
+	if a.showTags {
+		b.WriteString(a.renderTagBrowser(innerW, h-2))
+		return lipgloss.NewStyle().
+			Width(w).
+			Height(h).
+			Background(bgColor).
+			PaddingLeft(1).
+			PaddingRight(1).
+			Render(b.String())
+	}
+	if a.showHistory {
+		b.WriteString(a.renderHistoryList(innerW, h-2))
+		return lipgloss.NewStyle().
+			Width(w).
+			Height(h).
+			Background(bgColor).
+			PaddingLeft(1).
+			PaddingRight(1).
+			Render(b.String())
+	}
+	if a.showFind {
+		b.WriteString(a.renderFindResults(innerW, h-2))
+		return lipgloss.NewStyle().
+			Width(w).
+			Height(h).
+			Background(bgColor).
+			PaddingLeft(1).
+			PaddingRight(1).
+			Render(b.String())
+	}
 	if a.showHelp {
 		b.WriteString(a.renderHelpScreen(innerW, h-2))
 		return lipgloss.NewStyle().
@@ -764,6 +691,26 @@ func (a *App) renderTasks(w, h int) string {
 			PaddingRight(1).
 			Render(b.String())
 	}
+	if a.showBoard {
+		boardH := h - 2
+		if a.shouldShowTaskInfo() && a.selectedTask() != nil {
+			boardH -= a.taskInfoHeight()
+		}
+		b.WriteString(a.renderBoard(innerW, boardH))
+		if a.shouldShowTaskInfo() && a.selectedTask() != nil {
+			b.WriteString("\n" + a.renderTaskInfo(innerW))
+		}
+		if a.state.Mode == model.ModeInsert {
+			b.WriteString("\n" + a.renderTaskInput(innerW))
+		}
+		return lipgloss.NewStyle().
+			Width(w).
+			Height(h).
+			Background(bgColor).
+			PaddingLeft(1).
+			PaddingRight(1).
+			Render(b.String())
+	}
 
 	if len(a.flatTasks) == 0 {
 		empty := "empty list"
@@ -830,9 +777,20 @@ func (a *App) renderTasks(w, h int) string {
 		for i := a.taskScroll; i < endIdx; i++ {
 			line := a.flatTasks[i]
 			task := line.Task
+			if task == nil {
+				rendered := a.renderGroupHeader(line.Group, !line.Expanded, innerW)
+				if i == a.state.SelectedTask {
+					rendered = lipgloss.NewStyle().
+						Width(innerW).
+						Background(cursorBg).
+						Render(rendered)
+				}
+				taskLines = append(taskLines, rendered)
+				continue
+			}
 			prefix := strings.Repeat("  ", line.Depth)
 			marker := " "
-			if len(task.Children) > 0 {
+			if len(task.Children) > 0 && line.Group == nil {
 				if a.state.SearchQuery != "" || a.state.ExpandedTasks[task.ID] || task.ParentID == nil {
 					marker = "v"
 				} else {
@@ -860,9 +818,7 @@ func (a *App) renderTasks(w, h int) string {
 	}
 
 	if a.state.Mode == model.ModeInsert {
-		b.WriteString("\n" + lipgloss.NewStyle().
-			Foreground(accent).
-			Render("+ "+a.taskInputBuf+"_"))
+		b.WriteString("\n" + a.renderTaskInput(innerW))
 	}
 
 	return lipgloss.NewStyle().
@@ -877,16 +833,26 @@ func (a *App) renderTasks(w, h int) string {
 func (a *App) renderTaskLine(task *model.Task, prefix string, width int) string {
 	checkbox, progress := a.checkboxAndProgress(task)
 	meta := []string{}
-	if progress != "" {
+	remaining := a.remainingEstimate(task)
+	if progress != "" && !remaining.IsZero() {
+		meta = append(meta, "["+progress+" · "+remaining.String()+"]")
+	} else if progress != "" {
 		meta = append(meta, "["+progress+"]")
-	}
-	if len(task.Tags) > 0 {
-		meta = append(meta, formatTags(task.Tags))
+	} else if !remaining.IsZero() {
+		meta = append(meta, "("+remaining.String()+")")
 	}
 	metaStr := strings.TrimSpace(strings.Join(meta, " "))
 	if metaStr != "" {
 		metaStr = lipgloss.NewStyle().Foreground(dim).Render(metaStr)
 	}
+	if len(task.Tags) > 0 {
+		tagStyle := lipgloss.NewStyle().Foreground(dim)
+		tags := tagStyle.Render(formatTags(task.Tags))
+		if !a.taskIsComplete(task) {
+			tags = a.renderTags(task.Tags, tagStyle)
+		}
+		metaStr = strings.TrimSpace(metaStr + " " + tags)
+	}
 
 	left := strings.TrimSpace(fmt.Sprintf("%s%s %s", prefix, checkbox, task.Title))
 	if metaStr != "" {
@@ -898,7 +864,7 @@ func (a *App) renderTaskLine(task *model.Task, prefix string, width int) string
 		rightParts = append(rightParts, icon)
 	}
 	if task.DueDate != "" {
-		rightParts = append(rightParts, task.DueDate)
+		rightParts = append(rightParts, a.formatDue(task.DueDate))
 	}
 	right := strings.Join(rightParts, " ")
 
@@ -920,10 +886,10 @@ func (a *App) renderTaskLine(task *model.Task, prefix string, width int) string
 		left = truncateText(left, width)
 	}
 
-	if task.Priority < 0 {
-		left = lipgloss.NewStyle().Foreground(dim).Render(left)
-	} else if a.taskIsComplete(task) {
+	if a.taskIsComplete(task) {
 		left = lipgloss.NewStyle().Strikethrough(true).Foreground(doneColor).Render(left)
+	} else if status := task.EffectiveStatus(); status == model.StatusBlocked || status == model.StatusWaiting {
+		left = lipgloss.NewStyle().Foreground(dim).Render(left)
 	}
 
 	return left
@@ -954,15 +920,22 @@ func (a *App) renderStatus() string {
 		right = fmt.Sprintf(" %s%s ", weather, time.Now().Format("03:04 PM"))
 	}
 
+	left += a.renderTimer(a.width - lipgloss.Width(left) - lipgloss.Width(right))
+
 	statusWidth := a.width - lipgloss.Width(left) - lipgloss.Width(right)
 	if statusWidth > 0 {
 		left += strings.Repeat(" ", statusWidth)
 	}
 
+	lines := []string{left + right}
+	if a.completion != nil {
+		lines = append(lines, padToWidth(a.renderCompletion(a.width), a.width))
+	}
+	lines = append(lines, padToWidth(a.renderCommandLine(), a.width))
 	return lipgloss.NewStyle().
 		Width(a.width).
 		Background(borderColor).
-		Render(lipgloss.JoinVertical(lipgloss.Left, left+right, padToWidth(a.renderCommandLine(), a.width)))
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
 }
 
 func (a *App) renderCommandLine() string {
@@ -972,9 +945,17 @@ func (a *App) renderCommandLine() string {
 		if leader == "" {
 			leader = ":"
 		}
-		return lipgloss.NewStyle().Foreground(accent).Render(leader + a.state.CommandBuf)
+		if a.histSearch != nil {
+			return a.renderHistorySearch(a.width)
+		}
+		style := lipgloss.NewStyle().Foreground(accent)
+		return style.Render(leader) + a.renderInput(a.state.CommandBuf, style, a.width-lipgloss.Width(leader))
 	case model.ModeSearch:
-		return lipgloss.NewStyle().Foreground(accent).Render("?"+a.state.SearchBuf)
+		if a.histSearch != nil {
+			return a.renderHistorySearch(a.width)
+		}
+		style := lipgloss.NewStyle().Foreground(accent)
+		return style.Render("?") + a.renderInput(a.state.SearchBuf, style, a.width-1)
 	default:
 		if a.state.Msg != "" {
 			return lipgloss.NewStyle().Foreground(accent).Render(a.state.Msg)
@@ -984,10 +965,14 @@ func (a *App) renderCommandLine() string {
 }
 
 func (a *App) statusHeight() int {
+	h := 1
 	if a.state.Mode == model.ModeCommand || a.state.Mode == model.ModeSearch || a.state.Msg != "" {
-		return 2
+		h = 2
+	}
+	if a.completion != nil {
+		h++
 	}
-	return 1
+	return h
 }
 
 func (a *App) loadWorkspaces() {
@@ -1016,13 +1001,26 @@ func (a *App) loadTasks() {
 	a.tasks, _ = a.db.GetTasksForWorkspace(ws.ID)
 	a.taskIndex = make(map[int64]*model.Task)
 	a.indexTasks(a.tasks)
+	a.deps = nil
+	a.tagColors, _ = a.db.TagColors()
+	if a.showTags {
+		a.loadTagList()
+	}
+	a.loadTimer()
+	a.loadSortMode()
+	a.loadGroupMode()
 	a.flattenTasks()
 }
 
 func (a *App) flattenTasks() {
 	a.flatTasks = nil
 	tasks := a.applyFilter(a.tasks, a.state.SearchQuery)
-	a.walkTasks(tasks, 0, 0)
+	a.sortTasks(tasks)
+	if a.groupBy != groupNone {
+		a.walkGroups(tasks)
+	} else {
+		a.walkTasks(tasks, 0, 0)
+	}
 	if a.state.SelectedTask >= len(a.flatTasks) {
 		a.state.SelectedTask = len(a.flatTasks) - 1
 	}
@@ -1098,7 +1096,7 @@ func (a *App) selectWorkspaceByToken(token string) {
 
 	lower := strings.ToLower(token)
 	for i, ws := range a.workspaces {
-		if strings.ToLower(ws.Name) == lower {
+		if strings.ToLower(ws.Name) == lower || strings.ToLower(workspaceSlug(ws.Name)) == lower {
 			a.selectWorkspace(i)
 			return
 		}
@@ -1145,17 +1143,25 @@ func (a *App) moveToBottom() {
 }
 
 func (a *App) toggleTask() {
-	if a.state.SelectedTask >= len(a.flatTasks) {
+	if line, ok := a.selectedLine(); ok && line.Task == nil {
+		a.toggleGroup(line.Expanded)
+		return
+	}
+	task := a.selectedTask()
+	if task == nil {
 		return
 	}
-	task := a.flatTasks[a.state.SelectedTask].Task
+	completing := !a.taskIsComplete(task)
 	if len(task.Children) > 0 {
-		target := !a.taskIsComplete(task)
-		a.setTaskTreeCompleted(task, target)
+		a.setTaskTreeCompleted(task, completing)
 	} else {
-		a.db.SetTaskCompleted(task.ID, !task.Completed)
+		completing = !task.Completed
+		a.db.SetTaskCompleted(task.ID, completing)
 	}
 	a.loadTasks()
+	if completing && a.timer != nil && a.timer.TaskID == task.ID {
+		a.stopTimer()
+	}
 }
 
 func (a *App) toggleTaskInfo() {
@@ -1171,18 +1177,77 @@ func (a *App) selectedTaskHasInfo() bool {
 	if task == nil {
 		return false
 	}
-	return len(task.Tags) > 0 || task.DueDate != "" || task.Priority != 0
+	return len(task.Tags) > 0 || task.DueDate != "" || task.Priority != 0 || task.Notes != "" ||
+		(task.Status != "" && task.Status != model.StatusOpen) || len(task.DependsOn) > 0 || !task.Estimate.IsZero() ||
+		len(task.Reminders) > 0
 }
 
 func (a *App) selectedTask() *model.Task {
-	if a.state.SelectedTask >= len(a.flatTasks) || a.state.SelectedTask < 0 {
+	if a.showBoard {
+		return a.boardSelectedTask()
+	}
+	line, ok := a.selectedLine()
+	if !ok {
 		return nil
 	}
-	return a.flatTasks[a.state.SelectedTask].Task
+	return line.Task
+}
+
+// selectedLine returns the list line under the cursor. It is not meaningful
+// while the board is shown.
+func (a *App) selectedLine() (TaskLine, bool) {
+	if a.showBoard || a.state.SelectedTask >= len(a.flatTasks) || a.state.SelectedTask < 0 {
+		return TaskLine{}, false
+	}
+	return a.flatTasks[a.state.SelectedTask], true
 }
 
 func (a *App) taskInfoHeight() int {
-	return 6
+	task := a.selectedTask()
+	if task == nil {
+		return 7
+	}
+	return 7 + len(a.taskInfoExtras(task))
+}
+
+// taskInfoExtras returns the optional rows of the details panel, shown only
+// when they have something to say.
+func (a *App) taskInfoExtras(task *model.Task) []taskInfoRow {
+	var rows []taskInfoRow
+	remaining := a.remainingEstimate(task)
+	if !task.Estimate.IsZero() || !remaining.IsZero() {
+		value := task.Estimate.String()
+		if len(task.Children) > 0 || remaining != task.Estimate {
+			if value == "" {
+				value = "-"
+			}
+			if remaining.IsZero() {
+				value += " · nothing left"
+			} else {
+				value += " · " + remaining.String() + " left"
+			}
+		}
+		rows = append(rows, taskInfoRow{"Estimate", value})
+	}
+	if len(task.Reminders) > 0 {
+		rows = append(rows, taskInfoRow{"Remind", formatReminders(task)})
+	}
+	deps := a.dependenciesOf(task)
+	if len(deps.blockers) > 0 {
+		rows = append(rows, taskInfoRow{"Depends", formatDependencyRefs(deps.blockers)})
+	}
+	if len(deps.dependents) > 0 {
+		rows = append(rows, taskInfoRow{"Blocks", formatDependencyRefs(deps.dependents)})
+	}
+	if task.UUID != "" {
+		rows = append(rows, taskInfoRow{"UUID", task.UUID})
+	}
+	return rows
+}
+
+type taskInfoRow struct {
+	label string
+	value string
 }
 
 func (a *App) renderTaskInfo(width int) string {
@@ -1207,20 +1272,28 @@ func (a *App) renderTaskInfo(width int) string {
 		Padding(0, 1)
 
 	// Status
-	status := "pending"
+	effective := task.EffectiveStatus()
+	status := string(effective)
+	if status == "" {
+		status = string(model.StatusOpen)
+	}
+	if effective != task.Status {
+		status += fmt.Sprintf(" (waiting on %d)", len(task.BlockedBy))
+	}
 	statusStyle := valueStyle
-	if task.Completed {
-		status = "done"
+	switch {
+	case a.taskIsComplete(task):
 		statusStyle = lipgloss.NewStyle().Foreground(doneColor).Background(panelBg)
+	case effective == model.StatusBlocked:
+		statusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#e06c75")).Background(panelBg)
+	case effective == model.StatusInProgress || effective == model.StatusWaiting:
+		statusStyle = accentValue
 	}
 
 	// Priority with color
 	var priorityStr string
 	priorityStyle := valueStyle
 	switch {
-	case task.Priority < 0:
-		priorityStr = "blocked"
-		priorityStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#e06c75")).Background(panelBg)
 	case task.Priority >= 2:
 		priorityStr = "high"
 		priorityStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#e5c07b")).Background(panelBg)
@@ -1233,7 +1306,7 @@ func (a *App) renderTaskInfo(width int) string {
 	// Due date
 	dueStr := "-"
 	if task.DueDate != "" {
-		dueStr = task.DueDate
+		dueStr = a.formatDue(task.DueDate)
 	}
 
 	// Tags
@@ -1242,6 +1315,12 @@ func (a *App) renderTaskInfo(width int) string {
 		tagStr = formatTags(task.Tags)
 	}
 
+	// Notes
+	notesStr := "-"
+	if task.Notes != "" {
+		notesStr = task.Notes
+	}
+
 	// Calculate max value width and truncate if needed
 	labelWidth := 10
 	maxValueWidth := width - labelWidth - 2
@@ -1251,10 +1330,14 @@ func (a *App) renderTaskInfo(width int) string {
 
 	// Truncate long values
 	tagStr = truncateText(tagStr, maxValueWidth)
+	if len(task.Tags) > 0 {
+		tagStr = truncateText(a.renderTags(task.Tags, valueStyle), maxValueWidth)
+	}
 	dueStr = truncateText(dueStr, maxValueWidth)
+	notesStr = truncateText(notesStr, maxValueWidth)
 
 	// Build header line with full-width accent background
-	headerText := headerStyle.Render("▸ DETAILS")
+	headerText := headerStyle.Render(fmt.Sprintf("▸ DETAILS  #%d", task.ID))
 	headerPadding := width - lipgloss.Width(headerText)
 	if headerPadding < 0 {
 		headerPadding = 0
@@ -1266,24 +1349,34 @@ func (a *App) renderTaskInfo(width int) string {
 	line3 := labelStyle.Render(" Priority ") + priorityStyle.Render(priorityStr)
 	line4 := labelStyle.Render(" Due      ") + accentValue.Render(dueStr)
 	line5 := labelStyle.Render(" Tags     ") + valueStyle.Render(tagStr)
+	line6 := labelStyle.Render(" Notes    ") + valueStyle.Render(notesStr)
 
 	// Wrap content in box style
 	boxStyle := lipgloss.NewStyle().
 		Background(panelBg).
 		Width(width)
 
-	return headerLine + "\n" +
+	info := headerLine + "\n" +
 		boxStyle.Render(line2) + "\n" +
 		boxStyle.Render(line3) + "\n" +
 		boxStyle.Render(line4) + "\n" +
-		boxStyle.Render(line5)
+		boxStyle.Render(line5) + "\n" +
+		boxStyle.Render(line6)
+	for _, row := range a.taskInfoExtras(task) {
+		info += "\n" + boxStyle.Render(labelStyle.Render(fmt.Sprintf(" %-9s", row.label))+valueStyle.Render(truncateText(row.value, maxValueWidth)))
+	}
+	return info
 }
 
 func (a *App) collapseTask() {
-	if a.state.SelectedTask >= len(a.flatTasks) {
+	if a.selectedGroup() != nil {
+		a.toggleGroup(true)
+		return
+	}
+	task := a.selectedTask()
+	if task == nil {
 		return
 	}
-	task := a.flatTasks[a.state.SelectedTask].Task
 	if len(task.Children) > 0 {
 		delete(a.state.ExpandedTasks, task.ID)
 		a.loadTasks()
@@ -1291,10 +1384,14 @@ func (a *App) collapseTask() {
 }
 
 func (a *App) expandTask() {
-	if a.state.SelectedTask >= len(a.flatTasks) {
+	if a.selectedGroup() != nil {
+		a.toggleGroup(false)
+		return
+	}
+	task := a.selectedTask()
+	if task == nil {
 		return
 	}
-	task := a.flatTasks[a.state.SelectedTask].Task
 	if len(task.Children) > 0 {
 		a.state.ExpandedTasks[task.ID] = true
 		a.loadTasks()
@@ -1306,25 +1403,56 @@ func (a *App) saveNewTask() {
 		return
 	}
 	ws := a.workspaces[a.state.SelectedWS]
-	parsed := ParseTaskInput(a.taskInputBuf)
+	parsed := model.ParseTaskInput(a.taskInputBuf, a.db)
 	if parsed.Title == "" {
 		a.state.Mode = model.ModeNormal
 		a.taskInputBuf = ""
 		a.newTaskParent = nil
 		return
 	}
-	a.db.AddTaskWithMeta(ws.ID, parsed.Title, a.newTaskParent, parsed.Tags, parsed.DueDate, parsed.Priority)
+	status := parsed.Status
+	if status == "" {
+		status = a.newTaskStatus
+	}
+	parent := a.newTaskParent
+	if parsed.Workspace != "" {
+		id, err := a.db.ResolveWorkspace(parsed.Workspace)
+		if err != nil {
+			a.setMessage(err.Error())
+			return
+		}
+		if id != ws.ID {
+			// The parent lives in the current workspace.
+			ws = db.Workspace{ID: id}
+			parent = nil
+			for _, w := range a.workspaces {
+				if w.ID == id {
+					ws = w
+				}
+			}
+		}
+	}
+	id, err := a.db.AddTaskWithMeta(ws.ID, parsed.Title, parent, parsed.Tags, parsed.DueDate, parsed.Priority, status, parsed.Estimate)
+	if err == nil {
+		a.addHistory(db.HistoryTask, a.taskInputBuf)
+		a.addDependencies(id, parsed.DependsOn)
+		a.addReminders(id, parsed.Reminders)
+		if ws.ID != a.workspaces[a.state.SelectedWS].ID {
+			a.setMessage("added to " + ws.Name)
+		}
+	}
 	a.state.Mode = model.ModeNormal
 	a.taskInputBuf = ""
 	a.newTaskParent = nil
+	a.newTaskStatus = ""
 	a.loadTasks()
 }
 
 func (a *App) deleteTask() {
-	if a.state.SelectedTask >= len(a.flatTasks) {
+	task := a.selectedTask()
+	if task == nil {
 		return
 	}
-	task := a.flatTasks[a.state.SelectedTask].Task
 	a.db.DeleteTask(task.ID)
 	a.loadTasks()
 }
@@ -1338,6 +1466,12 @@ func (a *App) executeCommand() {
 		a.state.CommandBuf = ""
 		return
 	}
+	a.addHistory(db.HistoryCommand, command)
+	if name, ok := commandAliases[fields[0]]; ok {
+		a.completionUses[":"+name]++
+	} else {
+		a.completionUses[":"+fields[0]]++
+	}
 
 	switch fields[0] {
 	case "q", "quit", "wq":
@@ -1354,12 +1488,23 @@ func (a *App) executeCommand() {
 		a.executeWeatherCommand(fields)
 	case "search":
 		a.executeSearchCommand(fields)
+	case "find":
+		a.executeFindCommand(originalFields)
+	case "sort":
+		a.executeSortCommand(fields)
+	case "group", "groupby":
+		a.executeGroupCommand(fields)
+	case "board", "kanban":
+		a.executeBoardCommand(fields)
 	case "info":
 		a.executeInfoCommand(fields)
 	case "help":
 		a.showHelp = true
+		a.showKeys = false
 	case "focus", "pane":
-		if len(fields) > 1 {
+		if fields[0] == "focus" && !isPaneName(fields) {
+			a.executeFocusCommand(fields)
+		} else if len(fields) > 1 {
 			if fields[1] == "ws" || fields[1] == "workspaces" || fields[1] == "workspace" {
 				a.state.ActivePane = model.PaneWorkspaces
 			} else if fields[1] == "tasks" || fields[1] == "todos" {
@@ -1370,12 +1515,34 @@ func (a *App) executeCommand() {
 		a.executeDueCommand(originalFields)
 	case "tag", "tags":
 		a.executeTagCommand(originalFields)
+	case "note", "notes":
+		a.executeNoteCommand(originalFields)
 	case "priority", "p":
 		a.executePriorityCommand(fields)
+	case "status", "s":
+		a.executeStatusCommand(fields)
+	case "depends", "depend", "dep", "deps":
+		a.executeDependsCommand(fields)
+	case "next":
+		a.executeNextCommand()
+	case "timer", "time":
+		a.executeTimerCommand(fields)
+	case "estimate", "est":
+		a.executeEstimateCommand(fields)
+	case "pomodoro", "pomo":
+		a.executeFocusCommand(fields)
+	case "remind", "reminder", "reminders":
+		a.executeRemindCommand(fields)
 	case "clear":
 		a.executeClearCommand(fields)
 	case "dashboard", "dash", "db":
 		a.executeDashboardCommand(fields)
+	case "history", "hist":
+		a.executeHistoryCommand(fields)
+	case "map", "unmap":
+		a.executeMapCommand(originalFields)
+	case "config":
+		a.executeConfigCommand(fields)
 	}
 	a.state.Mode = model.ModeNormal
 	a.state.CommandBuf = ""
@@ -1393,7 +1560,7 @@ func (a *App) executeDueCommand(fields []string) {
 		a.state.MsgTimeout = 3
 		return
 	}
-	task.DueDate = parseDueDate(fields[1])
+	task.DueDate = model.ParseDueDate(fields[1])
 	a.db.UpdateTask(task)
 	a.loadTasks()
 	a.state.Msg = "due date set to " + task.DueDate
@@ -1401,17 +1568,15 @@ func (a *App) executeDueCommand(fields []string) {
 }
 
 func (a *App) executeTagCommand(fields []string) {
+	if a.executeTagAdminCommand(fields) {
+		return
+	}
 	task := a.selectedTask()
 	if task == nil {
 		a.state.Msg = "no task selected"
 		a.state.MsgTimeout = 3
 		return
 	}
-	if len(fields) < 2 {
-		a.state.Msg = "usage: :tag <tag1> [tag2] ..."
-		a.state.MsgTimeout = 3
-		return
-	}
 	for _, t := range fields[1:] {
 		tag := strings.TrimPrefix(t, "#")
 		if tag != "" {
@@ -1424,6 +1589,49 @@ func (a *App) executeTagCommand(fields []string) {
 	a.state.MsgTimeout = 2
 }
 
+func (a *App) executeNoteCommand(fields []string) {
+	task := a.selectedTask()
+	if task == nil {
+		a.state.Msg = "no task selected"
+		a.state.MsgTimeout = 3
+		return
+	}
+	if len(fields) < 2 {
+		a.state.Msg = "usage: :note <text>"
+		a.state.MsgTimeout = 3
+		return
+	}
+	task.Notes = strings.Join(fields[1:], " ")
+	a.db.UpdateTask(task)
+	a.loadTasks()
+	a.state.Msg = "note saved"
+	a.state.MsgTimeout = 2
+}
+
+func (a *App) executeStatusCommand(fields []string) {
+	task := a.selectedTask()
+	if task == nil {
+		a.state.Msg = "no task selected"
+		a.state.MsgTimeout = 3
+		return
+	}
+	if len(fields) < 2 {
+		a.state.Msg = "usage: :status <open|wip|waiting|blocked|done|cancelled>"
+		a.state.MsgTimeout = 3
+		return
+	}
+	status, ok := model.ParseStatus(fields[1])
+	if !ok {
+		a.state.Msg = "unknown status: " + fields[1]
+		a.state.MsgTimeout = 3
+		return
+	}
+	a.db.SetTaskStatus(task.ID, status)
+	a.loadTasks()
+	a.state.Msg = "status set to " + string(status)
+	a.state.MsgTimeout = 2
+}
+
 func (a *App) executePriorityCommand(fields []string) {
 	task := a.selectedTask()
 	if task == nil {
@@ -1432,7 +1640,7 @@ func (a *App) executePriorityCommand(fields []string) {
 		return
 	}
 	if len(fields) < 2 {
-		a.state.Msg = "usage: :priority <high|low|normal|blocked>"
+		a.state.Msg = "usage: :priority <high|low|normal>"
 		a.state.MsgTimeout = 3
 		return
 	}
@@ -1442,7 +1650,9 @@ func (a *App) executePriorityCommand(fields []string) {
 	case "low", "l", "1":
 		task.Priority = 1
 	case "blocked", "b", "-1":
-		task.Priority = -1
+		// Blocked used to be a priority; keep the old spelling working.
+		a.executeStatusCommand([]string{"status", "blocked"})
+		return
 	case "normal", "n", "0":
 		task.Priority = 0
 	default:
@@ -1464,10 +1674,19 @@ func (a *App) executeClearCommand(fields []string) {
 		return
 	}
 	if len(fields) < 2 {
-		a.state.Msg = "usage: :clear <due|tags|priority|all>"
+		a.state.Msg = "usage: :clear <due|tags|priority|status|notes|estimate|remind|deps|all>"
 		a.state.MsgTimeout = 3
 		return
 	}
+	clearStatus := func() {
+		task.Status = model.StatusOpen
+		task.Completed = false
+	}
+	clearDeps := func() {
+		for _, id := range task.DependsOn {
+			a.db.RemoveDependency(task.ID, id)
+		}
+	}
 	switch fields[1] {
 	case "due", "date":
 		task.DueDate = ""
@@ -1475,10 +1694,25 @@ func (a *App) executeClearCommand(fields []string) {
 		task.Tags = nil
 	case "priority", "p":
 		task.Priority = 0
+	case "notes", "note":
+		task.Notes = ""
+	case "status", "s":
+		clearStatus()
+	case "estimate", "est":
+		task.Estimate = model.Estimate{}
+	case "remind", "reminders":
+		a.db.ClearReminders(task.ID)
+	case "deps", "depends":
+		clearDeps()
 	case "all":
 		task.DueDate = ""
 		task.Tags = nil
 		task.Priority = 0
+		task.Notes = ""
+		task.Estimate = model.Estimate{}
+		clearStatus()
+		a.db.ClearReminders(task.ID)
+		clearDeps()
 	default:
 		a.state.Msg = "unknown field: " + fields[1]
 		a.state.MsgTimeout = 3
@@ -1651,6 +1885,7 @@ func (a *App) applyScheme(name string) bool {
 }
 
 func (a *App) performSearch() {
+	a.addHistory(db.HistorySearch, a.state.SearchBuf)
 	a.state.SearchQuery = strings.TrimSpace(a.state.SearchBuf)
 	a.state.SearchBuf = ""
 	a.state.Mode = model.ModeNormal
@@ -1669,10 +1904,10 @@ func (a *App) countCompletedChildren(task *model.Task) int {
 
 func (a *App) countStats(tasks []*model.Task) (completed, open, blocked int) {
 	for _, task := range tasks {
-		if task.Priority < 0 {
-			blocked++
-		} else if a.taskIsComplete(task) {
+		if a.taskIsComplete(task) {
 			completed++
+		} else if task.EffectiveStatus() == model.StatusBlocked {
+			blocked++
 		} else {
 			open++
 		}
@@ -1687,9 +1922,6 @@ func (a *App) countStats(tasks []*model.Task) (completed, open, blocked int) {
 }
 
 func (a *App) checkboxAndProgress(task *model.Task) (string, string) {
-	if task.Priority < 0 {
-		return "✖", ""
-	}
 	if len(task.Children) > 0 {
 		completed := a.countCompletedChildren(task)
 		total := len(task.Children)
@@ -1697,20 +1929,19 @@ func (a *App) checkboxAndProgress(task *model.Task) (string, string) {
 		if completed == total {
 			return "☑", progress
 		}
-		return "☐", progress
+		return statusIcon(task.EffectiveStatus()), progress
 	}
 	if task.Completed {
 		return "☑", ""
 	}
-	return "☐", ""
+	return statusIcon(task.EffectiveStatus()), ""
 }
 
+// taskIsComplete reports whether a task is closed. A parent is complete once
+// all of its subtasks are; cancelled tasks count as complete.
 func (a *App) taskIsComplete(task *model.Task) bool {
-	if task.Priority < 0 {
-		return false
-	}
 	if len(task.Children) == 0 {
-		return task.Completed
+		return task.Completed || task.Status.Closed()
 	}
 	return a.countCompletedChildren(task) == len(task.Children)
 }
@@ -1727,16 +1958,25 @@ func (a *App) addTask() {
 	a.taskInputBuf = ""
 	a.editingTaskID = nil
 	a.newTaskParent = nil
-	if a.state.SelectedTask < len(a.flatTasks) {
-		a.newTaskParent = a.flatTasks[a.state.SelectedTask].Task.ParentID
+	if task := a.selectedTask(); task != nil {
+		a.newTaskParent = task.ParentID
+	}
+	if group := a.selectedGroup(); group != nil {
+		a.taskInputBuf = group.prefix
+	}
+	a.newTaskStatus = ""
+	if a.showBoard {
+		// Cards added on the board land in the focused column.
+		a.newTaskParent = nil
+		a.newTaskStatus = boardColumns[clamp(a.boardCol, 0, len(boardColumns)-1)].status
 	}
 }
 
 func (a *App) editTask() {
-	if a.state.SelectedTask >= len(a.flatTasks) {
+	task := a.selectedTask()
+	if task == nil {
 		return
 	}
-	task := a.flatTasks[a.state.SelectedTask].Task
 	a.state.Mode = model.ModeInsert
 	a.taskInputBuf = task.Title
 	a.editingTaskID = &task.ID
@@ -1751,7 +1991,11 @@ func (a *App) saveTaskEdit() {
 	if task == nil {
 		return
 	}
-	parsed := ParseTaskInput(a.taskInputBuf)
+	parsed := model.ParseTaskInput(a.taskInputBuf, a.db)
+	if parsed.Workspace != "" {
+		a.setMessage("+workspace only applies to new tasks")
+		return
+	}
 	task.Title = parsed.Title
 	if len(parsed.Tags) > 0 {
 		task.Tags = append(task.Tags, parsed.Tags...)
@@ -1762,7 +2006,16 @@ func (a *App) saveTaskEdit() {
 	if parsed.Priority != 0 {
 		task.Priority = parsed.Priority
 	}
+	if parsed.Status != "" {
+		task.Status = parsed.Status
+		task.Completed = parsed.Status == model.StatusDone
+	}
+	if !parsed.Estimate.IsZero() {
+		task.Estimate = parsed.Estimate
+	}
 	a.db.UpdateTask(task)
+	a.addDependencies(task.ID, parsed.DependsOn)
+	a.addReminders(task.ID, parsed.Reminders)
 	a.state.Mode = model.ModeNormal
 	a.taskInputBuf = ""
 	a.editingTaskID = nil
@@ -1773,6 +2026,10 @@ func (a *App) indentTask() {
 	if a.state.SelectedTask <= 0 || a.state.SelectedTask >= len(a.flatTasks) {
 		return
 	}
+	if a.groupBy != groupNone || a.showBoard {
+		a.setMessage("indent is only available in the tree view")
+		return
+	}
 	current := a.flatTasks[a.state.SelectedTask]
 	prev := a.flatTasks[a.state.SelectedTask-1]
 	if prev.Depth != current.Depth {
@@ -1787,11 +2044,8 @@ func (a *App) indentTask() {
 }
 
 func (a *App) unindentTask() {
-	if a.state.SelectedTask >= len(a.flatTasks) {
-		return
-	}
-	task := a.flatTasks[a.state.SelectedTask].Task
-	if task.ParentID == nil {
+	task := a.selectedTask()
+	if task == nil || task.ParentID == nil {
 		return
 	}
 	parent := a.taskIndex[*task.ParentID]
@@ -1834,6 +2088,24 @@ func (a *App) applyFilter(tasks []*model.Task, query string) []*model.Task {
 }
 
 func (a *App) taskMatches(task *model.Task, query string) bool {
+	// "#tag" keeps tasks carrying exactly that tag.
+	if name, ok := strings.CutPrefix(query, "#"); ok && name != "" {
+		for _, tag := range task.Tags {
+			if strings.ToLower(tag) == name {
+				return true
+			}
+		}
+		return false
+	}
+	// "%blocked" or "status:blocked" filters by status instead of text.
+	if name, ok := strings.CutPrefix(query, "%"); ok {
+		status, valid := model.ParseStatus(name)
+		return valid && task.EffectiveStatus() == status
+	}
+	if name, ok := strings.CutPrefix(query, "status:"); ok {
+		status, valid := model.ParseStatus(name)
+		return valid && task.EffectiveStatus() == status
+	}
 	if strings.Contains(strings.ToLower(task.Title), query) {
 		return true
 	}
@@ -1854,38 +2126,6 @@ func (a *App) indexTasks(tasks []*model.Task) {
 	}
 }
 
-func (a *App) handlePendingKey(key string) {
-	switch key {
-	case "d", "g":
-		if a.state.ActivePane != model.PaneTasks {
-			a.pendingKey = ""
-			return
-		}
-		if a.pendingKey == key && time.Since(a.pendingAt) < 800*time.Millisecond {
-			a.pendingKey = ""
-			if key == "d" {
-				a.deleteTask()
-			} else {
-				a.moveToTop()
-			}
-			return
-		}
-		a.pendingKey = key
-		a.pendingAt = time.Now()
-	default:
-		a.pendingKey = ""
-	}
-}
-
-func (a *App) clearPendingKey() {
-	if a.pendingKey == "" {
-		return
-	}
-	if time.Since(a.pendingAt) > time.Second {
-		a.pendingKey = ""
-	}
-}
-
 func formatTags(tags []string) string {
 	var cleaned []string
 	for _, tag := range tags {
@@ -1901,6 +2141,23 @@ func formatTags(tags []string) string {
 	return strings.Join(cleaned, " ")
 }
 
+func statusIcon(status model.Status) string {
+	switch status {
+	case model.StatusInProgress:
+		return "◐"
+	case model.StatusWaiting:
+		return "◷"
+	case model.StatusBlocked:
+		return "✖"
+	case model.StatusDone:
+		return "☑"
+	case model.StatusCancelled:
+		return "⊘"
+	default:
+		return "☐"
+	}
+}
+
 func priorityIcon(priority int) string {
 	switch {
 	case priority >= 2:
@@ -1916,21 +2173,10 @@ func truncateText(s string, width int) string {
 	if width <= 0 || s == "" {
 		return ""
 	}
-	if lipgloss.Width(s) <= width {
-		return s
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		s = s[:i]
 	}
-
-	count := 0
-	for i, r := range s {
-		if count == width {
-			return s[:i]
-		}
-		if r == '\n' || r == '\r' {
-			return s[:i]
-		}
-		count++
-	}
-	return s
+	return ansi.Truncate(s, width, "")
 }
 
 func padToWidth(s string, width int) string {
@@ -1991,10 +2237,22 @@ func availableSchemes() []string {
 
 func (a *App) executeSettingsCommand(fields []string) {
 	if len(fields) < 2 {
-		a.setMessage("settings: weather on|off, city <name>, unit c|f")
+		a.setMessage("settings: weather on|off, city <name>, unit c|f, pomodoro 25/5/15/4")
 		return
 	}
 	switch fields[1] {
+	case "pomodoro", "pomo":
+		if len(fields) < 3 {
+			a.setMessage("pomodoro: " + a.loadPomodoroCycle().String() + " (work/short/long/every)")
+			return
+		}
+		cycle, ok := parsePomodoroCycle(strings.Join(fields[2:], "/"))
+		if !ok {
+			a.setMessage("usage: :settings pomodoro 25/5/15/4")
+			return
+		}
+		_ = a.db.SetSetting("pomodoro", cycle.String())
+		a.setMessage("pomodoro: " + cycle.String())
 	case "weather":
 		if len(fields) > 2 {
 			switch fields[2] {
@@ -2191,8 +2449,20 @@ type weatherMsg struct {
 func (a *App) Run() error {
 	a.loadAsciiArt()
 	a.loadSettings()
+	a.loadHistoryUses()
+	if err := a.loadConfig(); err != nil {
+		a.setMessage("config: " + err.Error())
+	}
+	if err := a.loadKeymap(); err != nil {
+		a.setMessage("keys: " + err.Error())
+	}
 	a.loadWorkspaces()
-	p := tea.NewProgram(a, tea.WithAltScreen())
+	if err := a.selectDefaultWorkspace(); err != nil {
+		a.setMessage("config: " + err.Error())
+	}
+	out := &terminalOutput{File: os.Stdout}
+	a.output = out
+	p := tea.NewProgram(a, tea.WithAltScreen(), tea.WithOutput(out))
 	_, err := p.Run()
 	return err
 }
@@ -2389,6 +2659,18 @@ func (a *App) renderHelpScreen(width, height int) string {
 		"  dd              delete task",
 		"  h/l             collapse / expand",
 		"  m               toggle details panel",
+		"  S               cycle sort mode",
+		"  s               start / stop timer",
+		"  tab / shift+tab complete #tag, @date, +workspace, commands",
+		"",
+		"Editing input",
+		"  left/right      move (ctrl+b/f)",
+		"  home/end        start / end of line (ctrl+a/e)",
+		"  alt+b / alt+f   back / forward a word",
+		"  ctrl+w / alt+d  delete word before / after",
+		"  ctrl+u / ctrl+k delete to start / end of line",
+		"  up/down         recall earlier input starting with what is typed",
+		"  ctrl+r          search earlier input",
 		"",
 		"Workspaces",
 		"  W               add workspace",
@@ -2398,14 +2680,32 @@ func (a *App) renderHelpScreen(width, height int) string {
 		"Commands",
 		"  /help           show this screen",
 		"  /search <q>     filter tasks",
+		"  /find <q>       search all workspaces (ctrl+f)",
+		"  /sort <mode>    manual|due|priority|created|alpha|completed-last",
+		"  /group <by>     tag|due|priority|off",
+		"  /tags           browse tags (rename, merge, color)",
+		"  /tag color <t> <c>  color a tag (red, 208, #ff8800, none)",
+		"  /board          board view (h/l columns, </> move card)",
+		"  /note <text>    set notes on task",
+		"  /depends <id>   depend on task #id (rm <id> to drop)",
+		"  /next           unblocked next actions",
+		"  /timer [stop]   show or stop the running timer",
+		"  /estimate <e>   set estimate (2h, 90m, 3p)",
+		"  /focus          pomodoro focus on task (space pause, n skip)",
+		"  /remind <when>  remind 30m-before, 14:00, friday@9:00",
 		"  /ws add <name>  create workspace",
+		"  /map <keys> <a> bind keys to an action (/map alone lists them)",
 		"  /scheme list    list themes",
+		"  /config reload  read config.toml again",
 		"  /settings city <name>",
 		"  /settings weather on|off",
 		"  /settings unit c|f",
 		"",
 		"Press H or Esc to close.",
 	}
+	if a.showKeys {
+		lines = append([]string{lipgloss.NewStyle().Foreground(accent).Render("Keys")}, a.keyBindingLines()...)
+	}
 
 	if height > 0 && len(lines) > height {
 		lines = lines[:height]
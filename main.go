@@ -4,11 +4,32 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 
+	"github.com/appgram/td/internal/config"
 	"github.com/appgram/td/internal/db"
+	"github.com/appgram/td/internal/model"
 	"github.com/appgram/td/internal/tui"
 )
 
+// subcommands run as "td <name> [args]" against the opened database.
+var subcommands = map[string]func(database *db.DB, args []string) error{
+	"time":    runTime,
+	"effort":  runEffort,
+	"remind":  runRemind,
+	"export":  runExport,
+	"import":  runImport,
+	"serve":   runServe,
+	"sync":    runSync,
+	"backup":  runBackup,
+	"restore": runRestore,
+	"doctor":  runDoctor,
+}
+
+// cfg is config.toml, read once the database is open. It is empty when the
+// file is missing or broken.
+var cfg = &config.Config{}
+
 var (
 	version = "dev"
 	commit  = "none"
@@ -32,6 +53,25 @@ func main() {
 	}
 	defer database.Close()
 
+	if c, err := config.Load(config.Path(filepath.Dir(database.Path()))); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else {
+		cfg = c
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		run, ok := subcommands[args[0]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", args[0])
+			os.Exit(2)
+		}
+		if err := run(database, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *addTodo != "" {
 		workspaces, err := database.GetWorkspaces()
 		if err != nil {
@@ -48,18 +88,42 @@ func main() {
 			workspaces = []db.Workspace{{ID: wsID, Name: "Default", Order: 0, TaskCount: 0, CompletedCount: 0}}
 		}
 
-		// Parse inline syntax: "task #tag @date !priority"
-		parsed := tui.ParseTaskInput(*addTodo)
+		// Parse inline syntax: "task #tag @date +workspace !priority %status ~id =2h remind:1h-before"
+		parsed := model.ParseTaskInput(*addTodo, database)
 		if parsed.Title == "" {
 			fmt.Fprintf(os.Stderr, "Error: task title is required\n")
 			os.Exit(1)
 		}
+		wsID := workspaces[0].ID
+		if ref := parsed.Workspace; ref != "" || cfg.DefaultWorkspace != "" {
+			if ref == "" {
+				ref = cfg.DefaultWorkspace
+			}
+			if wsID, err = database.ResolveWorkspace(ref); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
 
-		_, err = database.AddTaskWithMeta(workspaces[0].ID, parsed.Title, nil, parsed.Tags, parsed.DueDate, parsed.Priority)
+		id, err := database.AddTaskWithMeta(wsID, parsed.Title, nil, parsed.Tags, parsed.DueDate, parsed.Priority, parsed.Status, parsed.Estimate)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		for _, r := range parsed.Reminders {
+			if err := database.AddReminder(id, r); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: cannot add reminder: %v\n", err)
+			}
+		}
+		for _, ref := range parsed.DependsOn {
+			dep, err := database.ResolveTask(ref)
+			if err == nil {
+				err = database.AddDependency(id, dep)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: cannot depend on %s: %v\n", ref, err)
+			}
+		}
 		fmt.Printf("Added: %s", parsed.Title)
 		if len(parsed.Tags) > 0 {
 			fmt.Printf(" [tags: %v]", parsed.Tags)
@@ -74,15 +138,29 @@ func main() {
 				p = "high"
 			case 1:
 				p = "low"
-			case -1:
-				p = "blocked"
 			}
 			fmt.Printf(" [priority: %s]", p)
 		}
+		if parsed.Status != "" {
+			fmt.Printf(" [status: %s]", parsed.Status)
+		}
+		if len(parsed.DependsOn) > 0 {
+			fmt.Printf(" [depends: %v]", parsed.DependsOn)
+		}
+		if !parsed.Estimate.IsZero() {
+			fmt.Printf(" [estimate: %s]", parsed.Estimate)
+		}
+		for _, r := range parsed.Reminders {
+			fmt.Printf(" [remind: %s]", r)
+		}
 		fmt.Println()
 		return
 	}
 
+	if err := autoBackup(database); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: automatic backup failed: %v\n", err)
+	}
+
 	app := tui.New(database)
 	if err := app.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "UI error: %v\n", err)